@@ -5,13 +5,16 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/baishancloud/mallard/componentlib/agent/promscrape"
+	"github.com/baishancloud/mallard/corelib/models"
 	"github.com/baishancloud/mallard/corelib/utils"
 )
 
 type (
 	collector struct {
-		Interval int    `json:"interval"`
-		Prefix   string `json:"prefix"`
+		Interval int                                `json:"interval"`
+		Prefix   string                             `json:"prefix"`
+		Options  map[string]*models.CollectorOption `json:"options"` // collector name -> option
 	}
 	transferConfig struct {
 		URLs           []string            `json:"urls"`
@@ -31,21 +34,42 @@ type (
 		CleanDays    int    `json:"clean_days"`
 		GzipDays     int    `json:"gzip_days"`
 	}
+	logtailopt struct {
+		Interval  int    `json:"interval"`
+		StateFile string `json:"state_file"`
+	}
+	statsdopt struct {
+		UDPAddr      string `json:"udp_addr"`
+		TCPAddr      string `json:"tcp_addr"`
+		GraphiteAddr string `json:"graphite_addr"`
+		Interval     int    `json:"interval"`
+	}
+	spool struct {
+		Dir            string `json:"dir"`
+		MaxSize        int64  `json:"max_size"`
+		MaxAge         int    `json:"max_age"`
+		ReplayInterval int    `json:"replay_interval"`
+	}
 	server struct {
 		Addr string `json:"addr"`
 	}
 	config struct {
-		Debug        bool           `json:"debug"`
-		Endpoint     string         `json:"endpoint"`
-		Core         int            `json:"core"`
-		Server       server         `json:"server"`
-		Transfer     transferConfig `json:"transfer,omitempty"`
-		Collector    collector      `json:"collector"`
-		Plugin       plugin         `json:"plugin"`
-		DisableJudge bool           `json:"disable_judge"`
-		Logutil      logopt         `json:"logutil"`
-		PerfFile     string         `json:"perf_file"`
-		UseAllConf   bool           `json:"use_allconf"`
+		Debug        bool                 `json:"debug"`
+		Endpoint     string               `json:"endpoint"`
+		Core         int                  `json:"core"`
+		Server       server               `json:"server"`
+		Transfer     transferConfig       `json:"transfer,omitempty"`
+		Collector    collector            `json:"collector"`
+		Plugin       plugin               `json:"plugin"`
+		DisableJudge bool                 `json:"disable_judge"`
+		JudgeDump    string               `json:"judge_dump"`
+		Logutil      logopt               `json:"logutil"`
+		Logtail      logtailopt           `json:"logtail"`
+		Statsd       statsdopt            `json:"statsd"`
+		Prometheus   []*promscrape.Target `json:"prometheus"`
+		Spool        spool                `json:"spool"`
+		PerfFile     string               `json:"perf_file"`
+		UseAllConf   bool                 `json:"use_allconf"`
 	}
 )
 
@@ -104,7 +128,21 @@ func defaultConfig() *config {
 			CleanDays:    4,
 			GzipDays:     2,
 		},
+		Logtail: logtailopt{
+			Interval:  60,
+			StateFile: "./var/logtail_state.json",
+		},
+		Statsd: statsdopt{
+			Interval: 60,
+		},
+		Spool: spool{
+			Dir:            "./var/spool",
+			MaxSize:        256,
+			MaxAge:         3600 * 6,
+			ReplayInterval: 10,
+		},
 		DisableJudge: false,
+		JudgeDump:    "./var/judge_snapshot.json",
 		UseAllConf:   false,
 		PerfFile:     "./datalogs/mallard2_agent.log",
 	}
@@ -117,6 +155,9 @@ func checkConfig(cfg *config) error {
 	if cfg.Collector.Interval == 0 {
 		return errors.New("need-sys-collect-over-0")
 	}
+	if cfg.Statsd.Interval <= 0 && (cfg.Statsd.UDPAddr != "" || cfg.Statsd.TCPAddr != "" || cfg.Statsd.GraphiteAddr != "") {
+		return errors.New("need-statsd-interval-over-0")
+	}
 	if len(cfg.Transfer.FullURLs("")) == 0 {
 		return errors.New("need-transfer-urls")
 	}
@@ -6,10 +6,14 @@ import (
 
 	"github.com/baishancloud/mallard/componentlib/agent/httpserver"
 	"github.com/baishancloud/mallard/componentlib/agent/judger"
+	"github.com/baishancloud/mallard/componentlib/agent/logtail"
 	"github.com/baishancloud/mallard/componentlib/agent/logutil"
 	"github.com/baishancloud/mallard/componentlib/agent/plugins"
+	"github.com/baishancloud/mallard/componentlib/agent/probe"
 	"github.com/baishancloud/mallard/componentlib/agent/processor"
+	"github.com/baishancloud/mallard/componentlib/agent/promscrape"
 	"github.com/baishancloud/mallard/componentlib/agent/serverinfo"
+	"github.com/baishancloud/mallard/componentlib/agent/statsd"
 	"github.com/baishancloud/mallard/componentlib/agent/syscollector"
 	"github.com/baishancloud/mallard/componentlib/agent/transfer"
 	"github.com/baishancloud/mallard/corelib/expvar"
@@ -52,6 +56,14 @@ func main() {
 	errorQueue := make(chan error, 2e3)
 
 	transfer.SetURLs(cfg.Transfer.FullURLs(serverinfo.Hostname()), cfg.Transfer.APIs)
+	transfer.SetSpool(cfg.Spool.Dir, cfg.Spool.MaxSize*1024*1024, time.Second*time.Duration(cfg.Spool.MaxAge))
+	if cfg.Spool.Dir != "" && cfg.Spool.ReplayInterval > 0 {
+		go transfer.ReplaySpool(time.Second * time.Duration(cfg.Spool.ReplayInterval))
+	}
+	if !cfg.DisableJudge {
+		judger.SetSnapshotFile(cfg.JudgeDump)
+		go judger.DumpSnapshotInterval(time.Minute)
+	}
 	configSyncOpt := transfer.SyncOption{
 		Interval:  time.Second * time.Duration(cfg.Transfer.ConfigInterval),
 		Version:   version,
@@ -60,8 +72,23 @@ func main() {
 	configSyncOpt.Func = func(epData *models.EndpointData, isUpdate bool) {
 		if isUpdate && epData.Config != nil {
 			if !cfg.DisableJudge {
-				judger.SetStrategyData(epData.Config.Strategies)
+				// send closed and syntax events, eventor closes problems of removed or broken strategies by them
+				if events := judger.SetStrategyData(epData.Config.Strategies); len(events) > 0 {
+					for _, evt := range events {
+						if evt.Endpoint == "" {
+							evt.Endpoint = serverinfo.Hostname()
+						}
+					}
+					eventsQueue <- events
+				}
 			}
+			syscollector.SetProcessRules(epData.Config.Processes)
+			logtail.SetRules(epData.Config.Logs)
+			if epData.Config.Builtin != nil {
+				probe.SetProbes(epData.Config.Builtin.Probes)
+				syscollector.SetRemoteOptions(epData.Config.Builtin.Collectors)
+			}
+			plugins.SetIntegrity(epData.Config.PluginsIntegrity)
 			plugins.SetDir(cfg.Plugin.Dir, cfg.Plugin.LogDir, epData.Config.Plugins)
 		}
 		if epData.Time > 0 {
@@ -91,10 +118,18 @@ func main() {
 
 	go processor.Process(metricsQueue, eventsQueue, errorQueue)
 
+	syscollector.SetOptions(cfg.Collector.Options)
 	go syscollector.Collect(cfg.Collector.Prefix,
 		time.Second*time.Duration(cfg.Collector.Interval),
 		metricsQueue, errorQueue)
 
+	go probe.Run(time.Second*time.Duration(cfg.Collector.Interval), metricsQueue)
+
+	if len(cfg.Prometheus) > 0 {
+		promscrape.SetTargets(cfg.Prometheus)
+		go promscrape.Scrape(time.Second*time.Duration(cfg.Collector.Interval), metricsQueue)
+	}
+
 	go plugins.Exec(metricsQueue)
 	go plugins.SyncScan(time.Minute)
 
@@ -105,11 +140,35 @@ func main() {
 	logutil.SetWriteFile(cfg.Logutil.WriteFile, cfg.Logutil.CleanDays, cfg.Logutil.GzipDays)
 	go logutil.ReadInterval(time.Second*time.Duration(cfg.Logutil.ReadInterval), metricsQueue)
 
+	if cfg.Logtail.Interval > 0 {
+		logtail.SetStateFile(cfg.Logtail.StateFile)
+		go logtail.Tail(time.Second*time.Duration(cfg.Logtail.Interval), metricsQueue)
+	}
+
+	if cfg.Statsd.UDPAddr != "" || cfg.Statsd.TCPAddr != "" || cfg.Statsd.GraphiteAddr != "" {
+		if cfg.Statsd.UDPAddr != "" {
+			go statsd.ListenUDP(cfg.Statsd.UDPAddr)
+		}
+		if cfg.Statsd.TCPAddr != "" {
+			go statsd.ListenTCP(cfg.Statsd.TCPAddr)
+		}
+		if cfg.Statsd.GraphiteAddr != "" {
+			go statsd.ListenGraphite(cfg.Statsd.GraphiteAddr)
+		}
+		go statsd.Flush(time.Second*time.Duration(cfg.Statsd.Interval), metricsQueue)
+	}
+
 	go expvar.PrintAlways("mallard2_agent_perf", cfg.PerfFile, time.Minute*2)
 
 	osutil.Wait()
 
 	syscollector.StopCollect()
+	plugins.Stop()
+	if !cfg.DisableJudge {
+		if err := judger.DumpSnapshot(); err != nil {
+			log.Warn("judge-dump-error", "error", err)
+		}
+	}
 	transfer.Stop()
 	logutil.Stop()
 	log.Sync()
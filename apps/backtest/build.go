@@ -0,0 +1,6 @@
+package main
+
+const (
+    // BuildTime is auto generated build time
+    BuildTime = "2018-08-06T15:49:27+0800"
+)
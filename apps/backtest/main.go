@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baishancloud/mallard/componentlib/judge/backtest"
+	"github.com/baishancloud/mallard/corelib/utils"
+)
+
+var (
+	version = "2.5.0"
+
+	reqFile  = flag.String("req", "backtest.json", "request file with strategy or expression")
+	dirs     = flag.String("dir", "./var,./datastore", "directories of recorded metrics, separated by comma")
+	start    = flag.String("start", "", "start time, as 2006-01-02 15:04 or unix timestamp, override request")
+	end      = flag.String("end", "", "end time, as 2006-01-02 15:04 or unix timestamp, override request")
+	showVer  = flag.Bool("v", false, "show version")
+	showTime = flag.Bool("vt", false, "show version and built time")
+)
+
+func parseTime(s string) (int64, error) {
+	if t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local); err == nil {
+		return t.Unix(), nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func main() {
+	flag.Parse()
+	if *showVer {
+		fmt.Println(version)
+		return
+	}
+	if *showTime {
+		fmt.Println("version : " + version)
+		fmt.Println("build : " + BuildTime)
+		return
+	}
+
+	req := new(backtest.Request)
+	if err := utils.ReadConfigFile(*reqFile, req); err != nil {
+		fmt.Println("read request error :", err)
+		os.Exit(1)
+	}
+	var err error
+	if *start != "" {
+		if req.Start, err = parseTime(*start); err != nil {
+			fmt.Println("parse start error :", err)
+			os.Exit(1)
+		}
+	}
+	if *end != "" {
+		if req.End, err = parseTime(*end); err != nil {
+			fmt.Println("parse end error :", err)
+			os.Exit(1)
+		}
+	}
+
+	result, err := backtest.Run(req, strings.Split(*dirs, ","))
+	if err != nil {
+		fmt.Println("backtest error :", err)
+		os.Exit(1)
+	}
+	b, _ := json.MarshalIndent(result, "", "\t")
+	fmt.Println(string(b))
+}
@@ -7,6 +7,7 @@ type config struct {
 	HTTPAddr       string `json:"http_addr,omitempty"`
 	PerfFile       string `json:"perf_file,omitempty"`
 	ReloadInterval int    `json:"reload_interval,omitempty"`
+	Timezone       string `json:"timezone,omitempty"` // timezone of strategies without timezone, default is local timezone
 }
 
 func defaultConfig() config {
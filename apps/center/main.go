@@ -8,6 +8,7 @@ import (
 	"github.com/baishancloud/mallard/componentlib/center/sqldata"
 	"github.com/baishancloud/mallard/corelib/expvar"
 	"github.com/baishancloud/mallard/corelib/httputil"
+	"github.com/baishancloud/mallard/corelib/models"
 	"github.com/baishancloud/mallard/corelib/osutil"
 	"github.com/baishancloud/mallard/corelib/utils"
 	"github.com/baishancloud/mallard/corelib/zaplog"
@@ -31,6 +32,17 @@ func prepare() {
 	if err := utils.ReadConfigFile(configFile, &cfg); err != nil {
 		log.Fatal("config-error", "error", err)
 	}
+	if cfg.Timezone == "" {
+		cfg.Timezone = models.LocalTimezone()
+	}
+	if cfg.Timezone == "" {
+		// empty timezone makes agents judge running windows in their own local time
+		log.Fatal("timezone-unknown", "error", "set timezone in config, local timezone has no IANA name")
+	}
+	if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+		log.Fatal("timezone-error", "error", err)
+	}
+	log.Info("init-timezone", "timezone", cfg.Timezone)
 }
 
 func prepareDB(portalDSN, uicDSN string) (*sqlx.DB, *sqlx.DB, error) {
@@ -62,6 +74,7 @@ func main() {
 
 	sqldata.InitExpvars()
 	sqldata.SetDB(pdb, cdb)
+	sqldata.SetDefaultTimezone(cfg.Timezone)
 	go sqldata.Sync(time.Second*time.Duration(cfg.ReloadInterval), nil)
 
 	go httputil.Listen(cfg.HTTPAddr, centerhandler.Handlers())
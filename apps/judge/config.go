@@ -5,6 +5,10 @@ type (
 		Addr     string `json:"addr"`
 		Interval int    `json:"interval"`
 	}
+	baselineConfig struct {
+		File    string `json:"file"`
+		MaxDays int    `json:"max_days"`
+	}
 	transferConfig struct {
 		URLs []string          `json:"urls"`
 		APIs map[string]string `json:"apis"`
@@ -12,6 +16,7 @@ type (
 	config struct {
 		Transfer transferConfig `json:"transfer"`
 		StoreDir string         `json:"store_dir"`
+		Baseline baselineConfig `json:"baseline"`
 		Center   center         `json:"center"`
 		HTTPAddr string         `json:"http_addr"`
 		PerfFile string         `json:"perf_file"`
@@ -33,6 +38,10 @@ func defaultConfig() config {
 			},
 		},
 		StoreDir: "./datastore",
+		Baseline: baselineConfig{
+			File:    "baseline.dump",
+			MaxDays: 7,
+		},
 		HTTPAddr: "0.0.0.0:10988",
 		Center: center{
 			Addr:     "http://127.0.0.1:10999",
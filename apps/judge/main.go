@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/baishancloud/mallard/componentlib/agent/transfer"
+	"github.com/baishancloud/mallard/componentlib/judge/baseline"
 	"github.com/baishancloud/mallard/componentlib/judge/judgehandler"
 	"github.com/baishancloud/mallard/componentlib/judge/judgestore"
 	"github.com/baishancloud/mallard/componentlib/judge/judgestore/filter"
@@ -57,7 +58,10 @@ func main() {
 	go httputil.Listen(cfg.HTTPAddr, judgehandler.Create())
 
 	multijudge.SetCachedEventsFile("cache_events.dump")
-	multijudge.RegisterFn(judgestore.WriteMetrics, multijudge.Judge)
+	baseline.SetStore(cfg.Baseline.File, cfg.Baseline.MaxDays)
+	go baseline.Sync(time.Minute)
+
+	multijudge.RegisterFn(judgestore.WriteMetrics, multijudge.Judge, baseline.Judge)
 	go multijudge.Process(queue)
 	go multijudge.ScanForEvents(time.Second * 20)
 
@@ -68,6 +72,7 @@ func main() {
 	osutil.Wait()
 
 	httputil.Close()
+	baseline.Close()
 	judgestore.Close()
 	log.Sync()
 }
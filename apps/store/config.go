@@ -16,6 +16,7 @@ type (
 	Transfer struct {
 		URLs           map[string]string `json:"urls,omitempty"`
 		PullConcurrent int               `json:"pull_concurrent,omitempty"`
+		AckLease       int               `json:"ack_lease,omitempty"`
 	}
 	// Config is all config
 	Config struct {
@@ -68,6 +68,9 @@ func main() {
 	go influxdb.Process(queue)
 	go influxdb.SyncExpvars(time.Minute, cfg.StatInfluxdbFile)
 
+	if transferCfg.AckLease > 0 {
+		puller.SetAckLease(transferCfg.AckLease)
+	}
 	puller.Prepare(queue, transferCfg.URLs, transferCfg.PullConcurrent)
 	go puller.SyncExpvars(time.Minute, cfg.StatPullerFile)
 
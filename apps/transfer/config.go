@@ -8,8 +8,22 @@ type config struct {
 	IsPublic    bool              `json:"is_public,omitempty"`
 	EventorAddr map[string]string `json:"eventor_addr,omitempty"`
 	PerfFile    string            `json:"perf_file,omitempty"`
+	Queue       queueConfig       `json:"queue,omitempty"`
 }
 
+type queueConfig struct {
+	Type         string `json:"type,omitempty"`
+	Dir          string `json:"dir,omitempty"`
+	SegmentSize  int64  `json:"segment_size,omitempty"`
+	SyncPolicy   string `json:"sync_policy,omitempty"`
+	SyncInterval int    `json:"sync_interval,omitempty"`
+}
+
+const (
+	queueTypeMemory = "memory"
+	queueTypeWAL    = "wal"
+)
+
 func defaultConfig() config {
 	return config{
 		Debug:       true,
@@ -18,5 +32,12 @@ func defaultConfig() config {
 		HTTPAddr:    "0.0.0.0:10899",
 		TokenFile:   "tokens.json",
 		IsPublic:    false,
+		Queue: queueConfig{
+			Type:         queueTypeMemory,
+			Dir:          "_wal",
+			SegmentSize:  64,
+			SyncPolicy:   "interval",
+			SyncInterval: 1000,
+		},
 	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"path/filepath"
 	"runtime"
 	"time"
 
@@ -47,14 +48,11 @@ func main() {
 	go httptoken.SyncVerifier(cfg.TokenFile, time.Second*15)
 
 	// prepare queues
-	mQueue := queues.NewQueue(1e6, "_dump/metrics")
-	go mQueue.ScanDump(time.Minute, func(res queues.ScanDumpResult) {
-		log.Info("read-metrics-dump", "dump", res)
-	})
-	evtQueue := queues.NewQueue(1e6, "_dump/events")
-	go evtQueue.ScanDump(time.Minute, func(res queues.ScanDumpResult) {
-		log.Info("read-events-dump", "dump", res)
+	mQueue := createQueue("metrics")
+	go mQueue.ScanLeases(time.Second*10, func(count int) {
+		log.Info("metrics-lease-expired", "count", count)
 	})
+	evtQueue := createQueue("events")
 
 	// init event-sender
 	eventsender.SetURLs(cfg.EventorAddr)
@@ -76,7 +74,39 @@ func main() {
 	log.Sync()
 }
 
+func createQueue(name string) *queues.Queue {
+	if cfg.Queue.Type == queueTypeWAL {
+		queue, err := queues.NewWALQueue(1e6, filepath.Join(cfg.Queue.Dir, name), queues.WALOption{
+			SegmentSize:  cfg.Queue.SegmentSize * 1024 * 1024,
+			SyncPolicy:   cfg.Queue.SyncPolicy,
+			SyncInterval: time.Millisecond * time.Duration(cfg.Queue.SyncInterval),
+		})
+		if err != nil {
+			log.Fatal("open-wal-error", "name", name, "error", err)
+		}
+		log.Info("open-wal", "name", name, "length", queue.Len())
+		return queue
+	}
+	queue := queues.NewQueue(1e6, "_dump/"+name)
+	go queue.ScanDump(time.Minute, func(res queues.ScanDumpResult) {
+		log.Info("read-"+name+"-dump", "dump", res)
+	})
+	return queue
+}
+
 func dump(mQueue, evtQueue *queues.Queue) {
+	if count := mQueue.ReleaseLeases(); count > 0 {
+		log.Info("metrics-lease-release", "count", count)
+	}
+	if cfg.Queue.Type == queueTypeWAL {
+		if err := mQueue.Close(); err != nil {
+			log.Warn("metrics-wal-close-error", "error", err)
+		}
+		if err := evtQueue.Close(); err != nil {
+			log.Warn("events-wal-close-error", "error", err)
+		}
+		return
+	}
 	file, count, err := mQueue.Dump(1e6 * 2)
 	if err != nil {
 		log.Warn("metrics-dump-error", "error", err)
@@ -2,8 +2,10 @@ package httpserver
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/baishancloud/mallard/componentlib/agent/judger"
 	"github.com/baishancloud/mallard/componentlib/agent/plugins"
@@ -19,6 +21,8 @@ import (
 var (
 	log    = zaplog.Zap("http")
 	mQueue chan<- []*models.Metric
+
+	counterRater = models.NewCounterRater(time.Hour)
 )
 
 func init() {
@@ -38,6 +42,8 @@ func CreateHandlers() http.Handler {
 	r.POST("/v1/push", metricsRecv)
 	r.POST("/v2/push", metricsNewRecv)
 	r.GET("/v1/version", versionGet)
+	r.GET("/v1/plugins", pluginsGet)
+	r.POST("/v1/plugins/run", pluginRun)
 	r.HandlerFunc("GET", "/debug/vars", expvar.HTTPHandler)
 	r = pprofwrap.Wrap(r)
 	return r
@@ -78,14 +84,17 @@ func metricsRecv(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	if len(oldMs) > 0 {
 		ms := make([]*models.Metric, 0, len(oldMs))
 		for _, m := range oldMs {
-			m2, err := m.ToNew()
+			m2, err := counterRater.ToNew(m)
 			if err != nil {
 				responseFail(rw, err, "recv error")
 				return
 			}
+			if m2 == nil {
+				continue
+			}
 			ms = append(ms, m2)
 		}
-		if mQueue != nil {
+		if mQueue != nil && len(ms) > 0 {
 			mQueue <- ms
 		}
 		metricRecvCount.Incr(int64(len(ms)))
@@ -165,6 +174,50 @@ func versionGet(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	encoder.Encode(m)
 }
 
+func pluginsGet(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	writeBodyJSON(rw, plugins.Statuses())
+}
+
+// isLoopback checks request is from loopback address
+func isLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// pluginRun runs plugin file once, it's only allowed from loopback address
+func pluginRun(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !isLoopback(r) {
+		rw.WriteHeader(403)
+		rw.Write([]byte("only loopback is allowed"))
+		log.Warn("plugin-run-forbidden", "remote", r.RemoteAddr)
+		return
+	}
+	file := r.FormValue("file")
+	if file == "" {
+		rw.WriteHeader(400)
+		rw.Write([]byte("file is required"))
+		return
+	}
+	metrics, err := plugins.Run(file)
+	if err != nil {
+		if err == plugins.ErrPluginNotFound {
+			rw.WriteHeader(404)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		responseFail(rw, err, "plugin-run-error")
+		return
+	}
+	if metrics == nil {
+		metrics = []*models.Metric{}
+	}
+	writeBodyJSON(rw, metrics)
+}
+
 func keysOfMap(m map[string]struct{}) []string {
 	if len(m) == 0 {
 		return []string{}
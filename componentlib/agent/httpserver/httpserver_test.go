@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 
+	"github.com/baishancloud/mallard/componentlib/agent/plugins"
 	"github.com/baishancloud/mallard/corelib/models"
 	"github.com/baishancloud/mallard/corelib/zaplog"
 	. "github.com/smartystreets/goconvey/convey"
@@ -60,6 +62,32 @@ func TestGet(t *testing.T) {
 		So(result["version"], ShouldEqual, "9.9.9")
 		So(result, ShouldContainKey, "plugin")
 	})
+	Convey("get-plugins", t, func() {
+		resp, err := http.Get(server.URL + "/v1/plugins")
+		So(err, ShouldBeNil)
+		So(resp.StatusCode, ShouldEqual, 200)
+		var list []*plugins.Status
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		So(err, ShouldBeNil)
+
+		resp, err = http.Get(server.URL + "/v1/plugins/run?file=60_none.sh")
+		So(err, ShouldBeNil)
+		So(resp.StatusCode, ShouldEqual, 405)
+
+		resp, err = http.PostForm(server.URL+"/v1/plugins/run", nil)
+		So(err, ShouldBeNil)
+		So(resp.StatusCode, ShouldEqual, 400)
+
+		resp, err = http.PostForm(server.URL+"/v1/plugins/run", url.Values{"file": {"60_none.sh"}})
+		So(err, ShouldBeNil)
+		So(resp.StatusCode, ShouldEqual, 404)
+
+		req := httptest.NewRequest("POST", "/v1/plugins/run?file=60_none.sh", nil)
+		req.RemoteAddr = "10.0.0.1:12345"
+		rw := httptest.NewRecorder()
+		pluginRun(rw, req, nil)
+		So(rw.Code, ShouldEqual, 403)
+	})
 }
 
 func TestPushMetrics(t *testing.T) {
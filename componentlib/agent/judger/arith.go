@@ -0,0 +1,431 @@
+package judger
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/baishancloud/mallard/corelib/models"
+)
+
+var (
+	// ErrArithSyntax is error of invalid arithmetic expression
+	ErrArithSyntax = errors.New("arith-syntax-error")
+	// ErrArithDivideByZero is error of dividing by zero in arithmetic expression
+	ErrArithDivideByZero = errors.New("arith-divide-by-zero")
+	// ErrArithInvalidResult is error of NaN or Inf result in arithmetic expression
+	ErrArithInvalidResult = errors.New("arith-invalid-result")
+)
+
+const arithMaxDepth = 32
+
+// IsArithTransform checks field transform is select() with arithmetic expression,
+// select(field) with plain field name is not,
+// a field name with operators such as select(io-read) is still selected as field if metric has it
+func IsArithTransform(transform string) bool {
+	if !strings.HasPrefix(transform, "select(") || !strings.HasSuffix(transform, ")") {
+		return false
+	}
+	inner := strings.TrimSpace(transform[len("select(") : len(transform)-1])
+	return strings.ContainsAny(inner, "+-*/(), ")
+}
+
+// Arith is operator for select() with arithmetic expression,
+// such as select(used/total*100) or select(abs(value-baseline))
+type Arith struct {
+	base        *StrategyBase
+	compareFn   CompareFunc
+	calculateFn CalculateFunc
+	expr        arithNode
+}
+
+// NewArithFromStrategy return arithmetic select operator with strategy
+func NewArithFromStrategy(st *models.Strategy) (*Arith, error) {
+	var (
+		s   Arith
+		err error
+	)
+	s.base, err = NewStrategyBase(st)
+	if err != nil {
+		return nil, err
+	}
+	s.calculateFn = NewCalculateFunc(s.base.CalType)
+	if s.calculateFn == nil {
+		return nil, ErrUnknownCalcuateFunc
+	}
+	s.compareFn = NewCompareFunc(st.Operator)
+	if s.compareFn == nil {
+		return nil, ErrUnknownCompareFunc
+	}
+	if !IsArithTransform(st.FieldTransform) {
+		return nil, ErrUnknownTransform
+	}
+	s.base.Field = strings.TrimSpace(st.FieldTransform[len("select(") : len(st.FieldTransform)-1])
+	tokens, err := arithTokenize(s.base.Field)
+	if err != nil {
+		return nil, err
+	}
+	p := &arithParser{tokens: tokens}
+	if s.expr, err = p.parse(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Limit return data queue size
+func (s *Arith) Limit() int {
+	return s.base.Limit
+}
+
+// Transform evaluates expression with metric fields and value,
+// if metric has a field named as whole expression, such as "io-read", uses the field
+func (s *Arith) Transform(metric *models.Metric) (float64, error) {
+	if _, ok := metric.Fields[s.base.Field]; ok {
+		return selectField(metric, s.base.Field)
+	}
+	return evalArith(s.expr, metric)
+}
+
+func evalArith(expr arithNode, metric *models.Metric) (float64, error) {
+	v, err := expr.eval(metric)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, ErrArithInvalidResult
+	}
+	return v, nil
+}
+
+// Trigger check history data with strategy
+func (s *Arith) Trigger(historyList []*models.EventValue) (float64, bool, error) {
+	return s.calculateFn(historyList, s.base.RightValue, s.compareFn, s.base.Args...)
+}
+
+// Tags return strategy tags condition
+func (s *Arith) Tags() map[string]TagFilterFunc {
+	if s.base == nil {
+		return nil
+	}
+	return s.base.Tags
+}
+
+// Base return strategy base info
+func (s *Arith) Base() *StrategyBase {
+	return s.base
+}
+
+type arithNode interface {
+	eval(metric *models.Metric) (float64, error)
+}
+
+type arithNumber float64
+
+func (n arithNumber) eval(metric *models.Metric) (float64, error) {
+	return float64(n), nil
+}
+
+type arithField string
+
+func (f arithField) eval(metric *models.Metric) (float64, error) {
+	return selectField(metric, string(f))
+}
+
+type arithNegative struct {
+	node arithNode
+}
+
+func (n arithNegative) eval(metric *models.Metric) (float64, error) {
+	v, err := n.node.eval(metric)
+	return -v, err
+}
+
+type arithBinary struct {
+	op          byte
+	left, right arithNode
+}
+
+func (b arithBinary) eval(metric *models.Metric) (float64, error) {
+	left, err := b.left.eval(metric)
+	if err != nil {
+		return 0, err
+	}
+	right, err := b.right.eval(metric)
+	if err != nil {
+		return 0, err
+	}
+	switch b.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		if right == 0 {
+			return 0, ErrArithDivideByZero
+		}
+		return left / right, nil
+	}
+	return 0, ErrArithSyntax
+}
+
+type arithFuncInfo struct {
+	args int // -1 means at least one
+	fn   func(values []float64) float64
+}
+
+var arithFuncs = map[string]arithFuncInfo{
+	"abs":   {1, func(v []float64) float64 { return math.Abs(v[0]) }},
+	"sqrt":  {1, func(v []float64) float64 { return math.Sqrt(v[0]) }},
+	"log":   {1, func(v []float64) float64 { return math.Log(v[0]) }},
+	"log10": {1, func(v []float64) float64 { return math.Log10(v[0]) }},
+	"ceil":  {1, func(v []float64) float64 { return math.Ceil(v[0]) }},
+	"floor": {1, func(v []float64) float64 { return math.Floor(v[0]) }},
+	"round": {1, func(v []float64) float64 { return math.Floor(v[0] + 0.5) }},
+	"pow":   {2, func(v []float64) float64 { return math.Pow(v[0], v[1]) }},
+	"min": {-1, func(v []float64) float64 {
+		m := v[0]
+		for _, f := range v[1:] {
+			m = math.Min(m, f)
+		}
+		return m
+	}},
+	"max": {-1, func(v []float64) float64 {
+		m := v[0]
+		for _, f := range v[1:] {
+			m = math.Max(m, f)
+		}
+		return m
+	}},
+}
+
+type arithCall struct {
+	info arithFuncInfo
+	args []arithNode
+}
+
+func (c arithCall) eval(metric *models.Metric) (float64, error) {
+	values := make([]float64, len(c.args))
+	for i, arg := range c.args {
+		v, err := arg.eval(metric)
+		if err != nil {
+			return 0, err
+		}
+		values[i] = v
+	}
+	return c.info.fn(values), nil
+}
+
+type arithToken struct {
+	kind  byte // 'n' number, 'i' identifier, or operator char
+	text  string
+	value float64
+	pos   int
+}
+
+func isArithIdentChar(c byte, first bool) bool {
+	if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+		return true
+	}
+	return !first && (c == '.' || (c >= '0' && c <= '9'))
+}
+
+func arithTokenize(expr string) ([]arithToken, error) {
+	var tokens []arithToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.IndexByte("+-*/(),", c) >= 0:
+			tokens = append(tokens, arithToken{kind: c, text: string(c), pos: i})
+			i++
+		case (c >= '0' && c <= '9') || c == '.':
+			start := i
+			for i < len(expr) && ((expr[i] >= '0' && expr[i] <= '9') || expr[i] == '.') {
+				i++
+			}
+			v, err := strconv.ParseFloat(expr[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad number '%s' at %d", ErrArithSyntax, expr[start:i], start)
+			}
+			tokens = append(tokens, arithToken{kind: 'n', text: expr[start:i], value: v, pos: start})
+		case isArithIdentChar(c, true):
+			start := i
+			for i < len(expr) && isArithIdentChar(expr[i], false) {
+				i++
+			}
+			tokens = append(tokens, arithToken{kind: 'i', text: expr[start:i], pos: start})
+		default:
+			return nil, fmt.Errorf("%s: unexpected '%c' at %d", ErrArithSyntax, c, i)
+		}
+	}
+	return tokens, nil
+}
+
+// arithParser is recursive descent parser for arithmetic expression:
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/") unary }
+//	unary   = ("-" | "+") unary | primary
+//	primary = number | field | func "(" expr { "," expr } ")" | "(" expr ")"
+type arithParser struct {
+	tokens []arithToken
+	idx    int
+	depth  int
+}
+
+func (p *arithParser) peek() *arithToken {
+	if p.idx < len(p.tokens) {
+		return &p.tokens[p.idx]
+	}
+	return nil
+}
+
+func (p *arithParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %s", ErrArithSyntax, fmt.Sprintf(format, args...))
+}
+
+func (p *arithParser) unexpected() error {
+	if t := p.peek(); t != nil {
+		return p.errorf("unexpected '%s' at %d", t.text, t.pos)
+	}
+	return p.errorf("unexpected end")
+}
+
+func (p *arithParser) expect(kind byte) error {
+	if t := p.peek(); t != nil && t.kind == kind {
+		p.idx++
+		return nil
+	}
+	return p.unexpected()
+}
+
+func (p *arithParser) parse() (arithNode, error) {
+	if len(p.tokens) == 0 {
+		return nil, p.errorf("empty expression")
+	}
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != nil {
+		return nil, p.unexpected()
+	}
+	return node, nil
+}
+
+func (p *arithParser) parseExpr() (arithNode, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > arithMaxDepth {
+		return nil, p.errorf("too deep")
+	}
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t != nil && (t.kind == '+' || t.kind == '-'); t = p.peek() {
+		p.idx++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = arithBinary{op: t.kind, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *arithParser) parseTerm() (arithNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t != nil && (t.kind == '*' || t.kind == '/'); t = p.peek() {
+		p.idx++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = arithBinary{op: t.kind, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *arithParser) parseUnary() (arithNode, error) {
+	t := p.peek()
+	if t != nil && (t.kind == '-' || t.kind == '+') {
+		p.idx++
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > arithMaxDepth {
+			return nil, p.errorf("too deep")
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if t.kind == '-' {
+			return arithNegative{node: node}, nil
+		}
+		return node, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *arithParser) parsePrimary() (arithNode, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, p.unexpected()
+	}
+	switch t.kind {
+	case 'n':
+		p.idx++
+		return arithNumber(t.value), nil
+	case '(':
+		p.idx++
+		node, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expect(')')
+	case 'i':
+		p.idx++
+		if next := p.peek(); next == nil || next.kind != '(' {
+			return arithField(t.text), nil
+		}
+		return p.parseCall(t)
+	}
+	return nil, p.unexpected()
+}
+
+func (p *arithParser) parseCall(name *arithToken) (arithNode, error) {
+	info, ok := arithFuncs[name.text]
+	if !ok {
+		return nil, p.errorf("unknown function '%s' at %d", name.text, name.pos)
+	}
+	p.idx++ // skip "("
+	call := arithCall{info: info}
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+		if t := p.peek(); t != nil && t.kind == ',' {
+			p.idx++
+			continue
+		}
+		break
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	if info.args > 0 && len(call.args) != info.args {
+		return nil, p.errorf("function '%s' needs %d arguments", name.text, info.args)
+	}
+	return call, nil
+}
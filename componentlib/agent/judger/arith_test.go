@@ -0,0 +1,135 @@
+package judger
+
+import (
+	"testing"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestArith(t *testing.T) {
+	newArith := func(transform string) (Operator, error) {
+		return FromStrategy(&models.Strategy{
+			ID:             1,
+			Metric:         "disk",
+			FieldTransform: transform,
+			Func:           "all(#1)",
+			Operator:       ">",
+			RightValue:     90,
+		})
+	}
+	metric := &models.Metric{
+		Name:  "disk",
+		Value: 5,
+		Fields: map[string]interface{}{
+			"used":     80,
+			"total":    200.0,
+			"errors":   3,
+			"requests": "5",
+			"baseline": 9.0,
+		},
+	}
+
+	Convey("arith.plain", t, func() {
+		op, err := newArith("select(used)")
+		So(err, ShouldBeNil)
+		So(op, ShouldHaveSameTypeAs, &Select{})
+	})
+
+	Convey("arith.eval", t, func() {
+		cases := map[string]float64{
+			"select(used/total*100)":           40,
+			"select(errors/(requests+1))":      0.5,
+			"select(abs(value-baseline))":      4,
+			"select(-value + 2 * 3)":           1,
+			"select(max(used, total, 100)/10)": 20,
+			"select(pow(value,2) - sqrt(16))":  21,
+			"select(round(total/3))":           67,
+		}
+		for transform, expect := range cases {
+			op, err := newArith(transform)
+			So(err, ShouldBeNil)
+			So(op, ShouldHaveSameTypeAs, &Arith{})
+			v, err := op.Transform(metric)
+			So(err, ShouldBeNil)
+			So(v, ShouldAlmostEqual, expect)
+		}
+	})
+
+	Convey("arith.syntax", t, func() {
+		// arithmetic syntax errors fall back to plain field, as select() before arithmetic
+		for _, transform := range []string{
+			"select((used+1)",
+			"select(foo(used))",
+			"select(pow(used))",
+		} {
+			_, err := newArith(transform)
+			So(err, ShouldNotBeNil)
+		}
+		for _, transform := range []string{
+			"select(used/)",
+			"select(used+1))",
+			"select(used $ 2)",
+		} {
+			op, err := newArith(transform)
+			So(err, ShouldBeNil)
+			So(op, ShouldHaveSameTypeAs, &Select{})
+			_, err = op.Transform(metric)
+			So(err, ShouldHaveSameTypeAs, FieldMissingError{})
+		}
+
+		op, err := newArith("select(http-5xx)")
+		So(err, ShouldBeNil)
+		So(op, ShouldHaveSameTypeAs, &Select{})
+		v, err := op.Transform(&models.Metric{
+			Name:   "disk",
+			Fields: map[string]interface{}{"http-5xx": 4},
+		})
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, 4)
+	})
+
+	Convey("arith.eval.error", t, func() {
+		op, err := newArith("select(used/missing)")
+		So(err, ShouldBeNil)
+		_, err = op.Transform(metric)
+		So(err, ShouldHaveSameTypeAs, FieldMissingError{})
+
+		op, err = newArith("select(used/(total-200))")
+		So(err, ShouldBeNil)
+		_, err = op.Transform(metric)
+		So(err, ShouldEqual, ErrArithDivideByZero)
+
+		op, err = newArith("select(sqrt(0-used))")
+		So(err, ShouldBeNil)
+		_, err = op.Transform(metric)
+		So(err, ShouldEqual, ErrArithInvalidResult)
+	})
+
+	Convey("arith.field", t, func() {
+		op, err := newArith("select(io-read)")
+		So(err, ShouldBeNil)
+		v, err := op.Transform(&models.Metric{
+			Name:   "disk",
+			Fields: map[string]interface{}{"io-read": 7, "io": 10, "read": 2},
+		})
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, 7) // field named io-read, not io minus read
+		v, err = op.Transform(&models.Metric{
+			Name:   "disk",
+			Fields: map[string]interface{}{"io": 10, "read": 2},
+		})
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, 8)
+
+		op, err = newArith("select(value*2)")
+		So(err, ShouldBeNil)
+		v, err = op.Transform(&models.Metric{
+			Name:   "disk",
+			Value:  5,
+			Fields: map[string]interface{}{"value": 100},
+		})
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, 10) // value is metric value, same as select(value)
+	})
+}
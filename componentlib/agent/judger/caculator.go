@@ -3,6 +3,9 @@ package judger
 import (
 	"errors"
 	"math"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/baishancloud/mallard/corelib/models"
 )
@@ -25,6 +28,8 @@ func getCalculateLimit(l int, t string) int {
 		return l + 1
 	case "pdiffavg":
 		return l + 1
+	case "rate":
+		return l + 1
 	}
 	return l
 }
@@ -63,6 +68,23 @@ func NewCalculateFunc(t string) CalculateFunc {
 		return calculateSum
 	case "have":
 		return calculateHave
+	case "min":
+		return calculateMin
+	case "max":
+		return calculateMax
+	case "median":
+		return newCalculatePercentile(50)
+	case "stddev":
+		return calculateStddev
+	case "rate":
+		return calculateRate
+	}
+	if strings.HasPrefix(t, "p") {
+		// pNN means NN percentile, such as p95, p99.9
+		q, err := strconv.ParseFloat(t[1:], 64)
+		if err == nil && q > 0 && q <= 100 {
+			return newCalculatePercentile(q)
+		}
 	}
 	return nil
 }
@@ -296,3 +318,66 @@ func calculateHave(values []*models.EventValue, rightValue float64, compareFn Co
 	}
 	return values[notLeftValueIndex].Value, false, nil
 }
+
+func calculateMin(values []*models.EventValue, rightValue float64, compareFn CompareFunc, _ ...interface{}) (float64, bool, error) {
+	realValue := values[0].Value
+	for _, v := range values {
+		realValue = minValue(realValue, v.Value)
+	}
+	return realValue, compareFn(realValue, rightValue), nil
+}
+
+func calculateMax(values []*models.EventValue, rightValue float64, compareFn CompareFunc, _ ...interface{}) (float64, bool, error) {
+	realValue := values[0].Value
+	for _, v := range values {
+		if v.Value > realValue {
+			realValue = v.Value
+		}
+	}
+	return realValue, compareFn(realValue, rightValue), nil
+}
+
+// newCalculatePercentile returns calculate function of q percentile,
+// it interpolates linearly between the closest ranks
+func newCalculatePercentile(q float64) CalculateFunc {
+	return func(values []*models.EventValue, rightValue float64, compareFn CompareFunc, _ ...interface{}) (float64, bool, error) {
+		sorted := make([]float64, 0, len(values))
+		for _, v := range values {
+			sorted = append(sorted, v.Value)
+		}
+		sort.Float64s(sorted)
+		rank := q / 100 * float64(len(sorted)-1)
+		lower := int(math.Floor(rank))
+		upper := int(math.Ceil(rank))
+		realValue := sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+		return realValue, compareFn(realValue, rightValue), nil
+	}
+}
+
+func calculateStddev(values []*models.EventValue, rightValue float64, compareFn CompareFunc, _ ...interface{}) (float64, bool, error) {
+	var avg float64
+	for _, v := range values {
+		avg += v.Value
+	}
+	avg = avg / float64(len(values))
+	var realValue float64
+	for _, v := range values {
+		realValue += (v.Value - avg) * (v.Value - avg)
+	}
+	realValue = math.Sqrt(realValue / float64(len(values)))
+	return realValue, compareFn(realValue, rightValue), nil
+}
+
+var (
+	// ErrRateFuncNeedTimeRange means rate function need values in different time
+	ErrRateFuncNeedTimeRange = errors.New("rate func need values in different time")
+)
+
+func calculateRate(values []*models.EventValue, rightValue float64, compareFn CompareFunc, _ ...interface{}) (float64, bool, error) {
+	first, last := values[0], values[len(values)-1]
+	if first.Time == last.Time {
+		return 0, false, ErrRateFuncNeedTimeRange
+	}
+	realValue := (first.Value - last.Value) / float64(first.Time-last.Time)
+	return realValue, compareFn(realValue, rightValue), nil
+}
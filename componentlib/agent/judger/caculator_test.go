@@ -0,0 +1,48 @@
+package judger
+
+import (
+	"testing"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCalculateStatFunc(t *testing.T) {
+	Convey("calculate.stat", t, func() {
+		// real values, 1,2,3,5,7,11,13,17
+		cases := []struct {
+			Func       string
+			Operator   string
+			RightValue float64
+			Accepts    accepts
+		}{
+			{"min(#5)", ">=", 1, accepts{5, true, 1, 0}},
+			{"max(#5)", ">", 7, accepts{5, false, 7, 0}},
+			{"median(#5)", "==", 3, accepts{5, true, 3, 0}},
+			{"median(#6)", "==", 4, accepts{6, true, 4, 0}},
+			{"p50(#8)", "==", 6, accepts{8, true, 6, 0}},
+			{"p90(#5)", ">", 6, accepts{5, true, 6.2, 0}},
+			{"p100(#8)", "<", 17, accepts{8, false, 17, 0}},
+			{"p99.9(#2)", "<", 2, accepts{2, true, 1.999, 0}},
+			{"stddev(#3)", "<", 1, accepts{3, true, 0.816496580927726, 0}},
+			{"stddev(#2)", ">", 1, accepts{2, false, 0.5, 0}},
+			// (1-11)/(100-600) per second
+			{"rate(#5)", "==", 0.02, accepts{6, true, 0.02, 0}},
+			{"rate(#2)", ">", 0.01, accepts{3, false, 0.01, 0}},
+		}
+		for _, c := range cases {
+			testCalOperator(genSelect(c.Func, c.Operator, c.RightValue), c.Accepts)
+		}
+	})
+
+	Convey("calculate.stat.invalid", t, func() {
+		So(NewCalculateFunc("p0"), ShouldBeNil)
+		So(NewCalculateFunc("p101"), ShouldBeNil)
+		So(NewCalculateFunc("px"), ShouldBeNil)
+
+		fn := NewCalculateFunc("rate")
+		values := []*models.EventValue{{Value: 1, Time: 100}, {Value: 2, Time: 100}}
+		_, _, err := fn(values, 0, NewCompareFunc(">"))
+		So(err, ShouldEqual, ErrRateFuncNeedTimeRange)
+	})
+}
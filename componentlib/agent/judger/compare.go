@@ -2,7 +2,10 @@ package judger
 
 import (
 	"math"
+	"path"
+	"regexp"
 	"strings"
+	"sync"
 )
 
 // CompareFunc is function to run comparation of left and right value
@@ -42,8 +45,78 @@ func NewCompareFunc(operator string) CompareFunc {
 // TagFilterFunc is tag filter function
 type TagFilterFunc func(left string) bool
 
-// NewTagFilterFunc parse operator to gain right tag string and proper filter func
-func NewTagFilterFunc(key, operator string) (string, TagFilterFunc) {
+var (
+	tagRegexps     = make(map[string]*regexp.Regexp)
+	tagRegexpsLock sync.RWMutex
+)
+
+func compileTagRegexp(pattern string) (*regexp.Regexp, error) {
+	tagRegexpsLock.RLock()
+	re := tagRegexps[pattern]
+	tagRegexpsLock.RUnlock()
+	if re != nil {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	tagRegexpsLock.Lock()
+	tagRegexps[pattern] = re
+	tagRegexpsLock.Unlock()
+	return re, nil
+}
+
+// NewTagFilterFunc parse operator to gain right tag string and proper filter func.
+// Supported filters in strategy tag string:
+//
+//	key=value, key=[v1|v2]     equal to one value
+//	key!=value, key!=[v1|v2]   not equal to all values
+//	key^=value, key$=value     has prefix or suffix
+//	key*=value                 contains
+//	key=~regexp, key!~regexp   matches regexp or not
+//	key~=glob, key!~=glob      matches glob pattern or not
+func NewTagFilterFunc(key, operator string) (string, TagFilterFunc, error) {
+	if strings.HasPrefix(operator, "~") {
+		return regexpFilter(key, strings.TrimPrefix(operator, "~"))
+	}
+	if strings.HasSuffix(key, "~") {
+		return globFilter(strings.TrimSuffix(key, "~"), operator)
+	}
+	key, fn := valueFilter(key, operator)
+	return key, fn, nil
+}
+
+func regexpFilter(key, pattern string) (string, TagFilterFunc, error) {
+	re, err := compileTagRegexp(pattern)
+	if err != nil {
+		return "", nil, err
+	}
+	if strings.HasSuffix(key, "!") {
+		return strings.TrimSuffix(key, "!"), func(left string) bool {
+			return !re.MatchString(left)
+		}, nil
+	}
+	return key, re.MatchString, nil
+}
+
+func globFilter(key, pattern string) (string, TagFilterFunc, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return "", nil, err
+	}
+	if strings.HasSuffix(key, "!") {
+		return strings.TrimSuffix(key, "!"), func(left string) bool {
+			ok, _ := path.Match(pattern, left)
+			return !ok
+		}, nil
+	}
+	return key, func(left string) bool {
+		ok, _ := path.Match(pattern, left)
+		return ok
+	}, nil
+}
+
+func valueFilter(key, operator string) (string, TagFilterFunc) {
 	opList := parseOperatorToList(operator)
 	if len(opList) == 0 {
 		return singleValueFilter(key, operator)
@@ -0,0 +1,80 @@
+package judger
+
+import (
+	"testing"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTagMatcher(t *testing.T) {
+	Convey("tag.matcher", t, func() {
+		cases := []struct {
+			TagString string
+			Key       string
+			Accepts   []string
+			Rejects   []string
+		}{
+			{"iface=eth0", "iface", []string{"eth0"}, []string{"eth1", ""}},
+			{"iface=[eth0|eth1]", "iface", []string{"eth0", "eth1"}, []string{"lo"}},
+			{"iface!=lo", "iface", []string{"eth0"}, []string{"lo", ""}},
+			{"iface!=[lo|docker0]", "iface", []string{"eth0"}, []string{"lo", "docker0"}},
+			{"mount^=/data", "mount", []string{"/data1"}, []string{"/home"}},
+			{"mount$=1", "mount", []string{"/data1"}, []string{"/data2"}},
+			{"mount*=dat", "mount", []string{"/data1"}, []string{"/home"}},
+			{"mount=~^/data[0-9]+$", "mount", []string{"/data1", "/data12"}, []string{"/data", "/data1/x"}},
+			{"disk!~^loop", "disk", []string{"sda", "nvme0n1"}, []string{"loop0"}},
+			{"disk~=sd?", "disk", []string{"sda", "sdb"}, []string{"sda1", "vda"}},
+			{"disk!~=loop*", "disk", []string{"sda"}, []string{"loop0", "loop12"}},
+			{`mount=~^/d{1\,3}$`, "mount", []string{"/d", "/ddd"}, []string{"/dddd"}},
+			{`mount!~^/d{2\,}$`, "mount", []string{"/d"}, []string{"/dd", "/ddd"}},
+		}
+		for _, c := range cases {
+			tags, err := models.ExtractTagFilters(c.TagString)
+			So(err, ShouldBeNil)
+			So(tags, ShouldHaveLength, 1)
+			for k, v := range tags {
+				key, fn, err := NewTagFilterFunc(k, v)
+				So(err, ShouldBeNil)
+				So(key, ShouldEqual, c.Key)
+				for _, value := range c.Accepts {
+					So(fn(value), ShouldBeTrue)
+				}
+				for _, value := range c.Rejects {
+					So(fn(value), ShouldBeFalse)
+				}
+			}
+		}
+	})
+
+	Convey("tag.matcher.escape", t, func() {
+		tags, err := models.ExtractTagFilters(`mount=~^/d{1\,3}$,iface=[eth0|eth1],disk!~a\,b`)
+		So(err, ShouldBeNil)
+		So(tags, ShouldResemble, map[string]string{
+			"mount": "~^/d{1,3}$",
+			"iface": "[eth0|eth1]",
+			"disk!": "~a,b",
+		})
+	})
+
+	Convey("tag.matcher.invalid", t, func() {
+		_, _, err := NewTagFilterFunc("mount", "~^/data[0-9+$")
+		So(err, ShouldNotBeNil)
+		_, _, err = NewTagFilterFunc("disk~", "sd[a")
+		So(err, ShouldNotBeNil)
+
+		_, err = NewUnit(&models.Strategy{
+			ID:             1,
+			Metric:         "df",
+			FieldTransform: "select(value)",
+			Func:           "all(#2)",
+			Operator:       ">=",
+			TagString:      "mount=~(data",
+		})
+		So(err, ShouldNotBeNil)
+
+		re1, _ := compileTagRegexp("^loop")
+		re2, _ := compileTagRegexp("^loop")
+		So(re1, ShouldEqual, re2)
+	})
+}
@@ -12,13 +12,43 @@ import (
 // it checks event history to determine the event should alarm
 type Current struct {
 	records map[string]*models.Event
+	states  map[string]*eventState
 	lock    sync.RWMutex
 }
 
+// flapRefreshSteps is steps to send held FLAPPING event again,
+// it keeps alarm refreshed in eventor, otherwise it's closed as outdated
+const flapRefreshSteps = 5
+
+type eventState struct {
+	status   models.EventStatus
+	okCount  int
+	changes  []int64
+	flapping bool
+	held     int // held FLAPPING events count since last sent
+}
+
+// Hysteresis is options to hold event status changes
+type Hysteresis struct {
+	RecoverCount int   // consecutive ok count to recover from problem
+	FlapCount    int   // status changes count in window to be flapping
+	FlapWindow   int64 // flapping window in seconds
+}
+
+// HysteresisOf returns hysteresis options of strategy
+func HysteresisOf(st *models.Strategy) Hysteresis {
+	return Hysteresis{
+		RecoverCount: st.RecoverCount,
+		FlapCount:    st.FlapCount,
+		FlapWindow:   int64(st.FlapWindow),
+	}
+}
+
 // NewCurrent return new event history object
 func NewCurrent() *Current {
 	return &Current{
 		records: make(map[string]*models.Event),
+		states:  make(map[string]*eventState),
 	}
 }
 
@@ -28,11 +58,27 @@ func NewCurrent() *Current {
 // If event problem in two mod, alarm
 // If event ok in 10 mod, alarm
 func (h *Current) ShouldAlarm(event *models.Event) bool {
+	return h.ShouldAlarmWith(event, Hysteresis{})
+}
+
+// ShouldAlarmWith check event should be alarmed by history with hysteresis options.
+// Problem event keeps problem until ok count reaches RecoverCount.
+// If status changes count in FlapWindow reaches FlapCount, event is FLAPPING,
+// the FLAPPING event is alarmed once, and held until status changes count drops,
+// held FLAPPING event is sent again in every flapRefreshSteps
+func (h *Current) ShouldAlarmWith(event *models.Event, hy Hysteresis) bool {
 	if event == nil {
 		return false
 	}
 	h.lock.Lock()
 	defer h.lock.Unlock()
+	if !h.checkState(event, hy) {
+		if lastEvent := h.records[event.ID]; lastEvent != nil {
+			event.Step = lastEvent.Step + 1
+		}
+		h.records[event.ID] = event
+		return false
+	}
 	lastEvent := h.records[event.ID]
 	if lastEvent == nil {
 		h.records[event.ID] = event
@@ -47,6 +93,75 @@ func (h *Current) ShouldAlarm(event *models.Event) bool {
 	return event.Status <= 1
 }
 
+// InProblem checks event is in problem or flapping status
+func (h *Current) InProblem(eid string) bool {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	lastEvent := h.records[eid]
+	if lastEvent == nil {
+		return false
+	}
+	return lastEvent.Status == models.EventProblem || lastEvent.Status == models.EventFlapping
+}
+
+// checkState updates event state with hysteresis options, it may change event status,
+// it returns false if event should be held
+func (h *Current) checkState(event *models.Event, hy Hysteresis) bool {
+	if event.Status != models.EventOk && event.Status != models.EventProblem {
+		return true
+	}
+	if hy.RecoverCount <= 1 && (hy.FlapCount <= 0 || hy.FlapWindow <= 0) {
+		delete(h.states, event.ID)
+		return true
+	}
+	state := h.states[event.ID]
+	if state == nil {
+		state = &eventState{}
+		h.states[event.ID] = state
+	}
+	// hold problem until enough consecutive ok
+	if event.Status == models.EventOk && state.status == models.EventProblem && hy.RecoverCount > 1 {
+		state.okCount++
+		if state.okCount < hy.RecoverCount {
+			event.Status = models.EventProblem
+		} else {
+			state.okCount = 0
+		}
+	} else if event.Status == models.EventProblem {
+		state.okCount = 0
+	}
+
+	if hy.FlapCount <= 0 || hy.FlapWindow <= 0 {
+		state.status = event.Status
+		state.changes = nil
+		state.flapping = false
+		return true
+	}
+	if state.status != 0 && state.status != event.Status {
+		state.changes = append(state.changes, event.Time)
+	}
+	state.status = event.Status
+	var idx int
+	for idx < len(state.changes) && state.changes[idx] <= event.Time-hy.FlapWindow {
+		idx++
+	}
+	state.changes = state.changes[idx:]
+	if len(state.changes) >= hy.FlapCount {
+		event.Status = models.EventFlapping
+		if state.flapping {
+			state.held++
+			if state.held < flapRefreshSteps {
+				return false
+			}
+		}
+		state.flapping = true
+		state.held = 0
+		return true
+	}
+	state.flapping = false
+	return true
+}
+
 // FindByStrategy find events in records with same strategy id
 func (h *Current) FindByStrategy(id int) map[string]*models.Event {
 	m := make(map[string]*models.Event)
@@ -67,3 +182,19 @@ func (h *Current) All() map[string]*models.Event {
 	defer h.lock.RUnlock()
 	return h.records
 }
+
+func (h *Current) copy() map[string]*models.Event {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	records := make(map[string]*models.Event, len(h.records))
+	for eid, evt := range h.records {
+		records[eid] = evt
+	}
+	return records
+}
+
+func (h *Current) set(event *models.Event) {
+	h.lock.Lock()
+	h.records[event.ID] = event
+	h.lock.Unlock()
+}
@@ -0,0 +1,90 @@
+package judger
+
+import (
+	"testing"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCurrentHysteresis(t *testing.T) {
+	Convey("current.recover-count", t, func() {
+		c := NewCurrent()
+		hy := Hysteresis{RecoverCount: 3}
+		So(c.ShouldAlarmWith(&models.Event{ID: "e1", Status: models.EventProblem, Time: 1}, hy), ShouldBeTrue)
+		So(c.InProblem("e1"), ShouldBeTrue)
+
+		evt := &models.Event{ID: "e1", Status: models.EventOk, Time: 2}
+		So(c.ShouldAlarmWith(evt, hy), ShouldBeTrue)
+		So(evt.Status, ShouldEqual, models.EventProblem)
+		So(evt.Step, ShouldEqual, 1)
+
+		evt = &models.Event{ID: "e1", Status: models.EventOk, Time: 3}
+		c.ShouldAlarmWith(evt, hy)
+		So(evt.Status, ShouldEqual, models.EventProblem)
+
+		evt = &models.Event{ID: "e1", Status: models.EventOk, Time: 4}
+		So(c.ShouldAlarmWith(evt, hy), ShouldBeTrue)
+		So(evt.Status, ShouldEqual, models.EventOk)
+		So(c.InProblem("e1"), ShouldBeFalse)
+	})
+
+	Convey("current.flapping", t, func() {
+		c := NewCurrent()
+		hy := Hysteresis{FlapCount: 3, FlapWindow: 100}
+		statuses := []models.EventStatus{models.EventProblem, models.EventOk, models.EventProblem}
+		for i, s := range statuses {
+			evt := &models.Event{ID: "e2", Status: s, Time: int64(i * 10)}
+			So(c.ShouldAlarmWith(evt, hy), ShouldBeTrue)
+			So(evt.Status, ShouldEqual, s)
+		}
+
+		evt := &models.Event{ID: "e2", Status: models.EventOk, Time: 30}
+		So(c.ShouldAlarmWith(evt, hy), ShouldBeTrue)
+		So(evt.Status, ShouldEqual, models.EventFlapping)
+		So(c.InProblem("e2"), ShouldBeTrue)
+
+		for i := 1; i < flapRefreshSteps; i++ {
+			evt = &models.Event{ID: "e2", Status: models.EventProblem, Time: int64(30 + i*10)}
+			So(c.ShouldAlarmWith(evt, hy), ShouldBeFalse)
+			So(evt.Status, ShouldEqual, models.EventFlapping)
+		}
+		// held FLAPPING event is sent again to refresh alarm
+		evt = &models.Event{ID: "e2", Status: models.EventProblem, Time: int64(30 + flapRefreshSteps*10)}
+		So(c.ShouldAlarmWith(evt, hy), ShouldBeTrue)
+		So(evt.Status, ShouldEqual, models.EventFlapping)
+		evt = &models.Event{ID: "e2", Status: models.EventProblem, Time: int64(40 + flapRefreshSteps*10)}
+		So(c.ShouldAlarmWith(evt, hy), ShouldBeFalse)
+
+		evt = &models.Event{ID: "e2", Status: models.EventProblem, Time: 200}
+		So(c.ShouldAlarmWith(evt, hy), ShouldBeTrue)
+		So(evt.Status, ShouldEqual, models.EventProblem)
+	})
+
+	Convey("current.recover-value", t, func() {
+		rv := 50.0
+		unit, err := NewUnit(&models.Strategy{
+			ID:             1,
+			Metric:         "cpu",
+			FieldTransform: "select(value)",
+			Func:           "all(#1)",
+			Operator:       ">=",
+			RightValue:     80,
+			RecoverValue:   &rv,
+		})
+		So(err, ShouldBeNil)
+
+		metric := &models.Metric{Name: "cpu", Value: 90, Time: 1}
+		_, status, _ := unit.Check(metric, "h1")
+		So(status, ShouldEqual, models.EventProblem)
+
+		metric = &models.Metric{Name: "cpu", Value: 60, Time: 2}
+		_, status, _ = unit.Check(metric, "h1")
+		So(status, ShouldEqual, models.EventOk)
+		So(unit.IsRecovered("h1"), ShouldBeFalse)
+
+		metric = &models.Metric{Name: "cpu", Value: 40, Time: 3}
+		unit.Check(metric, "h1")
+		So(unit.IsRecovered("h1"), ShouldBeTrue)
+	})
+}
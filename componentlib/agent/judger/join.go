@@ -0,0 +1,208 @@
+package judger
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/baishancloud/mallard/corelib/models"
+)
+
+var (
+	// ErrJoinInvalidArguments is error of join arguments
+	ErrJoinInvalidArguments = errors.New("join-args-error")
+)
+
+// Join is operator for join(), it combines latest samples of strategy metric and another metric
+// from same endpoint and join tags within tolerance seconds,
+// then evaluates arithmetic expression with fields prefixed by "a." and "b.":
+//
+//	join(metric_b, expression, tag1|tag2, tolerance)
+//
+// such as join(nginx.requests, a.value/b.value*100, host, 30),
+// tag filters of strategy are only applied to strategy metric
+type Join struct {
+	base        *StrategyBase
+	compareFn   CompareFunc
+	calculateFn CalculateFunc
+	expr        arithNode
+
+	joinMetric string
+	joinTags   []string
+	tolerance  int64
+
+	samples   map[string]*joinSample
+	lastSweep int64
+	lock      sync.Mutex
+}
+
+type joinSample struct {
+	a, b *models.Metric
+}
+
+// NewJoinFromStrategy return join operator with strategy
+func NewJoinFromStrategy(st *models.Strategy) (*Join, error) {
+	var (
+		s = Join{
+			samples: make(map[string]*joinSample),
+		}
+		err error
+	)
+	s.base, err = NewStrategyBase(st)
+	if err != nil {
+		return nil, err
+	}
+	s.calculateFn = NewCalculateFunc(s.base.CalType)
+	if s.calculateFn == nil {
+		return nil, ErrUnknownCalcuateFunc
+	}
+	s.compareFn = NewCompareFunc(st.Operator)
+	if s.compareFn == nil {
+		return nil, ErrUnknownCompareFunc
+	}
+	if !strings.HasSuffix(st.FieldTransform, ")") {
+		return nil, ErrJoinInvalidArguments
+	}
+	args := strings.Split(st.FieldTransform[len("join("):len(st.FieldTransform)-1], ",")
+	if len(args) < 4 {
+		return nil, ErrJoinInvalidArguments
+	}
+	s.joinMetric = strings.TrimSpace(args[0])
+	if s.joinMetric == "" || s.joinMetric == st.Metric {
+		return nil, ErrJoinInvalidArguments
+	}
+	for _, tag := range strings.Split(args[len(args)-2], "|") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			s.joinTags = append(s.joinTags, tag)
+		}
+	}
+	if s.tolerance, err = strconv.ParseInt(strings.TrimSpace(args[len(args)-1]), 10, 64); err != nil || s.tolerance <= 0 {
+		return nil, ErrJoinInvalidArguments
+	}
+	s.base.Field = strings.TrimSpace(strings.Join(args[1:len(args)-2], ","))
+	tokens, err := arithTokenize(s.base.Field)
+	if err != nil {
+		return nil, err
+	}
+	p := &arithParser{tokens: tokens}
+	if s.expr, err = p.parse(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// JoinMetric returns metric name joined to strategy metric
+func (s *Join) JoinMetric() string {
+	return s.joinMetric
+}
+
+// Join buffers metric sample with join key,
+// if both samples are found within tolerance, returns joined metric and clears buffered samples
+func (s *Join) Join(metric *models.Metric) (*models.Metric, bool) {
+	fullTags := metric.FullTags()
+	key := metric.Endpoint
+	for _, tag := range s.joinTags {
+		key += "|" + fullTags[tag]
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sweep(metric.Time)
+	sample := s.samples[key]
+	if sample == nil {
+		sample = &joinSample{}
+		s.samples[key] = sample
+	}
+	if metric.Name == s.joinMetric {
+		sample.b = metric
+	} else {
+		sample.a = metric
+	}
+	if sample.a == nil || sample.b == nil {
+		return nil, false
+	}
+	diff := sample.a.Time - sample.b.Time
+	if diff > s.tolerance || diff < -s.tolerance {
+		return nil, false
+	}
+	delete(s.samples, key)
+	return s.joined(sample.a, sample.b), true
+}
+
+func (s *Join) joined(a, b *models.Metric) *models.Metric {
+	m := &models.Metric{
+		Name:     a.Name,
+		Endpoint: a.Endpoint,
+		Value:    a.Value,
+		Time:     a.Time,
+		Step:     a.Step,
+		Tags:     make(map[string]string, len(s.joinTags)),
+		Fields:   make(map[string]interface{}, len(a.Fields)+len(b.Fields)+2),
+	}
+	if b.Time > m.Time {
+		m.Time = b.Time
+	}
+	for _, tag := range s.joinTags {
+		if v, ok := a.Tags[tag]; ok {
+			m.Tags[tag] = v
+		}
+	}
+	for k, v := range a.Fields {
+		m.Fields["a."+k] = v
+	}
+	for k, v := range b.Fields {
+		m.Fields["b."+k] = v
+	}
+	m.Fields["a.value"] = a.Value
+	m.Fields["b.value"] = b.Value
+	return m
+}
+
+// sweep removes samples older than twice of tolerance, it runs once in tolerance duration
+func (s *Join) sweep(now int64) {
+	if now-s.lastSweep < s.tolerance {
+		return
+	}
+	s.lastSweep = now
+	for key, sample := range s.samples {
+		latest := int64(0)
+		if sample.a != nil {
+			latest = sample.a.Time
+		}
+		if sample.b != nil && sample.b.Time > latest {
+			latest = sample.b.Time
+		}
+		if now-latest > s.tolerance*2 {
+			delete(s.samples, key)
+		}
+	}
+}
+
+// Limit return data queue size
+func (s *Join) Limit() int {
+	return s.base.Limit
+}
+
+// Transform evaluates expression with joined metric
+func (s *Join) Transform(metric *models.Metric) (float64, error) {
+	return evalArith(s.expr, metric)
+}
+
+// Trigger check history data with strategy
+func (s *Join) Trigger(historyList []*models.EventValue) (float64, bool, error) {
+	return s.calculateFn(historyList, s.base.RightValue, s.compareFn, s.base.Args...)
+}
+
+// Tags return strategy tags condition
+func (s *Join) Tags() map[string]TagFilterFunc {
+	if s.base == nil {
+		return nil
+	}
+	return s.base.Tags
+}
+
+// Base return strategy base info
+func (s *Join) Base() *StrategyBase {
+	return s.base
+}
@@ -0,0 +1,90 @@
+package judger
+
+import (
+	"testing"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJoin(t *testing.T) {
+	Convey("join.invalid", t, func() {
+		for _, transform := range []string{
+			"join(nginx.requests, a.value/b.value, host)",
+			"join(nginx.5xx, a.value/b.value, host, 30)",
+			"join(nginx.requests, a.value/b.value, host, 0)",
+			"join(nginx.requests, a.value/, host, 30)",
+		} {
+			_, err := NewUnit(&models.Strategy{
+				ID:             1,
+				Metric:         "nginx.5xx",
+				FieldTransform: transform,
+				Func:           "all(#1)",
+				Operator:       ">",
+				RightValue:     2,
+			})
+			So(err, ShouldNotBeNil)
+		}
+	})
+
+	Convey("join.judge", t, func() {
+		SetStrategyData(nil)
+		eventCurrent = NewCurrent()
+		st := &models.Strategy{
+			ID:             1,
+			Metric:         "nginx.5xx",
+			FieldTransform: "join(nginx.requests, a.value/b.value*100, host, 30)",
+			Func:           "all(#1)",
+			Operator:       ">",
+			RightValue:     2,
+			TagString:      "code=5xx",
+		}
+		So(SetStrategyData([]*models.Strategy{st}), ShouldBeEmpty)
+		So(units[1].Metrics(), ShouldResemble, []string{"nginx.5xx", "nginx.requests"})
+
+		errMetric := &models.Metric{
+			Name:     "nginx.5xx",
+			Endpoint: "ep1",
+			Value:    5,
+			Time:     100,
+			Tags:     map[string]string{"host": "a.com", "code": "5xx"},
+			Fields:   map[string]interface{}{"slow": 1},
+		}
+		totalMetric := &models.Metric{
+			Name:     "nginx.requests",
+			Endpoint: "ep1",
+			Value:    100,
+			Time:     110,
+			Tags:     map[string]string{"host": "a.com"},
+		}
+		So(Judge([]*models.Metric{errMetric}), ShouldBeEmpty)
+
+		otherHost := *totalMetric
+		otherHost.Tags = map[string]string{"host": "b.com"}
+		So(Judge([]*models.Metric{&otherHost}), ShouldBeEmpty)
+
+		events := Judge([]*models.Metric{totalMetric})
+		So(events, ShouldHaveLength, 1)
+		So(events[0].Status, ShouldEqual, models.EventProblem)
+		So(events[0].LeftValue, ShouldEqual, 5)
+		So(events[0].Time, ShouldEqual, 110)
+		So(events[0].Tags, ShouldResemble, map[string]string{"host": "a.com"})
+		So(events[0].Fields["a.value"], ShouldEqual, 5)
+		So(events[0].Fields["b.value"], ShouldEqual, 100)
+		So(events[0].Fields["a.slow"], ShouldEqual, 1)
+
+		// samples are out of tolerance
+		errMetric2 := *errMetric
+		errMetric2.Value, errMetric2.Time = 1, 160
+		totalMetric2 := *totalMetric
+		totalMetric2.Time = 200
+		So(Judge([]*models.Metric{&errMetric2, &totalMetric2}), ShouldBeEmpty)
+
+		errMetric2.Time = 210
+		events = Judge([]*models.Metric{&errMetric2})
+		So(events, ShouldHaveLength, 1)
+		So(events[0].Status, ShouldEqual, models.EventOk)
+		SetStrategyData(nil)
+		eventCurrent = NewCurrent()
+	})
+}
@@ -17,29 +17,50 @@ var (
 	unitsLock   sync.RWMutex
 	log         = zaplog.Zap("judger")
 
-	eventCurrent = NewCurrent()
+	eventCurrent   = NewCurrent()
+	syntaxReported = make(map[int]bool)
 
-	strategyCount     = expvar.NewBase("event.strategy")
-	eventOKCount      = expvar.NewDiff("events.ok")
-	eventProblemCount = expvar.NewDiff("events.problem")
+	strategyCount      = expvar.NewBase("event.strategy")
+	eventOKCount       = expvar.NewDiff("events.ok")
+	eventProblemCount  = expvar.NewDiff("events.problem")
+	eventFlappingCount = expvar.NewDiff("events.flapping")
 )
 
 func init() {
-	expvar.Register(strategyCount, eventOKCount, eventProblemCount)
+	expvar.Register(strategyCount, eventOKCount, eventProblemCount, eventFlappingCount)
 }
 
 // SetStrategyData sets strategies data
-// if some unit closed, return closed events
+// if some unit closed, return closed events,
+// if some strategy is wrong syntax, return syntax events that are not reported before
 func SetStrategyData(ss []*models.Strategy) []*models.Event {
 	strategies := make(map[int]*models.Strategy, len(ss))
 	for _, s := range ss {
+		if s.IsBaseline() { // baseline strategies are judged in judge service
+			continue
+		}
 		strategies[s.ID] = s
 	}
 
 	unitsLock.Lock()
 	defer unitsLock.Unlock()
 
-	var closedIDs []int
+	var (
+		closedIDs    []int
+		syntaxEvents []*models.Event
+	)
+	reportSyntax := func(s *models.Strategy, err error) {
+		if syntaxReported[s.ID] {
+			return
+		}
+		syntaxEvents = append(syntaxEvents, genSyntaxEvent(s, err))
+		syntaxReported[s.ID] = true
+	}
+	for id := range syntaxReported {
+		if strategies[id] == nil {
+			delete(syntaxReported, id)
+		}
+	}
 
 	// closed no-using unit
 	before := len(units)
@@ -60,19 +81,30 @@ func SetStrategyData(ss []*models.Strategy) []*models.Event {
 		u, ok := units[key]
 		if ok {
 			if err := u.SetStrategy(s); err != nil {
+				// old unit can't judge with new strategy, close it as removed
 				log.Warn("unit-reload-error", "error", err, "id", u.ID())
+				delete(units, key)
+				closedIDs = append(closedIDs, u.ID())
+				reportSyntax(s, err)
 			} else {
-				accepts[s.Metric] = append(accepts[s.Metric], s.ID)
+				delete(syntaxReported, s.ID)
+				for _, m := range u.Metrics() {
+					accepts[m] = append(accepts[m], s.ID)
+				}
 			}
 		} else {
 			var err error
 			u, err = NewUnit(s)
 			if err == nil {
 				units[key] = u
-				accepts[s.Metric] = append(accepts[s.Metric], s.ID)
+				delete(syntaxReported, s.ID)
+				for _, m := range u.Metrics() {
+					accepts[m] = append(accepts[m], s.ID)
+				}
 				// log.Debug("unit-new", "id", u.ID())
 			} else {
 				log.Warn("unit-new-error", "error", err, "s", s)
+				reportSyntax(s, err)
 			}
 		}
 	}
@@ -80,11 +112,26 @@ func SetStrategyData(ss []*models.Strategy) []*models.Event {
 		sort.Sort(sort.IntSlice(accepts[k]))
 	}
 	unitsAccept = accepts
+	restoreSnapshot()
 
 	log.Debug("set-strategy", "before", before, "after", len(units))
 	strategyCount.Set(int64(len(units)))
 
-	return genClosedEvents(closedIDs)
+	return append(genClosedEvents(closedIDs), syntaxEvents...)
+}
+
+func genSyntaxEvent(s *models.Strategy, err error) *models.Event {
+	now := time.Now().Unix()
+	return &models.Event{
+		ID:       fmt.Sprintf("s_%d_syntax", s.ID),
+		Status:   models.EventSyntax,
+		Time:     now,
+		Strategy: s.ID,
+		Fields: map[string]interface{}{
+			"error": err.Error(),
+		},
+		CreateTime: now,
+	}
 }
 
 func genClosedEvents(closed []int) []*models.Event {
@@ -138,62 +185,81 @@ func judgeOnce(metric *models.Metric) []*models.Event {
 		if !u.Accept(metric) {
 			continue
 		}
-		leftValue, status, err := u.Check(metric, "")
-		if _, ok := err.(FieldMissingError); ok {
-			log.Debug("strategy-field-miss", "sid", u.ID(), "msg", err)
+		m, ok := u.Join(metric)
+		if !ok {
 			continue
 		}
-		if status == models.EventIgnore {
-			if err != nil {
-				log.Debug("strategy-check-fail", "sid", u.ID(), "error", err)
-			}
+		event, alarm := JudgeUnit(u, m, eventCurrent, now)
+		if !alarm {
 			continue
 		}
-		/*if status == models.EventSyntax && err != nil {
-			log.Debug("strategy-check-fail", "sid", u.ID(), "error", err)
-			continue
-		}*/
-
-		event := &models.Event{
-			ID:         fmt.Sprintf("s_%d_%s", u.ID(), metric.Hash()),
-			Status:     status,
-			Time:       metric.Time,
-			Strategy:   u.ID(),
-			LeftValue:  leftValue,
-			History:    u.History(metric.Hash()),
-			Tags:       metric.Tags,
-			Cycle:      metric.Step,
-			Fields:     metric.Fields,
-			Endpoint:   metric.Endpoint,
-			CreateTime: now,
-		}
-		if len(event.Fields) > 0 {
-			if _, ok := event.Fields["value"]; !ok {
-				event.Fields["value"] = metric.Value
+		if event.Status == models.EventOk {
+			eventOKCount.Incr(1)
+			if event.Step > 3 {
+				event = event.Simple()
 			}
 		}
-
-		if eventCurrent.ShouldAlarm(event) {
-			if event.Status == models.EventOk {
-				eventOKCount.Incr(1)
-				if event.Step > 3 {
-					event = event.Simple()
-				}
-			}
-			if event.Status == models.EventProblem {
-				eventProblemCount.Incr(1)
-				log.Info("problem-event", "event", event)
-			}
-			events = append(events, event)
-			/*log.Info("event",
-			"s", event.Status.String(),
-			"eid", event.ID,
-			"step", event.Step)*/
+		if event.Status == models.EventProblem {
+			eventProblemCount.Incr(1)
+			log.Info("problem-event", "event", event)
+		}
+		if event.Status == models.EventFlapping {
+			eventFlappingCount.Incr(1)
+			log.Info("flapping-event", "event", event)
 		}
+		events = append(events, event)
+		/*log.Info("event",
+		"s", event.Status.String(),
+		"eid", event.ID,
+		"step", event.Step)*/
 	}
 	return events
 }
 
+// JudgeUnit checks metric joined by unit, and applies running windows, recover value and hysteresis
+// of the strategy with current events. Metric out of running windows still feeds unit history,
+// but only keeps judging problem events to resolve them.
+// It returns the event and whether it should be alarmed, event is nil if metric is ignored
+func JudgeUnit(u *StrategyUnit, m *models.Metric, current *Current, now int64) (*models.Event, bool) {
+	eventID := fmt.Sprintf("s_%d_%s", u.ID(), m.Hash())
+	leftValue, status, err := u.Check(m, "")
+	if _, ok := err.(FieldMissingError); ok {
+		log.Debug("strategy-field-miss", "sid", u.ID(), "msg", err)
+		return nil, false
+	}
+	if status == models.EventIgnore {
+		if err != nil {
+			log.Debug("strategy-check-fail", "sid", u.ID(), "error", err)
+		}
+		return nil, false
+	}
+	if !u.GetStrategy().IsInTime(m.Time) && !current.InProblem(eventID) {
+		return nil, false
+	}
+	if status == models.EventOk && current.InProblem(eventID) && !u.IsRecovered(m.Hash()) {
+		status = models.EventProblem // not cross recover value
+	}
+	event := &models.Event{
+		ID:         eventID,
+		Status:     status,
+		Time:       m.Time,
+		Strategy:   u.ID(),
+		LeftValue:  leftValue,
+		History:    u.History(m.Hash()),
+		Tags:       m.Tags,
+		Cycle:      m.Step,
+		Fields:     m.Fields,
+		Endpoint:   m.Endpoint,
+		CreateTime: now,
+	}
+	if len(event.Fields) > 0 {
+		if _, ok := event.Fields["value"]; !ok {
+			event.Fields["value"] = m.Value
+		}
+	}
+	return event, current.ShouldAlarmWith(event, HysteresisOf(u.GetStrategy()))
+}
+
 // Currents returns current events in manager
 func Currents() *Current {
 	return eventCurrent
@@ -58,7 +58,9 @@ func TestJudger(t *testing.T) {
 			},
 		}
 		events := SetStrategyData(ss)
-		So(events, ShouldHaveLength, 0)
+		So(events, ShouldHaveLength, 1) // strategy 9 is wrong syntax
+		So(events[0].Status, ShouldEqual, models.EventSyntax)
+		So(events[0].Strategy, ShouldEqual, 9)
 		So(unitsAccept["cpu"], ShouldHaveLength, 4)
 
 		metrics := []*models.Metric{
@@ -154,5 +156,76 @@ func TestJudger(t *testing.T) {
 			So(events, ShouldHaveLength, 1)
 			So(events[0].Status, ShouldEqual, models.EventClosed)
 		})
+
+	})
+
+	Convey("judge.reload.error", t, func() {
+		SetStrategyData(nil)
+		ss := []*models.Strategy{
+			{
+				ID:             1,
+				Metric:         "cpu",
+				FieldTransform: "select(value)",
+				Func:           "all(#1)",
+				Operator:       ">=",
+				RightValue:     1,
+			},
+			{
+				ID:             9,
+				Metric:         "cpu",
+				FieldTransform: "select(value)",
+				Func:           "xyz(#2)",
+				Operator:       "<=",
+				RightValue:     2,
+			},
+		}
+		So(SetStrategyData(ss), ShouldHaveLength, 1)
+		So(SetStrategyData(ss), ShouldBeEmpty) // syntax of strategy 9 is reported before
+
+		events := Judge([]*models.Metric{{Name: "cpu", Time: 1, Value: 2, Endpoint: "localhost"}})
+		So(events, ShouldHaveLength, 1)
+		So(events[0].Status, ShouldEqual, models.EventProblem)
+
+		ss[0] = &models.Strategy{
+			ID:             1,
+			Metric:         "cpu",
+			FieldTransform: "select(value)",
+			Func:           "xyz(#1)",
+			Operator:       ">=",
+			RightValue:     1,
+		}
+		events = SetStrategyData(ss)
+		So(events, ShouldHaveLength, 2)
+		So(events[0].Status, ShouldEqual, models.EventClosed)
+		So(events[0].Strategy, ShouldEqual, 1)
+		So(events[1].Status, ShouldEqual, models.EventSyntax)
+		So(events[1].Strategy, ShouldEqual, 1)
+		So(units[1], ShouldBeNil)
+		So(unitsAccept["cpu"], ShouldBeEmpty)
+
+		So(SetStrategyData(ss), ShouldBeEmpty)
+		SetStrategyData(nil)
+	})
+
+	Convey("judge.schedule", t, func() {
+		SetStrategyData(nil)
+		SetStrategyData([]*models.Strategy{
+			{
+				ID:             1,
+				Metric:         "cpu",
+				FieldTransform: "select(value)",
+				Func:           "all(#2)",
+				Operator:       ">=",
+				RightValue:     1,
+				Schedule:       "* 00:01-00:03",
+				Timezone:       "UTC",
+			},
+		})
+		// out of running windows, it feeds history without event
+		So(Judge([]*models.Metric{{Name: "cpu", Time: 0, Value: 2, Endpoint: "localhost"}}), ShouldBeEmpty)
+		events := Judge([]*models.Metric{{Name: "cpu", Time: 60, Value: 2, Endpoint: "localhost"}})
+		So(events, ShouldHaveLength, 1)
+		So(events[0].Status, ShouldEqual, models.EventProblem)
+		SetStrategyData(nil)
 	})
 }
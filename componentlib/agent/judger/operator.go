@@ -31,6 +31,14 @@ var (
 // FromStrategy return operator with strategy
 func FromStrategy(s *models.Strategy) (Operator, error) {
 	if strings.HasPrefix(s.FieldTransform, "select(") {
+		if IsArithTransform(s.FieldTransform) {
+			op, err := NewArithFromStrategy(s)
+			if err == nil {
+				return op, nil
+			}
+			// field names with operator characters, such as http-5xx, are selected as plain field
+			log.Debug("arith-fallback-select", "sid", s.ID, "transform", s.FieldTransform, "error", err)
+		}
 		return NewSelectFromStrategy(s)
 	}
 	if strings.HasPrefix(s.FieldTransform, "rangeselect(") {
@@ -42,6 +50,9 @@ func FromStrategy(s *models.Strategy) (Operator, error) {
 	if strings.HasPrefix(s.FieldTransform, "rangeor(") {
 		return NewRangeXORFromStrategy(s, true)
 	}
+	if strings.HasPrefix(s.FieldTransform, "join(") {
+		return NewJoinFromStrategy(s)
+	}
 	return nil, ErrUnknownTransform
 }
 
@@ -78,12 +89,15 @@ func NewStrategyBase(st *models.Strategy) (*StrategyBase, error) {
 	stb.Limit = limit
 	stb.Tags = make(map[string]TagFilterFunc)
 	rawTags := make(map[string]string)
-	rawTags, err = models.ExtractTags(st.TagString)
+	rawTags, err = models.ExtractTagFilters(st.TagString)
 	if err != nil {
 		return nil, err
 	}
 	for k, v := range rawTags {
-		key, fn := NewTagFilterFunc(k, v)
+		key, fn, err := NewTagFilterFunc(k, v)
+		if err != nil {
+			return nil, fmt.Errorf("bad tag filter %s : %s", k, err.Error())
+		}
 		stb.Tags[key] = fn
 	}
 	stb.CalType = callStrings[0]
@@ -110,12 +124,15 @@ func parseStrategyThree(st *models.Strategy, callStrings []string) (*StrategyBas
 
 	stb.Tags = make(map[string]TagFilterFunc)
 	rawTags := make(map[string]string)
-	rawTags, err = models.ExtractTags(st.TagString)
+	rawTags, err = models.ExtractTagFilters(st.TagString)
 	if err != nil {
 		return nil, err
 	}
 	for k, v := range rawTags {
-		key, fn := NewTagFilterFunc(k, v)
+		key, fn, err := NewTagFilterFunc(k, v)
+		if err != nil {
+			return nil, fmt.Errorf("bad tag filter %s : %s", k, err.Error())
+		}
 		stb.Tags[key] = fn
 	}
 	stb.CalType = callStrings[0]
@@ -173,12 +190,17 @@ func (s *Select) Limit() int {
 
 // Transform return field value from metric by operator
 func (s *Select) Transform(metric *models.Metric) (float64, error) {
-	if s.base.Field == "value" {
+	return selectField(metric, s.base.Field)
+}
+
+// selectField returns metric value for "value" or the value of field
+func selectField(metric *models.Metric, field string) (float64, error) {
+	if field == "value" {
 		return metric.Value, nil
 	}
-	fieldv, ok := metric.Fields[s.base.Field]
+	fieldv, ok := metric.Fields[field]
 	if !ok {
-		return 0, FieldMissingError{error: fmt.Errorf("field '%s' is not found", s.base.Field)}
+		return 0, FieldMissingError{error: fmt.Errorf("field '%s' is not found", field)}
 	}
 	return utils.ToFloat64(fieldv)
 }
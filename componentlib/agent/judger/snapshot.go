@@ -0,0 +1,167 @@
+package judger
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/corelib/utils"
+)
+
+type (
+	unitSnapshot struct {
+		Hash   string                          `json:"hash"`
+		Queues map[string][]*models.EventValue `json:"queues,omitempty"`
+	}
+	snapshot struct {
+		Time   int64                    `json:"time"`
+		Units  map[int]*unitSnapshot    `json:"units,omitempty"`
+		Events map[string]*models.Event `json:"events,omitempty"`
+	}
+)
+
+const (
+	// snapshotMaxSteps is max steps of snapshot age, older history is not continuous to new values
+	snapshotMaxSteps = 3
+	snapshotStep     = 60 // default step if strategy step is not set
+)
+
+var (
+	snapshotFile    string
+	pendingSnapshot *snapshot
+	snapshotLock    sync.Mutex
+)
+
+// StrategyHash returns hash of strategy fields that affect judging result,
+// history in snapshot is restored only when strategy hash is not changed
+func StrategyHash(st *models.Strategy) string {
+	b, _ := json.Marshal(st.ToSimple())
+	return utils.MD5HashBytes(b)
+}
+
+// isSnapshotFresh checks snapshot unit is not older than max steps of strategy
+func isSnapshotFresh(st *models.Strategy, age int64) bool {
+	step := st.Step
+	if step <= 0 {
+		step = snapshotStep
+	}
+	return age <= int64(step*snapshotMaxSteps)
+}
+
+// SetSnapshotFile sets file to dump units history and current events,
+// it reads existing snapshot, and restores it when strategies are set at first time
+func SetSnapshotFile(file string) {
+	snapshotFile = file
+	if file == "" {
+		return
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("read-snapshot-error", "error", err, "file", file)
+		}
+		return
+	}
+	snap := new(snapshot)
+	if err = json.Unmarshal(b, snap); err != nil {
+		log.Warn("decode-snapshot-error", "error", err, "file", file)
+		return
+	}
+	snapshotLock.Lock()
+	pendingSnapshot = snap
+	snapshotLock.Unlock()
+	log.Info("read-snapshot", "file", file, "units", len(snap.Units), "events", len(snap.Events), "time", snap.Time)
+}
+
+// restoreSnapshot restores pending snapshot to units that strategy hash is not changed
+// and snapshot is not too old, it must be called with units lock
+func restoreSnapshot() {
+	snapshotLock.Lock()
+	snap := pendingSnapshot
+	pendingSnapshot = nil
+	snapshotLock.Unlock()
+	if snap == nil {
+		return
+	}
+	var (
+		unitCount, eventCount int
+		age                   = time.Now().Unix() - snap.Time
+		restorable            = func(id int) bool {
+			unit, us := units[id], snap.Units[id]
+			if unit == nil || us == nil {
+				return false
+			}
+			st := unit.GetStrategy()
+			return StrategyHash(st) == us.Hash && isSnapshotFresh(st, age)
+		}
+	)
+	for id, us := range snap.Units {
+		if !restorable(id) {
+			continue
+		}
+		units[id].setQueues(us.Queues)
+		unitCount++
+	}
+	for _, evt := range snap.Events {
+		if !restorable(evt.Strategy) {
+			continue
+		}
+		eventCurrent.set(evt)
+		eventCount++
+	}
+	log.Info("restore-snapshot", "units", unitCount, "events", eventCount, "age", age)
+}
+
+// DumpSnapshot dumps units history and current events to snapshot file,
+// it skips dumping before strategies are set, so snapshot not restored yet is not overwritten by empty units
+func DumpSnapshot() error {
+	if snapshotFile == "" {
+		return nil
+	}
+	snapshotLock.Lock()
+	pending := pendingSnapshot != nil
+	snapshotLock.Unlock()
+	if pending {
+		return nil
+	}
+	snap := &snapshot{
+		Time:  time.Now().Unix(),
+		Units: make(map[int]*unitSnapshot),
+	}
+	unitsLock.RLock()
+	for id, unit := range units {
+		snap.Units[id] = &unitSnapshot{
+			Hash:   StrategyHash(unit.GetStrategy()),
+			Queues: unit.queues(),
+		}
+	}
+	unitsLock.RUnlock()
+	snap.Events = eventCurrent.copy()
+
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(filepath.Dir(snapshotFile), os.ModePerm)
+	tmpFile := snapshotFile + ".tmp"
+	if err = ioutil.WriteFile(tmpFile, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, snapshotFile)
+}
+
+// DumpSnapshotInterval dumps snapshot in interval
+func DumpSnapshotInterval(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		if err := DumpSnapshot(); err != nil {
+			log.Warn("dump-snapshot-error", "error", err, "file", snapshotFile)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package judger
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSnapshot(t *testing.T) {
+	Convey("snapshot", t, func() {
+		file := "./tests_snapshot.json"
+		defer os.RemoveAll(file)
+		defer SetSnapshotFile("")
+
+		newStrategy := func() *models.Strategy {
+			return &models.Strategy{
+				ID:             101,
+				Metric:         "mem",
+				FieldTransform: "select(value)",
+				Func:           "all(#2)",
+				Operator:       ">=",
+				RightValue:     1,
+			}
+		}
+		ss := []*models.Strategy{newStrategy()}
+		SetStrategyData(nil)
+		eventCurrent = NewCurrent()
+		SetStrategyData(ss)
+		metric := &models.Metric{Name: "mem", Value: 5, Endpoint: "localhost", Time: 100}
+		So(Judge([]*models.Metric{metric}), ShouldHaveLength, 0)
+		metric2 := &models.Metric{Name: "mem", Value: 6, Endpoint: "localhost", Time: 200}
+		events := Judge([]*models.Metric{metric2})
+		So(events, ShouldHaveLength, 1)
+		So(events[0].Status, ShouldEqual, models.EventProblem)
+
+		SetSnapshotFile(file)
+		So(DumpSnapshot(), ShouldBeNil)
+
+		Convey("snapshot.restore", func() {
+			SetStrategyData(nil)
+			eventCurrent = NewCurrent()
+			SetSnapshotFile(file)
+			SetStrategyData(ss)
+			So(units[101].History(metric.Hash()), ShouldHaveLength, 2)
+
+			metric3 := &models.Metric{Name: "mem", Value: 7, Endpoint: "localhost", Time: 300}
+			events := Judge([]*models.Metric{metric3})
+			So(events, ShouldHaveLength, 1)
+			So(events[0].Step, ShouldEqual, 1)
+		})
+
+		Convey("snapshot.before.strategies", func() {
+			SetStrategyData(nil)
+			eventCurrent = NewCurrent()
+			SetSnapshotFile(file)
+			data, _ := ioutil.ReadFile(file)
+			So(DumpSnapshot(), ShouldBeNil)
+			data2, _ := ioutil.ReadFile(file)
+			So(string(data2), ShouldEqual, string(data))
+
+			SetStrategyData(ss)
+			So(units[101].History(metric.Hash()), ShouldHaveLength, 2)
+		})
+
+		Convey("snapshot.changed", func() {
+			SetStrategyData(nil)
+			eventCurrent = NewCurrent()
+			SetSnapshotFile(file)
+			changed := newStrategy()
+			changed.RightValue = 2
+			SetStrategyData([]*models.Strategy{changed})
+			So(units[101].History(metric.Hash()), ShouldHaveLength, 0)
+			So(eventCurrent.All(), ShouldHaveLength, 0)
+		})
+
+		Convey("snapshot.changed.recover", func() {
+			SetStrategyData(nil)
+			eventCurrent = NewCurrent()
+			SetSnapshotFile(file)
+			changed := newStrategy()
+			changed.RecoverCount = 2
+			SetStrategyData([]*models.Strategy{changed})
+			So(units[101].History(metric.Hash()), ShouldHaveLength, 0)
+		})
+
+		Convey("snapshot.old", func() {
+			SetStrategyData(nil)
+			eventCurrent = NewCurrent()
+			SetSnapshotFile(file)
+			snapshotLock.Lock()
+			pendingSnapshot.Time -= snapshotStep * (snapshotMaxSteps + 1)
+			snapshotLock.Unlock()
+			SetStrategyData(ss)
+			So(units[101].History(metric.Hash()), ShouldHaveLength, 0)
+			So(eventCurrent.All(), ShouldHaveLength, 0)
+		})
+	})
+}
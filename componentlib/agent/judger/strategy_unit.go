@@ -10,8 +10,9 @@ import (
 
 // StrategyUnit is a unit to check strategy in judge
 type StrategyUnit struct {
-	st *models.Strategy
-	op Operator
+	st        *models.Strategy
+	op        Operator
+	recoverOp Operator
 
 	dataQueue map[string][]*models.EventValue
 	lock      sync.RWMutex
@@ -38,12 +39,24 @@ func (s *StrategyUnit) SetStrategy(st *models.Strategy) error {
 	if st == nil {
 		return errors.New("nil")
 	}
+	if err := st.CheckSchedule(); err != nil {
+		return fmt.Errorf("strategy-%d-schedule-error-%s", st.ID, err.Error())
+	}
 	op, err := FromStrategy(st)
 	if err != nil {
 		return fmt.Errorf("strategy-%d-parse-error-%s", st.ID, err.Error())
 	}
+	var recoverOp Operator
+	if st.RecoverValue != nil {
+		recoverSt := *st
+		recoverSt.RightValue = *st.RecoverValue
+		if recoverOp, err = FromStrategy(&recoverSt); err != nil {
+			return fmt.Errorf("strategy-%d-parse-recover-error-%s", st.ID, err.Error())
+		}
+	}
 	s.st = st
 	s.op = op
+	s.recoverOp = recoverOp
 	return nil
 }
 
@@ -113,11 +126,28 @@ func (s *StrategyUnit) Check(metric *models.Metric, customHash string) (float64,
 	return leftValue, models.EventOk, nil
 }
 
+// IsRecovered checks history values are recovered with recover value of the strategy,
+// it's used to keep problem status until the value crosses recover value
+func (s *StrategyUnit) IsRecovered(hash string) bool {
+	if s.recoverOp == nil {
+		return true
+	}
+	queue := s.History(hash)
+	if len(queue) < s.recoverOp.Limit() {
+		return true
+	}
+	_, isProblem, err := s.recoverOp.Trigger(queue[:s.recoverOp.Limit()])
+	return err != nil || !isProblem
+}
+
 // Accept check metric name is suited for this unit
 func (s *StrategyUnit) Accept(metric *models.Metric) bool {
 	if s.st == nil || s.op == nil {
 		return false
 	}
+	if join, ok := s.op.(*Join); ok && join.JoinMetric() == metric.Name {
+		return true
+	}
 	if s.st.Metric != metric.Name {
 		return false
 	}
@@ -137,6 +167,24 @@ func (s *StrategyUnit) Accept(metric *models.Metric) bool {
 	return true
 }
 
+// Join joins metric with buffered samples for join() strategy,
+// it returns joined metric when both samples are ready,
+// other strategies return metric itself
+func (s *StrategyUnit) Join(metric *models.Metric) (*models.Metric, bool) {
+	if join, ok := s.op.(*Join); ok {
+		return join.Join(metric)
+	}
+	return metric, true
+}
+
+// Metrics returns metric names accepted by this unit
+func (s *StrategyUnit) Metrics() []string {
+	if join, ok := s.op.(*Join); ok {
+		return []string{s.st.Metric, join.JoinMetric()}
+	}
+	return []string{s.st.Metric}
+}
+
 // History return history data in this unit with metric hash key
 func (s *StrategyUnit) History(hash string) []*models.EventValue {
 	s.lock.RLock()
@@ -144,6 +192,24 @@ func (s *StrategyUnit) History(hash string) []*models.EventValue {
 	return s.dataQueue[hash]
 }
 
+func (s *StrategyUnit) queues() map[string][]*models.EventValue {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	queues := make(map[string][]*models.EventValue, len(s.dataQueue))
+	for hash, queue := range s.dataQueue {
+		queues[hash] = queue
+	}
+	return queues
+}
+
+func (s *StrategyUnit) setQueues(queues map[string][]*models.EventValue) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for hash, queue := range queues {
+		s.dataQueue[hash] = queue
+	}
+}
+
 // Operator return operator in the strategy unit
 func (s *StrategyUnit) Operator() Operator {
 	return s.op
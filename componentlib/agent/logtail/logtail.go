@@ -0,0 +1,270 @@
+package logtail
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/expvar"
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/corelib/zaplog"
+)
+
+var (
+	rules    []*rule
+	rulesSet bool // rules are set at least once, tailers restored from state are kept until then
+	tailers  = make(map[string]*tailer)
+	// file patterns globbed in last collecting, files newly matched by them are created later and read from beginning,
+	// files matched by new patterns are existing files and read from end
+	globbed    = make(map[string]bool)
+	stateFile  string
+	stateSaved int // tailers count in last saved state
+	lock       sync.Mutex
+
+	log = zaplog.Zap("logtail")
+
+	linesCount = expvar.NewDiff("logtail.lines")
+	matchCount = expvar.NewDiff("logtail.match")
+)
+
+func init() {
+	expvar.Register(linesCount, matchCount)
+}
+
+// rule is compiled log rule
+type rule struct {
+	*models.LogRule
+	reg    *regexp.Regexp
+	fields []string // named captures
+}
+
+// ruleStat is matched count and captured values of a rule in one reading
+type ruleStat struct {
+	count  int64
+	values map[string][]float64
+}
+
+// SetRules sets log rules, invalid rules are ignored
+func SetRules(logRules []*models.LogRule) {
+	var validRules []*rule
+	for _, lr := range logRules {
+		if lr.Name == "" || lr.File == "" || lr.Pattern == "" {
+			log.Warn("rule-invalid", "name", lr.Name, "file", lr.File)
+			continue
+		}
+		reg, err := regexp.Compile(lr.Pattern)
+		if err != nil {
+			log.Warn("rule-regexp-error", "name", lr.Name, "error", err)
+			continue
+		}
+		r := &rule{LogRule: lr, reg: reg}
+		for _, name := range reg.SubexpNames() {
+			if name != "" {
+				r.fields = append(r.fields, name)
+			}
+		}
+		validRules = append(validRules, r)
+	}
+	lock.Lock()
+	rules = validRules
+	rulesSet = true
+	lock.Unlock()
+	log.Info("set-rules", "rules", len(validRules))
+}
+
+// SetStateFile sets file to save offsets of tailing files,
+// tailing continues from saved offsets after restarting
+func SetStateFile(file string) {
+	lock.Lock()
+	defer lock.Unlock()
+	stateFile = file
+	if file == "" {
+		return
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("read-state-error", "file", file, "error", err)
+		}
+		return
+	}
+	states := make(map[string]*tailer)
+	if err = json.Unmarshal(data, &states); err != nil {
+		log.Warn("read-state-error", "file", file, "error", err)
+		return
+	}
+	for file, t := range states {
+		if tailers[file] == nil {
+			t.file = file
+			tailers[file] = t
+		}
+	}
+	stateSaved = len(states)
+	log.Info("read-state", "file", file, "tailers", len(states))
+}
+
+// saveState writes offsets of tailers to state file by temp file and renaming,
+// it skips writing if there is no tailer and no saved state
+func saveState() {
+	if stateFile == "" || (len(tailers) == 0 && stateSaved == 0) {
+		return
+	}
+	data, _ := json.Marshal(tailers)
+	tmpFile := stateFile + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, data, 0644); err != nil {
+		log.Warn("save-state-error", "file", stateFile, "error", err)
+		return
+	}
+	if err := os.Rename(tmpFile, stateFile); err != nil {
+		log.Warn("save-state-error", "file", stateFile, "error", err)
+		return
+	}
+	stateSaved = len(tailers)
+}
+
+// Tail reads log files and counts matched lines in interval
+func Tail(interval time.Duration, ch chan<- []*models.Metric) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		now := <-ticker.C
+		if metrics := collect(now, int(interval.Seconds())); len(metrics) > 0 && ch != nil {
+			ch <- metrics
+		}
+	}
+}
+
+// collect reads new lines of files in rules and returns metrics of each rule and file,
+// it does nothing before rules are set to keep offsets restored from state file
+func collect(now time.Time, step int) []*models.Metric {
+	lock.Lock()
+	defer lock.Unlock()
+	if !rulesSet {
+		return nil
+	}
+
+	var (
+		files       = make(map[string][]*rule)
+		created     = make(map[string]bool)
+		nowPatterns = make(map[string]bool, len(rules))
+	)
+	for _, r := range rules {
+		nowPatterns[r.File] = true
+		matches, err := filepath.Glob(r.File)
+		if err != nil {
+			log.Warn("glob-error", "file", r.File, "error", err)
+			continue
+		}
+		for _, file := range matches {
+			files[file] = append(files[file], r)
+			if globbed[r.File] {
+				created[file] = true
+			}
+		}
+	}
+	globbed = nowPatterns
+	for file, t := range tailers {
+		if files[file] == nil {
+			t.close()
+			delete(tailers, file)
+		}
+	}
+
+	var metrics []*models.Metric
+	for file, fileRules := range files {
+		t := tailers[file]
+		if t == nil {
+			if created[file] {
+				t = &tailer{file: file}
+			} else {
+				t = newTailerAtEnd(file)
+			}
+			tailers[file] = t
+		}
+		stats := make([]*ruleStat, len(fileRules))
+		for i := range stats {
+			stats[i] = &ruleStat{values: make(map[string][]float64)}
+		}
+		var lines int64
+		err := t.read(func(line []byte) {
+			lines++
+			for i, r := range fileRules {
+				stats[i].match(r, line)
+			}
+		})
+		if err != nil {
+			log.Warn("read-error", "file", file, "error", err)
+		}
+		linesCount.Incr(lines)
+		for i, r := range fileRules {
+			matchCount.Incr(stats[i].count)
+			metrics = append(metrics, stats[i].metric(r, file, now.Unix(), step))
+		}
+	}
+	saveState()
+	return metrics
+}
+
+func (rs *ruleStat) match(r *rule, line []byte) {
+	if len(r.fields) == 0 {
+		if r.reg.Match(line) {
+			rs.count++
+		}
+		return
+	}
+	sub := r.reg.FindSubmatch(line)
+	if sub == nil {
+		return
+	}
+	rs.count++
+	for i, name := range r.reg.SubexpNames() {
+		if name == "" || len(sub[i]) == 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(string(sub[i]), 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		rs.values[name] = append(rs.values[name], value)
+	}
+}
+
+// metric returns metric of matched count as value,
+// captured values are fields as <name>_avg, <name>_max, <name>_min and <name>_sum
+func (rs *ruleStat) metric(r *rule, file string, now int64, step int) *models.Metric {
+	m := &models.Metric{
+		Name:  r.Name,
+		Value: float64(rs.count),
+		Time:  now,
+		Step:  step,
+		Tags: map[string]string{
+			"file": file,
+		},
+		Fields: map[string]interface{}{
+			"count": rs.count,
+		},
+	}
+	for _, name := range r.fields {
+		values := rs.values[name]
+		if len(values) == 0 {
+			continue
+		}
+		sum, min, max := 0.0, values[0], values[0]
+		for _, v := range values {
+			sum += v
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+		}
+		m.Fields[name+"_sum"] = sum
+		m.Fields[name+"_avg"] = sum / float64(len(values))
+		m.Fields[name+"_min"] = min
+		m.Fields[name+"_max"] = max
+	}
+	return m
+}
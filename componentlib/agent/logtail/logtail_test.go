@@ -0,0 +1,205 @@
+package logtail
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/corelib/zaplog"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func init() {
+	log = zaplog.Null()
+}
+
+func appendFile(file string, data string) {
+	f, _ := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(data)
+	f.Close()
+}
+
+func TestTailer(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "logtail")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "app.log")
+
+	var lines []string
+	readFn := func(line []byte) {
+		lines = append(lines, string(line))
+	}
+
+	Convey("tailer", t, func() {
+		appendFile(file, "old line\n")
+		tl := newTailerAtEnd(file)
+		defer tl.close()
+
+		appendFile(file, "line 1\nline 2\r\nline")
+		So(tl.read(readFn), ShouldBeNil)
+		So(lines, ShouldResemble, []string{"line 1", "line 2"})
+
+		appendFile(file, " 3\n")
+		So(tl.read(readFn), ShouldBeNil)
+		So(lines, ShouldHaveLength, 3)
+		So(lines[2], ShouldEqual, "line 3")
+
+		// rotate by rename and create new file
+		appendFile(file, "line 4\n")
+		os.Rename(file, file+".1")
+		appendFile(file, "line 5\n")
+		So(tl.read(readFn), ShouldBeNil)
+		So(lines[3:], ShouldResemble, []string{"line 4", "line 5"})
+
+		// truncate
+		ioutil.WriteFile(file, []byte("6\n"), 0644)
+		So(tl.read(readFn), ShouldBeNil)
+		So(lines[5:], ShouldResemble, []string{"6"})
+
+		os.Remove(file)
+		So(tl.read(readFn), ShouldBeNil)
+		So(lines, ShouldHaveLength, 6)
+	})
+}
+
+func TestCollect(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "logtail")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "nginx.log")
+	stateFile := filepath.Join(dir, "state.json")
+	defer func() {
+		SetRules(nil)
+		collect(time.Now(), 60)
+		globbed = make(map[string]bool)
+		stateFile, stateSaved = "", 0
+		rulesSet = false
+	}()
+
+	Convey("collect", t, func() {
+		appendFile(file, "segfault at start\n")
+		SetStateFile(stateFile)
+		logRules := []*models.LogRule{
+			{Name: "log_segfault", File: filepath.Join(dir, "*.log"), Pattern: "segfault"},
+			{Name: "nginx_upstream", File: file, Pattern: `status=(?P<status>\d+) upstream_time=(?P<upstream_time>[0-9.]+|-)`},
+			{Name: "bad", File: file, Pattern: "("},
+		}
+		SetRules(logRules)
+		So(rules, ShouldHaveLength, 2)
+
+		metrics := collect(time.Now(), 60)
+		So(metrics, ShouldHaveLength, 2)
+		for _, m := range metrics {
+			So(m.Value, ShouldEqual, 0)
+			So(m.Tags["file"], ShouldEqual, file)
+		}
+
+		appendFile(file, `status=200 upstream_time=0.5
+status=502 upstream_time=1.5
+kernel: segfault at 0
+status=499 upstream_time=-
+`)
+		metrics = collect(time.Now(), 60)
+		So(metrics, ShouldHaveLength, 2)
+		for _, m := range metrics {
+			if m.Name == "log_segfault" {
+				So(m.Value, ShouldEqual, 1)
+				continue
+			}
+			So(m.Value, ShouldEqual, 3)
+			So(m.Step, ShouldEqual, 60)
+			So(m.Fields["upstream_time_avg"], ShouldEqual, 1)
+			So(m.Fields["upstream_time_max"], ShouldEqual, 1.5)
+			So(m.Fields["upstream_time_min"], ShouldEqual, 0.5)
+			So(m.Fields["status_sum"], ShouldEqual, 1201)
+		}
+
+		// restart with saved offsets
+		appendFile(file, "segfault again\n")
+		for _, tl := range tailers {
+			tl.close()
+		}
+		tailers = make(map[string]*tailer)
+		globbed = make(map[string]bool)
+		SetStateFile(stateFile)
+		So(tailers, ShouldContainKey, file)
+		metrics = collect(time.Now(), 60)
+		for _, m := range metrics {
+			if m.Name == "log_segfault" {
+				So(m.Value, ShouldEqual, 1)
+			}
+		}
+
+		// new file matched by existing pattern is read from beginning
+		newFile := filepath.Join(dir, "new.log")
+		appendFile(newFile, "segfault in new file\n")
+		metrics = collect(time.Now(), 60)
+		So(metrics, ShouldHaveLength, 3)
+		for _, m := range metrics {
+			if m.Tags["file"] == newFile {
+				So(m.Value, ShouldEqual, 1)
+			}
+		}
+
+		// existing file matched by new rule is read from end
+		oldFile := filepath.Join(dir, "old.txt")
+		appendFile(oldFile, "error before rule\n")
+		SetRules(append(logRules, &models.LogRule{Name: "txt_error", File: filepath.Join(dir, "*.txt"), Pattern: "error"}))
+		metrics = collect(time.Now(), 60)
+		So(metrics, ShouldHaveLength, 4)
+		for _, m := range metrics {
+			if m.Tags["file"] == oldFile {
+				So(m.Value, ShouldEqual, 0)
+			}
+		}
+		appendFile(oldFile, "error after rule\n")
+		metrics = collect(time.Now(), 60)
+		for _, m := range metrics {
+			if m.Tags["file"] == oldFile {
+				So(m.Value, ShouldEqual, 1)
+			}
+		}
+
+		data, err := ioutil.ReadFile(stateFile)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldContainSubstring, oldFile)
+	})
+
+	Convey("collect.no.state", t, func() {
+		SetRules(nil)
+		collect(time.Now(), 60)
+		os.Remove(stateFile)
+		stateSaved = 0
+		collect(time.Now(), 60)
+		_, err := os.Stat(stateFile)
+		So(os.IsNotExist(err), ShouldBeTrue) // no tailer, no state to save
+	})
+
+	Convey("collect.before.rules", t, func() {
+		appendFile(file, "segfault before restart\n")
+		SetRules([]*models.LogRule{{Name: "log_segfault", File: file, Pattern: "segfault"}})
+		SetStateFile(stateFile)
+		collect(time.Now(), 60)
+
+		// restart, no rules before config is synced
+		for _, tl := range tailers {
+			tl.close()
+		}
+		tailers = make(map[string]*tailer)
+		globbed = make(map[string]bool)
+		rules, rulesSet = nil, false
+		SetStateFile(stateFile)
+		saved, _ := ioutil.ReadFile(stateFile)
+		appendFile(file, "segfault during outage\n")
+		So(collect(time.Now(), 60), ShouldBeEmpty)
+		So(tailers, ShouldContainKey, file)
+		data, _ := ioutil.ReadFile(stateFile)
+		So(string(data), ShouldEqual, string(saved))
+
+		SetRules([]*models.LogRule{{Name: "log_segfault", File: file, Pattern: "segfault"}})
+		metrics := collect(time.Now(), 60)
+		So(metrics, ShouldHaveLength, 1)
+		So(metrics[0].Value, ShouldEqual, 1)
+	})
+}
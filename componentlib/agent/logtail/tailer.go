@@ -0,0 +1,137 @@
+package logtail
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"syscall"
+)
+
+// maxPartialSize is max size of incomplete line, longer line is dropped
+const maxPartialSize = 1024 * 1024
+
+// tailer reads new lines of a file by offset and inode,
+// it reads the rest of old file and re-opens new file when the file is rotated
+type tailer struct {
+	file     string
+	f        *os.File
+	Inode    uint64 `json:"inode"`
+	Offset   int64  `json:"offset"`
+	partial  []byte
+	skipping bool // skipping the rest of too long line
+}
+
+func inodeOf(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return stat.Ino
+	}
+	return 0
+}
+
+// newTailerAtEnd creates tailer reading from end of file, old lines are skipped
+func newTailerAtEnd(file string) *tailer {
+	t := &tailer{file: file}
+	if info, err := os.Stat(file); err == nil {
+		t.Inode = inodeOf(info)
+		t.Offset = info.Size()
+	}
+	return t
+}
+
+// read reads new complete lines to fn
+func (t *tailer) read(fn func(line []byte)) error {
+	info, err := os.Stat(t.file)
+	if t.f != nil && (err != nil || inodeOf(info) != t.Inode) {
+		// rotated or removed, read the rest of old file
+		t.readLines(fn)
+		t.close()
+		t.Inode, t.Offset = 0, 0
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if t.f == nil {
+		if err = t.open(); err != nil {
+			return err
+		}
+	}
+	if info.Size() < t.Offset {
+		// truncated
+		if _, err = t.f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		t.Offset = 0
+		t.partial, t.skipping = nil, false
+	}
+	return t.readLines(fn)
+}
+
+// open opens file, seeks to offset if it's the same inode, or reads from beginning
+func (t *tailer) open() error {
+	f, err := os.Open(t.file)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if inode := inodeOf(info); inode != t.Inode || info.Size() < t.Offset {
+		t.Inode, t.Offset = inode, 0
+	}
+	if _, err = f.Seek(t.Offset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	t.f = f
+	t.partial, t.skipping = nil, false
+	return nil
+}
+
+func (t *tailer) readLines(fn func(line []byte)) error {
+	reader := bufio.NewReader(t.f)
+	for {
+		data, err := reader.ReadSlice('\n')
+		t.Offset += int64(len(data))
+		if err != nil {
+			if t.skipping || len(t.partial)+len(data) > maxPartialSize {
+				t.skipping = true
+				t.partial = nil
+			} else {
+				t.partial = append(t.partial, data...)
+			}
+			if err == bufio.ErrBufferFull {
+				continue
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if t.skipping {
+			t.skipping = false
+			continue
+		}
+		line := data[:len(data)-1]
+		if len(t.partial) > 0 {
+			line = append(t.partial, line...)
+			t.partial = nil
+		}
+		if n := len(line); n > 0 && line[n-1] == '\r' {
+			line = line[:n-1]
+		}
+		fn(line)
+	}
+}
+
+func (t *tailer) close() {
+	if t.f != nil {
+		t.f.Close()
+		t.f = nil
+	}
+	t.partial, t.skipping = nil, false
+}
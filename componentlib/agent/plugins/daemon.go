@@ -0,0 +1,305 @@
+package plugins
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/expvar"
+	"github.com/baishancloud/mallard/corelib/models"
+)
+
+var (
+	// DaemonMaxBackoff is max waiting time before restarting exited daemon plugin
+	DaemonMaxBackoff = time.Minute
+	// DaemonDefaultStep is metric step of daemon plugin if manifest has no cycle
+	DaemonDefaultStep int64 = 60
+
+	daemonFlushInterval = time.Second
+	daemonFlushSize     = 100
+	daemonStopTimeout   = time.Second * 5
+
+	daemons        = make(map[string]*daemon)
+	daemonsStopped bool
+	daemonCounters = make(map[string][2]*expvar.DiffMeter)
+)
+
+// daemon supervises a long-running plugin, it restarts the plugin with backoff if exited
+type daemon struct {
+	plugin   *Plugin
+	modTime  int64
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	restarts *expvar.DiffMeter
+	lines    *expvar.DiffMeter
+}
+
+func newDaemon(plugin *Plugin) *daemon {
+	counters, ok := daemonCounters[plugin.File]
+	if !ok {
+		name := daemonName(plugin.File)
+		counters = [2]*expvar.DiffMeter{
+			expvar.NewDiff("plugins.daemon." + name + ".restarts"),
+			expvar.NewDiff("plugins.daemon." + name + ".lines"),
+		}
+		expvar.Register(counters[0], counters[1])
+		daemonCounters[plugin.File] = counters
+	}
+	return &daemon{
+		plugin:   plugin,
+		modTime:  plugin.FileModTime,
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+		restarts: counters[0],
+		lines:    counters[1],
+	}
+}
+
+// daemonName returns name of daemon plugin in counters, it's file path relative to plugins dir,
+// so daemons with same file name in different dirs don't share counters
+func daemonName(file string) string {
+	name, err := filepath.Rel(pluginDir, file)
+	if err != nil {
+		name = file
+	}
+	return strings.Replace(filepath.ToSlash(name), "/", "_", -1)
+}
+
+// syncDaemons starts new or changed daemon plugins, and signals removed ones to stop,
+// it must be called with pluginsLock, returned daemons are waited by waitDaemons after unlocking
+func syncDaemons() []*daemon {
+	if execQueue == nil || daemonsStopped {
+		return nil
+	}
+	var stopping []*daemon
+	for file, d := range daemons {
+		plugin := plugins[file]
+		if plugin == nil || plugin != d.plugin || plugin.FileModTime != d.modTime {
+			close(d.stopCh)
+			stopping = append(stopping, d)
+			delete(daemons, file)
+			log.Info("daemon-stop", "file", file)
+		}
+	}
+	for file, plugin := range plugins {
+		if !plugin.IsDaemon() || daemons[file] != nil {
+			continue
+		}
+		d := newDaemon(plugin)
+		daemons[file] = d
+		go d.run(execQueue)
+		log.Info("daemon-start", "file", file)
+	}
+	return stopping
+}
+
+// waitDaemons waits stopping daemons to exit, it's called without pluginsLock,
+// so waiting does not block running other plugins
+func waitDaemons(stopping []*daemon) {
+	for _, d := range stopping {
+		d.wait()
+	}
+}
+
+// Stop stops all daemon plugins
+func Stop() {
+	pluginsLock.Lock()
+	daemonsStopped = true
+	var stopping []*daemon
+	for file, d := range daemons {
+		close(d.stopCh)
+		stopping = append(stopping, d)
+		delete(daemons, file)
+	}
+	pluginsLock.Unlock()
+	waitDaemons(stopping)
+}
+
+func (d *daemon) stop() {
+	close(d.stopCh)
+	d.wait()
+}
+
+func (d *daemon) wait() {
+	select {
+	case <-d.doneCh:
+	case <-time.After(daemonStopTimeout):
+		log.Warn("daemon-stop-timeout", "file", d.plugin.File)
+	}
+}
+
+func (d *daemon) run(mCh chan<- []*models.Metric) {
+	defer close(d.doneCh)
+	backoff := time.Second
+	for {
+		st := time.Now()
+		err := d.runOnce(mCh)
+		select {
+		case <-d.stopCh:
+			return
+		default:
+		}
+		if err != nil {
+			d.plugin.setStatus(time.Since(st), nil, err)
+		}
+		if time.Since(st) > DaemonMaxBackoff {
+			backoff = time.Second
+		}
+		d.restarts.Incr(1)
+		log.Warn("daemon-exit", "file", d.plugin.File, "error", err, "backoff", backoff.String())
+		select {
+		case <-d.stopCh:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > DaemonMaxBackoff {
+			backoff = DaemonMaxBackoff
+		}
+	}
+}
+
+func (d *daemon) runOnce(mCh chan<- []*models.Metric) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	st := time.Now()
+	d.plugin.LastExecTime = st.Unix()
+
+	if err := d.plugin.verify(); err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, d.plugin.File)
+	if err := d.plugin.command(cmd); err != nil {
+		return err
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true // kill children with plugin when stopping
+	cmd.Stderr = pluginLogWriter{d.plugin}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		select {
+		case <-d.stopCh:
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	lineCh := make(chan []*models.Metric, daemonFlushSize)
+	go func() {
+		defer close(lineCh)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			d.lines.Incr(1)
+			metrics, err := d.plugin.parseLine(scanner.Bytes(), time.Now().Unix())
+			if err != nil {
+				d.plugin.writeLog([]byte("parse error: " + err.Error() + "\n"))
+				continue
+			}
+			if len(metrics) > 0 {
+				lineCh <- metrics
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(daemonFlushInterval)
+	defer ticker.Stop()
+	var metrics []*models.Metric
+	flush := func() {
+		if len(metrics) == 0 {
+			return
+		}
+		select {
+		case mCh <- metrics:
+			pluginsExecCount.Incr(int64(len(metrics)))
+			d.plugin.setStatus(time.Since(st), metrics, nil)
+		case <-d.stopCh: // metrics are dropped if queue is full when stopping
+		}
+		metrics = nil
+	}
+	for {
+		select {
+		case ms, ok := <-lineCh:
+			if !ok {
+				flush()
+				return cmd.Wait()
+			}
+			if metrics = append(metrics, ms...); len(metrics) >= daemonFlushSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// IsDaemon checks plugin is long-running daemon plugin
+func (p *Plugin) IsDaemon() bool {
+	return p.manifest != nil && p.manifest.Daemon
+}
+
+// parseLine parses one line output of daemon plugin,
+// it's a json metric object or array, or one line in plugin format
+func (p *Plugin) parseLine(line []byte, now int64) ([]*models.Metric, error) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] == '#' {
+		return nil, nil
+	}
+	if line[0] == '[' {
+		return p.parse(line, parsedAsNew, now)
+	}
+	if line[0] == '{' {
+		m := new(models.Metric)
+		if err := json.Unmarshal(line, m); err != nil {
+			return nil, err
+		}
+		if m.Time == 0 {
+			m.Time = now
+		}
+		if m.Step == 0 {
+			m.Step = int(p.Cycle)
+		}
+		return []*models.Metric{m}, nil
+	}
+	format := p.Format
+	if format == FormatAuto || format == FormatJSON {
+		format = DetectFormat(line)
+	}
+	var (
+		metrics []*models.Metric
+		errs    []error
+	)
+	if format == FormatInflux {
+		metrics, errs = ParseInflux(line, now, int(p.Cycle))
+	} else {
+		metrics, errs = ParsePrometheus(line, now, int(p.Cycle))
+	}
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return metrics, nil
+}
+
+type pluginLogWriter struct {
+	p *Plugin
+}
+
+func (w pluginLogWriter) Write(data []byte) (int, error) {
+	w.p.writeLog(data)
+	return len(data), nil
+}
@@ -0,0 +1,140 @@
+package plugins
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDaemon(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "plugins")
+	defer os.RemoveAll(dir)
+
+	newDaemonPlugin := func(name string, script string) *Plugin {
+		file := filepath.Join(dir, name)
+		ioutil.WriteFile(file, []byte(script), 0755)
+		p, err := NewPluginWithManifest(file, filepath.Join(dir, name+".log"), new(fileInfo), &Manifest{Daemon: true})
+		So(err, ShouldBeNil)
+		return p
+	}
+
+	Convey("daemon.parse", t, func() {
+		p := newDaemonPlugin("parse.sh", "")
+		So(p.IsDaemon(), ShouldBeTrue)
+		So(p.Cycle, ShouldEqual, DaemonDefaultStep)
+
+		metrics, err := p.parseLine([]byte(`{"name":"a","value":1}`), 100)
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 1)
+		So(metrics[0].Time, ShouldEqual, 100)
+		So(metrics[0].Step, ShouldEqual, 60)
+
+		metrics, err = p.parseLine([]byte(`cpu,host=a value=2`), 100)
+		So(err, ShouldBeNil)
+		So(metrics[0].Value, ShouldEqual, 2)
+
+		metrics, err = p.parseLine([]byte(`up{job="a"} 1`), 100)
+		So(err, ShouldBeNil)
+		So(metrics[0].Tags["job"], ShouldEqual, "a")
+
+		_, err = p.parseLine([]byte(`{"name":`), 100)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("daemon.run", t, func() {
+		p := newDaemonPlugin("stream.sh", `#!/bin/bash
+echo 'stream value=1'
+echo 'bad line{'
+echo 'error message' >&2
+echo 'stream value=2'
+sleep 60
+`)
+		queue := make(chan []*models.Metric, 10)
+		d := newDaemon(p)
+		go d.run(queue)
+
+		var metrics []*models.Metric
+		timeout := time.After(time.Second * 3)
+		for len(metrics) < 2 {
+			select {
+			case ms := <-queue:
+				metrics = append(metrics, ms...)
+			case <-timeout:
+				So("timeout", ShouldBeEmpty)
+			}
+		}
+		So(metrics[0].Value, ShouldEqual, 1)
+		So(metrics[1].Value, ShouldEqual, 2)
+		So(d.lines.Count(), ShouldEqual, 3)
+
+		d.stop()
+		_, ok := <-d.doneCh
+		So(ok, ShouldBeFalse)
+		So(d.restarts.Count(), ShouldEqual, 0)
+
+		logData, _ := ioutil.ReadFile(p.LogFile)
+		So(string(logData), ShouldContainSubstring, "parse error")
+		So(string(logData), ShouldContainSubstring, "error message")
+	})
+
+	Convey("daemon.restart", t, func() {
+		p := newDaemonPlugin("exit.sh", "#!/bin/bash\necho 'exit value=1'\n")
+		queue := make(chan []*models.Metric, 10)
+		d := newDaemon(p)
+		go d.run(queue)
+		time.Sleep(time.Millisecond * 1500)
+		d.stop()
+		So(d.restarts.Count(), ShouldBeGreaterThanOrEqualTo, 1)
+		So(len(queue), ShouldBeGreaterThanOrEqualTo, 2)
+	})
+
+	Convey("daemon.name", t, func() {
+		So(daemonName(filepath.Join(pluginDir, "sys", "10_foo.py")), ShouldEqual, "sys_10_foo.py")
+		So(daemonName(filepath.Join(pluginDir, "app", "10_foo.py")), ShouldEqual, "app_10_foo.py")
+	})
+
+	Convey("daemon.sync", t, func() {
+		pluginsLock.Lock()
+		p := newDaemonPlugin("sync.sh", "#!/bin/bash\nsleep 60\n")
+		plugins[p.File] = p
+		execQueue = make(chan []*models.Metric, 10)
+		syncDaemons()
+		So(daemons, ShouldContainKey, p.File)
+
+		p.FileModTime++
+		stopping := syncDaemons()
+		So(stopping, ShouldHaveLength, 1)
+		So(daemons, ShouldContainKey, p.File)
+		So(daemons[p.File].modTime, ShouldEqual, p.FileModTime)
+
+		delete(plugins, p.File)
+		stopping = append(stopping, syncDaemons()...)
+		So(daemons, ShouldBeEmpty)
+		execQueue = nil
+		pluginsLock.Unlock()
+
+		waitDaemons(stopping)
+		for _, d := range stopping {
+			_, ok := <-d.doneCh
+			So(ok, ShouldBeFalse)
+		}
+	})
+
+	Convey("daemon.stop.blocked", t, func() {
+		p := newDaemonPlugin("blocked.sh", "#!/bin/bash\necho 'blocked value=1'\nsleep 60\n")
+		queue := make(chan []*models.Metric) // nobody receives
+		d := newDaemon(p)
+		go d.run(queue)
+		time.Sleep(daemonFlushInterval * 2)
+		st := time.Now()
+		d.stop()
+		So(time.Since(st), ShouldBeLessThan, daemonStopTimeout)
+		_, ok := <-d.doneCh
+		So(ok, ShouldBeFalse)
+	})
+}
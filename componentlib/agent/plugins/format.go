@@ -0,0 +1,399 @@
+package plugins
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/baishancloud/mallard/corelib/models"
+)
+
+const (
+	// FormatAuto detects output format by content
+	FormatAuto = ""
+	// FormatJSON is json array of metrics
+	FormatJSON = "json"
+	// FormatPrometheus is prometheus text exposition format
+	FormatPrometheus = "prometheus"
+	// FormatInflux is influxdb line protocol
+	FormatInflux = "influx"
+)
+
+// formatOfFilename returns format declared in filename,
+// such as 60_nginx.prom.sh or 60_redis.influx.py
+func formatOfFilename(file string) string {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	switch filepath.Ext(name) {
+	case ".prom", ".prometheus":
+		return FormatPrometheus
+	case ".influx":
+		return FormatInflux
+	case ".json":
+		return FormatJSON
+	}
+	return FormatAuto
+}
+
+// isMetricRawJSON checks json array is old metric objects with "metric" key,
+// new metric objects have "name" key
+func isMetricRawJSON(data []byte) bool {
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return false
+	}
+	for _, item := range items {
+		if _, ok := item["name"]; ok {
+			return false
+		}
+		if _, ok := item["metric"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectFormat detects output format by content,
+// json array starts with "[", prometheus text starts with "#" or has no field set,
+// influxdb line has field set with "=" after measurement and tags
+func DetectFormat(data []byte) string {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] == '[' {
+		return FormatJSON
+	}
+	if data[0] == '#' {
+		return FormatPrometheus
+	}
+	line := data
+	if idx := bytes.IndexByte(data, '\n'); idx > 0 {
+		line = data[:idx]
+	}
+	parts := splitUnescaped(string(line), ' ')
+	if len(parts) > 1 && strings.Contains(parts[1], "=") && !strings.Contains(parts[0], "{") {
+		return FormatInflux
+	}
+	return FormatPrometheus
+}
+
+// splitUnescaped splits string by sep, but not escaped by "\" or in quotes or braces
+func splitUnescaped(s string, sep byte) []string {
+	var (
+		parts   []string
+		start   int
+		inQuote bool
+		inBrace bool
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case c == '"':
+			inQuote = !inQuote
+		case c == '{' && !inQuote:
+			inBrace = true
+		case c == '}' && !inQuote:
+			inBrace = false
+		case c == sep && !inQuote && !inBrace:
+			if i > start {
+				parts = append(parts, s[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' {
+				buf.WriteByte('\n')
+				continue
+			}
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.String()
+}
+
+// unixSeconds converts timestamp in s, ms, us or ns to seconds by its magnitude
+func unixSeconds(ts int64) int64 {
+	switch {
+	case ts < 1e11:
+		return ts
+	case ts < 1e14:
+		return ts / 1e3
+	case ts < 1e17:
+		return ts / 1e6
+	}
+	return ts / 1e9
+}
+
+// ParsePrometheus parses prometheus text exposition format to metrics,
+// labels are tags, histogram and summary series are merged to one metric with fields,
+// it returns parsed metrics and errors of bad lines
+func ParsePrometheus(data []byte, now int64, step int) ([]*models.Metric, []error) {
+	var (
+		metrics []*models.Metric
+		errs    []error
+		types   = make(map[string]string)
+		merged  = make(map[string]*models.Metric)
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if line[0] == '#' {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+		name, tags, value, ts, err := parsePrometheusLine(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %s", lineNo, err.Error()))
+			continue
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		if ts == 0 {
+			ts = now
+		}
+		base, field := prometheusSeriesField(name, tags, types)
+		if field == "" {
+			metrics = append(metrics, &models.Metric{
+				Name:  name,
+				Time:  ts,
+				Value: value,
+				Tags:  tags,
+				Step:  step,
+			})
+			continue
+		}
+		// histogram or summary series
+		m := &models.Metric{Name: base, Time: ts, Tags: tags, Step: step}
+		key := m.Hash()
+		if old := merged[key]; old != nil {
+			m = old
+		} else {
+			m.Fields = make(map[string]interface{})
+			merged[key] = m
+			metrics = append(metrics, m)
+		}
+		m.Fields[field] = value
+		if field == "count" {
+			m.Value = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return metrics, errs
+}
+
+// prometheusSeriesField returns base name and field name for histogram or summary series,
+// it removes "le" and "quantile" from tags, field is empty for other series
+func prometheusSeriesField(name string, tags map[string]string, types map[string]string) (string, string) {
+	for _, suffix := range []string{"_bucket", "_sum", "_count", ""} {
+		base := strings.TrimSuffix(name, suffix)
+		if suffix != "" && base == name {
+			continue
+		}
+		typ := types[base]
+		if typ != "histogram" && typ != "summary" {
+			continue
+		}
+		switch suffix {
+		case "_bucket":
+			le := tags["le"]
+			delete(tags, "le")
+			return base, "bucket_" + le
+		case "_sum":
+			return base, "sum"
+		case "_count":
+			return base, "count"
+		}
+		if q, ok := tags["quantile"]; ok {
+			delete(tags, "quantile")
+			return base, "quantile_" + q
+		}
+	}
+	return name, ""
+}
+
+func parsePrometheusLine(line string) (string, map[string]string, float64, int64, error) {
+	var (
+		name string
+		tags map[string]string
+		rest string
+	)
+	if idx := strings.IndexByte(line, '{'); idx >= 0 {
+		end := strings.LastIndexByte(line, '}')
+		if end < idx {
+			return "", nil, 0, 0, fmt.Errorf("bad labels '%s'", line)
+		}
+		name = strings.TrimSpace(line[:idx])
+		var err error
+		if tags, err = parsePrometheusLabels(line[idx+1 : end]); err != nil {
+			return "", nil, 0, 0, err
+		}
+		rest = line[end+1:]
+	} else {
+		idx := strings.IndexAny(line, " \t")
+		if idx < 0 {
+			return "", nil, 0, 0, fmt.Errorf("no value '%s'", line)
+		}
+		name, rest = line[:idx], line[idx:]
+	}
+	if name == "" {
+		return "", nil, 0, 0, fmt.Errorf("no name '%s'", line)
+	}
+	values := strings.Fields(rest)
+	if len(values) == 0 || len(values) > 2 {
+		return "", nil, 0, 0, fmt.Errorf("bad value '%s'", line)
+	}
+	value, err := strconv.ParseFloat(values[0], 64)
+	if err != nil {
+		return "", nil, 0, 0, fmt.Errorf("bad value '%s'", values[0])
+	}
+	var ts int64
+	if len(values) == 2 {
+		if ts, err = strconv.ParseInt(values[1], 10, 64); err != nil {
+			return "", nil, 0, 0, fmt.Errorf("bad timestamp '%s'", values[1])
+		}
+		ts = unixSeconds(ts)
+	}
+	return name, tags, value, ts, nil
+}
+
+func parsePrometheusLabels(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, pair := range splitUnescaped(s, ',') {
+		idx := strings.IndexByte(pair, '=')
+		if idx < 0 {
+			return nil, fmt.Errorf("bad label '%s'", pair)
+		}
+		key := strings.TrimSpace(pair[:idx])
+		value := strings.TrimSpace(pair[idx+1:])
+		if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+			return nil, fmt.Errorf("bad label value '%s'", pair)
+		}
+		tags[key] = unescape(value[1 : len(value)-1])
+	}
+	return tags, nil
+}
+
+// ParseInflux parses influxdb line protocol to metrics,
+// field "value" is metric value, it returns parsed metrics and errors of bad lines
+func ParseInflux(data []byte, now int64, step int) ([]*models.Metric, []error) {
+	var (
+		metrics []*models.Metric
+		errs    []error
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		m, err := parseInfluxLine(line, now, step)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %s", lineNo, err.Error()))
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return metrics, errs
+}
+
+func parseInfluxLine(line string, now int64, step int) (*models.Metric, error) {
+	parts := splitUnescaped(line, ' ')
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("bad line '%s'", line)
+	}
+	m := &models.Metric{
+		Time:   now,
+		Step:   step,
+		Fields: make(map[string]interface{}),
+	}
+	keys := splitUnescaped(parts[0], ',')
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no measurement '%s'", line)
+	}
+	m.Name = unescape(keys[0])
+	for _, tag := range keys[1:] {
+		pair := splitUnescaped(tag, '=')
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("bad tag '%s'", tag)
+		}
+		if m.Tags == nil {
+			m.Tags = make(map[string]string)
+		}
+		m.Tags[unescape(pair[0])] = unescape(pair[1])
+	}
+	for _, field := range splitUnescaped(parts[1], ',') {
+		idx := strings.IndexByte(field, '=')
+		if idx <= 0 {
+			return nil, fmt.Errorf("bad field '%s'", field)
+		}
+		value, err := parseInfluxValue(field[idx+1:])
+		if err != nil {
+			return nil, fmt.Errorf("bad field '%s'", field)
+		}
+		m.Fields[unescape(field[:idx])] = value
+	}
+	if len(parts) == 3 {
+		ts, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad timestamp '%s'", parts[2])
+		}
+		m.Time = unixSeconds(ts)
+	}
+	if v, ok := m.Fields["value"].(float64); ok {
+		m.Value = v
+		delete(m.Fields, "value")
+	}
+	return m, nil
+}
+
+func parseInfluxValue(s string) (interface{}, error) {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return unescape(s[1 : len(s)-1]), nil
+	}
+	switch s {
+	case "t", "T", "true", "True", "TRUE":
+		return 1.0, nil
+	case "f", "F", "false", "False", "FALSE":
+		return 0.0, nil
+	}
+	if strings.HasSuffix(s, "i") || strings.HasSuffix(s, "u") {
+		v, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+		return float64(v), err
+	}
+	return strconv.ParseFloat(s, 64)
+}
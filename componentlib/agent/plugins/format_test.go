@@ -0,0 +1,132 @@
+package plugins
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFormat(t *testing.T) {
+	Convey("format.detect", t, func() {
+		So(DetectFormat([]byte(` [{"metric":"a"}]`)), ShouldEqual, FormatJSON)
+		So(DetectFormat([]byte("# HELP a a\na 1\n")), ShouldEqual, FormatPrometheus)
+		So(DetectFormat([]byte(`a{b="c d"} 1`)), ShouldEqual, FormatPrometheus)
+		So(DetectFormat([]byte("a 1 1534000000000")), ShouldEqual, FormatPrometheus)
+		So(DetectFormat([]byte("cpu,host=a idle=1,user=2 1534000000000000000")), ShouldEqual, FormatInflux)
+		So(DetectFormat([]byte(`disk\ io value=1`)), ShouldEqual, FormatInflux)
+
+		So(formatOfFilename("plugins/60_nginx.prom.sh"), ShouldEqual, FormatPrometheus)
+		So(formatOfFilename("plugins/60_redis.influx.py"), ShouldEqual, FormatInflux)
+		So(formatOfFilename("plugins/60_redis.py"), ShouldEqual, FormatAuto)
+	})
+
+	Convey("format.prometheus", t, func() {
+		data := []byte(`# HELP http_requests_total total requests
+# TYPE http_requests_total counter
+http_requests_total{method="get",path="/a\"b"} 1027 1534000000000
+up 1
+# TYPE latency histogram
+latency_bucket{api="x",le="0.1"} 5
+latency_bucket{api="x",le="+Inf"} 8
+latency_sum{api="x"} 1.5
+latency_count{api="x"} 8
+# TYPE rpc summary
+rpc{quantile="0.99"} 0.2
+rpc_sum 10
+rpc_count 100
+nan_value NaN
+bad{a=b} 1
+bad_value abc
+`)
+		metrics, errs := ParsePrometheus(data, 1534000099, 60)
+		So(errs, ShouldHaveLength, 2)
+		So(metrics, ShouldHaveLength, 4)
+
+		So(metrics[0].Name, ShouldEqual, "http_requests_total")
+		So(metrics[0].Value, ShouldEqual, 1027)
+		So(metrics[0].Time, ShouldEqual, 1534000000)
+		So(metrics[0].Step, ShouldEqual, 60)
+		So(metrics[0].Tags, ShouldResemble, map[string]string{"method": "get", "path": `/a"b`})
+
+		So(metrics[1].Name, ShouldEqual, "up")
+		So(metrics[1].Time, ShouldEqual, 1534000099)
+
+		So(metrics[2].Name, ShouldEqual, "latency")
+		So(metrics[2].Tags, ShouldResemble, map[string]string{"api": "x"})
+		So(metrics[2].Value, ShouldEqual, 8)
+		So(metrics[2].Fields, ShouldResemble, map[string]interface{}{
+			"bucket_0.1":  5.0,
+			"bucket_+Inf": 8.0,
+			"sum":         1.5,
+			"count":       8.0,
+		})
+
+		So(metrics[3].Name, ShouldEqual, "rpc")
+		So(metrics[3].Fields["quantile_0.99"], ShouldEqual, 0.2)
+		So(metrics[3].Fields["count"], ShouldEqual, 100)
+	})
+
+	Convey("format.influx", t, func() {
+		data := []byte(`cpu,host=server\ 1,region=cn idle=90.5,busy=2i,ok=t 1534000000000000000
+disk value=3,path="/data a"
+# comment
+mem,host=a
+`)
+		metrics, errs := ParseInflux(data, 1534000099, 60)
+		So(errs, ShouldHaveLength, 1)
+		So(metrics, ShouldHaveLength, 2)
+
+		So(metrics[0].Name, ShouldEqual, "cpu")
+		So(metrics[0].Time, ShouldEqual, 1534000000)
+		So(metrics[0].Tags, ShouldResemble, map[string]string{"host": "server 1", "region": "cn"})
+		So(metrics[0].Fields, ShouldResemble, map[string]interface{}{"idle": 90.5, "busy": 2.0, "ok": 1.0})
+
+		So(metrics[1].Name, ShouldEqual, "disk")
+		So(metrics[1].Value, ShouldEqual, 3)
+		So(metrics[1].Time, ShouldEqual, 1534000099)
+		So(metrics[1].Fields, ShouldResemble, map[string]interface{}{"path": "/data a"})
+	})
+
+	Convey("format.json", t, func() {
+		p, err := NewPlugin("10_test.json.sh", "", new(fileInfo))
+		So(err, ShouldBeNil)
+		So(p.Format, ShouldEqual, FormatJSON)
+
+		metrics, err := p.parse([]byte(`[{"metric":"old","value":1,"timestamp":100,"step":10,"counterType":"GAUGE"}]`), true, 100)
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 1)
+		So(metrics[0].Name, ShouldEqual, "old")
+
+		metrics, err = p.parse([]byte(`[{"name":"new","value":2,"time":100,"fields":{"a":1}}]`), false, 100)
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 1)
+		So(metrics[0].Name, ShouldEqual, "new")
+		So(metrics[0].Fields["a"], ShouldEqual, 1)
+	})
+
+	Convey("format.plugin", t, func() {
+		dir, _ := ioutil.TempDir("", "plugins")
+		defer os.RemoveAll(dir)
+		file := filepath.Join(dir, "10_test.prom.sh")
+		ioutil.WriteFile(file, []byte(`#!/bin/bash
+echo '# TYPE http_requests_total counter'
+echo 'http_requests_total{method="get",code="200"} 1027'
+echo 'http_requests_total{method="post",code="200"} 3'
+echo 'bad_line{method="get"'
+`), 0755)
+		logFile := filepath.Join(dir, "prom.log")
+		p, err := NewPlugin(file, logFile, new(fileInfo))
+		So(err, ShouldBeNil)
+		So(p.Format, ShouldEqual, FormatPrometheus)
+		metrics, err := p.Exec(parsedAsNew)
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 2)
+		So(metrics[0].Step, ShouldEqual, 10)
+
+		logData, _ := ioutil.ReadFile(logFile)
+		So(string(logData), ShouldContainSubstring, "prometheus parse error: line 4")
+	})
+}
@@ -0,0 +1,150 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	signSuffix          = ".sig"
+	integrityMetricName = "plugin_integrity"
+)
+
+var (
+	// ErrIntegrityHash means file hash is different to expected hash
+	ErrIntegrityHash = errors.New("hash-mismatch")
+	// ErrIntegritySign means file has no signature or wrong signature
+	ErrIntegritySign = errors.New("sign-mismatch")
+	// ErrIntegrityNotAllowed means file has no expected hash and dir has no signing key
+	ErrIntegrityNotAllowed = errors.New("not-allowed")
+
+	integrities map[string]*models.PluginIntegrity
+	mismatches  = make(map[string]string)
+)
+
+// SetIntegrity sets expected hashes or signing keys for plugins dirs,
+// files in these dirs are not executed if not matched
+func SetIntegrity(ig map[string]*models.PluginIntegrity) {
+	pluginsLock.Lock()
+	integrities = ig
+	pluginsLock.Unlock()
+	log.Info("set-integrity", "dirs", len(ig))
+}
+
+// Mismatches returns files failed in integrity checking, with reasons
+func Mismatches() map[string]string {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+
+	result := make(map[string]string, len(mismatches))
+	for file, reason := range mismatches {
+		result[file] = reason
+	}
+	return result
+}
+
+func isSignFile(file string) bool {
+	return strings.HasSuffix(file, signSuffix)
+}
+
+// verifyPlugin checks plugin file and its manifest with integrity of dir
+func verifyPlugin(ig *models.PluginIntegrity, dir string, file string, hasManifest bool) error {
+	if err := verifyFile(ig, dir, file); err != nil {
+		return err
+	}
+	if hasManifest {
+		if err := verifyFile(ig, dir, ManifestFile(file)); err != nil {
+			return errors.New("manifest " + err.Error())
+		}
+	}
+	return nil
+}
+
+// pluginIntegrity is integrity of plugins dir that plugin is verified with
+type pluginIntegrity struct {
+	ig  *models.PluginIntegrity
+	dir string
+}
+
+// setIntegrity sets integrity to verify plugin again before each execution, nil ig means no checking
+func (p *Plugin) setIntegrity(ig *models.PluginIntegrity, dir string) {
+	p.statusLock.Lock()
+	defer p.statusLock.Unlock()
+	if ig == nil {
+		p.integrity = nil
+		return
+	}
+	p.integrity = &pluginIntegrity{ig: ig, dir: dir}
+}
+
+// verify checks plugin file and its manifest again right before execution,
+// files could be changed after ScanFiles verified them
+func (p *Plugin) verify() error {
+	p.statusLock.Lock()
+	pi := p.integrity
+	p.statusLock.Unlock()
+	if pi == nil {
+		return nil
+	}
+	return verifyPlugin(pi.ig, pi.dir, p.File, p.manifestTime > 0)
+}
+
+// verifyFile checks file by expected hash, or signature file with public key if no hash
+func verifyFile(ig *models.PluginIntegrity, dir string, file string) error {
+	rel, err := filepath.Rel(dir, file)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if hash, ok := ig.Hashes[filepath.ToSlash(rel)]; ok {
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != strings.ToLower(hash) {
+			return ErrIntegrityHash
+		}
+		return nil
+	}
+	if ig.PublicKey == "" {
+		return ErrIntegrityNotAllowed
+	}
+	key, err := base64.StdEncoding.DecodeString(ig.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return ErrIntegritySign
+	}
+	sigData, err := ioutil.ReadFile(file + signSuffix)
+	if err != nil {
+		return ErrIntegritySign
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigData)))
+	if err != nil || !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+		return ErrIntegritySign
+	}
+	return nil
+}
+
+// integrityMetric returns metric of mismatched files count,
+// nil if no integrity to check
+func integrityMetric(step int) *models.Metric {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+	if len(integrities) == 0 {
+		return nil
+	}
+	return &models.Metric{
+		Name:  integrityMetricName,
+		Value: float64(len(mismatches)),
+		Time:  time.Now().Unix(),
+		Step:  step,
+	}
+}
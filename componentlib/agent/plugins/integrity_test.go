@@ -0,0 +1,87 @@
+package plugins
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestIntegrity(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "plugins")
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "sys"), os.ModePerm)
+
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	writePlugin := func(name string, data string, sign bool) string {
+		file := filepath.Join(dir, "sys", name)
+		ioutil.WriteFile(file, []byte(data), 0755)
+		if sign {
+			sig := ed25519.Sign(priv, []byte(data))
+			ioutil.WriteFile(file+signSuffix, []byte(base64.StdEncoding.EncodeToString(sig)), 0644)
+		}
+		return file
+	}
+	hashed := "#!/bin/bash\necho '[]'\n"
+	sum := sha256.Sum256([]byte(hashed))
+	ig := &models.PluginIntegrity{
+		Hashes:    map[string]string{"60_hashed.sh": hex.EncodeToString(sum[:])},
+		PublicKey: base64.StdEncoding.EncodeToString(pub),
+	}
+
+	Convey("integrity.verify", t, func() {
+		file := writePlugin("60_hashed.sh", hashed, false)
+		So(verifyFile(ig, filepath.Join(dir, "sys"), file), ShouldBeNil)
+
+		file = writePlugin("60_signed.sh", "#!/bin/bash\necho 1\n", true)
+		So(verifyFile(ig, filepath.Join(dir, "sys"), file), ShouldBeNil)
+		So(verifyFile(&models.PluginIntegrity{}, filepath.Join(dir, "sys"), file), ShouldEqual, ErrIntegrityNotAllowed)
+
+		ioutil.WriteFile(file, []byte("#!/bin/bash\necho 2\n"), 0755)
+		So(verifyFile(ig, filepath.Join(dir, "sys"), file), ShouldEqual, ErrIntegritySign)
+		writePlugin("60_signed.sh", "#!/bin/bash\necho 1\n", true)
+	})
+
+	Convey("integrity.scan", t, func() {
+		oldDir, oldRunDirs := pluginDir, pluginRunDirList
+		pluginDir, pluginRunDirList = dir, []string{"sys"}
+		defer func() {
+			pluginDir, pluginRunDirList = oldDir, oldRunDirs
+			plugins = make(map[string]*Plugin)
+			SetIntegrity(nil)
+			mismatches = make(map[string]string)
+		}()
+		So(integrityMetric(60), ShouldBeNil)
+
+		writePlugin("60_unknown.sh", "#!/bin/bash\necho 3\n", false)
+		SetIntegrity(map[string]*models.PluginIntegrity{"sys": ig})
+		ScanFiles()
+		So(plugins, ShouldHaveLength, 2)
+		So(plugins, ShouldContainKey, filepath.Join(dir, "sys", "60_hashed.sh"))
+		So(plugins, ShouldContainKey, filepath.Join(dir, "sys", "60_signed.sh"))
+		So(Mismatches(), ShouldResemble, map[string]string{
+			filepath.Join(dir, "sys", "60_unknown.sh"): ErrIntegritySign.Error(),
+		})
+
+		_, err := Run("60_hashed.sh")
+		So(err, ShouldBeNil)
+		writePlugin("60_hashed.sh", "#!/bin/bash\necho tampered\n", false)
+		_, err = Run("60_hashed.sh") // verified again before execution
+		So(err, ShouldEqual, ErrIntegrityHash)
+		ScanFiles()
+		So(plugins, ShouldHaveLength, 1)
+		So(Mismatches()[filepath.Join(dir, "sys", "60_hashed.sh")], ShouldEqual, ErrIntegrityHash.Error())
+
+		m := integrityMetric(60)
+		So(m, ShouldNotBeNil)
+		So(m.Value, ShouldEqual, 2)
+	})
+}
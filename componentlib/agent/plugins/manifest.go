@@ -0,0 +1,164 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const manifestSuffix = ".plugin.json"
+
+var (
+	// ErrManifestNoCycle means manifest and filename both have no cycle
+	ErrManifestNoCycle = errors.New("manifest-no-cycle")
+)
+
+// Manifest is plugin options in sidecar file next to the plugin file,
+// such as 60_foo.py.plugin.json or 60_foo.plugin.json for 60_foo.py, see ManifestFile for lookup order
+type Manifest struct {
+	Cycle       int64             `json:"cycle,omitempty"`   // seconds, use cycle in filename if 0
+	Timeout     int64             `json:"timeout,omitempty"` // seconds, use default timeout of cycle if 0
+	Args        []string          `json:"args,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	Dir         string            `json:"dir,omitempty"`
+	Format      string            `json:"format,omitempty"`
+	User        string            `json:"user,omitempty"`
+	Interpreter string            `json:"interpreter,omitempty"` // such as python3, run file directly if empty
+	Overlap     bool              `json:"overlap,omitempty"`     // allow to run when last execution is not finished
+	Daemon      bool              `json:"daemon,omitempty"`      // long-running plugin that writes line-delimited metrics
+}
+
+// ManifestFile returns manifest file path of plugin file, it looks up foo.py.plugin.json
+// then foo.plugin.json for foo.py, full file name is preferred so foo.py and foo.sh can have own manifests,
+// it returns the full file name path if neither exists
+func ManifestFile(file string) string {
+	full := file + manifestSuffix
+	ext := filepath.Ext(file)
+	if ext == "" {
+		return full
+	}
+	if _, err := os.Stat(full); err == nil {
+		return full
+	}
+	short := strings.TrimSuffix(file, ext) + manifestSuffix
+	if _, err := os.Stat(short); err == nil {
+		return short
+	}
+	return full
+}
+
+func isManifestFile(file string) bool {
+	return strings.HasSuffix(file, manifestSuffix)
+}
+
+// ReadManifest reads manifest file
+func ReadManifest(file string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	m := new(Manifest)
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Cycle < 0 || m.Timeout < 0 {
+		return nil, fmt.Errorf("bad cycle or timeout in %s", file)
+	}
+	switch m.Format {
+	case FormatAuto, FormatJSON, FormatPrometheus, FormatInflux:
+	default:
+		return nil, fmt.Errorf("bad format %s in %s", m.Format, file)
+	}
+	return m, nil
+}
+
+// NewPluginWithManifest new plugin with file and manifest,
+// file could be any executable file
+func NewPluginWithManifest(file string, logFile string, info os.FileInfo, manifest *Manifest) (*Plugin, error) {
+	cycle := manifest.Cycle
+	if cycle == 0 {
+		var err error
+		if cycle, err = parseFilename(filepath.Base(file)); err != nil || cycle <= 0 {
+			if !manifest.Daemon {
+				return nil, ErrManifestNoCycle
+			}
+			cycle = DaemonDefaultStep
+		}
+	}
+	p, err := newPlugin(file, logFile, info, cycle)
+	if err != nil {
+		return nil, err
+	}
+	p.SetManifest(manifest)
+	return p, nil
+}
+
+// SetManifest sets manifest options to plugin
+func (p *Plugin) SetManifest(manifest *Manifest) {
+	p.manifest = manifest
+	if manifest == nil {
+		return
+	}
+	p.overlap = manifest.Overlap
+	if manifest.Cycle > 0 {
+		p.Cycle = manifest.Cycle
+	}
+	p.timeout = calTimeout(p.Cycle)
+	if manifest.Timeout > 0 {
+		p.timeout = time.Duration(manifest.Timeout) * time.Second
+	}
+	if manifest.Format != FormatAuto {
+		p.Format = manifest.Format
+	}
+}
+
+// Manifest returns manifest of plugin, nil if no manifest
+func (p *Plugin) Manifest() *Manifest {
+	return p.manifest
+}
+
+// command returns command to execute plugin with manifest options
+func (p *Plugin) command(cmd *exec.Cmd) error {
+	m := p.manifest
+	if m == nil {
+		return nil
+	}
+	if m.Interpreter != "" {
+		interpreter, err := exec.LookPath(m.Interpreter)
+		if err != nil {
+			return err
+		}
+		cmd.Path = interpreter
+		cmd.Args = append([]string{m.Interpreter, p.File}, m.Args...)
+	} else {
+		cmd.Args = append(cmd.Args, m.Args...)
+	}
+	if len(m.Env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range m.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+	cmd.Dir = m.Dir
+	if m.User != "" {
+		u, err := user.Lookup(m.User)
+		if err != nil {
+			return err
+		}
+		uid, _ := strconv.ParseUint(u.Uid, 10, 32)
+		gid, _ := strconv.ParseUint(u.Gid, 10, 32)
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Credential: &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package plugins
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestManifest(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "plugins")
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "sys"), os.ModePerm)
+
+	Convey("manifest.read", t, func() {
+		So(ManifestFile("sys/60_foo.py"), ShouldEqual, "sys/60_foo.py.plugin.json")
+		So(ManifestFile("sys/60_foo.sh"), ShouldEqual, "sys/60_foo.sh.plugin.json")
+		So(ManifestFile("sys/check"), ShouldEqual, "sys/check.plugin.json")
+
+		script := filepath.Join(dir, "60_foo.py")
+		ioutil.WriteFile(filepath.Join(dir, "60_foo.plugin.json"), []byte(`{}`), 0644)
+		So(ManifestFile(script), ShouldEqual, filepath.Join(dir, "60_foo.plugin.json"))
+		ioutil.WriteFile(script+".plugin.json", []byte(`{}`), 0644)
+		So(ManifestFile(script), ShouldEqual, script+".plugin.json") // full name is preferred
+		os.Remove(script + ".plugin.json")
+		os.Remove(filepath.Join(dir, "60_foo.plugin.json"))
+
+		file := filepath.Join(dir, "bad.plugin.json")
+		ioutil.WriteFile(file, []byte(`{"cycle":30,"format":"xml"}`), 0644)
+		_, err := ReadManifest(file)
+		So(err, ShouldNotBeNil)
+
+		_, err = NewPluginWithManifest("check", "", new(fileInfo), &Manifest{})
+		So(err, ShouldEqual, ErrManifestNoCycle)
+	})
+
+	Convey("manifest.exec", t, func() {
+		file := filepath.Join(dir, "sys", "check")
+		ioutil.WriteFile(file, []byte(`echo "check,arg=$1 value=$CHECK_VALUE,dir=\"$(pwd)\" 1534000000"`), 0644)
+		ioutil.WriteFile(ManifestFile(file), []byte(`{
+			"cycle": 30,
+			"timeout": 5,
+			"args": ["a1"],
+			"env": {"CHECK_VALUE": "9"},
+			"dir": "/tmp",
+			"interpreter": "bash",
+			"format": "influx"
+		}`), 0644)
+		manifest, err := ReadManifest(ManifestFile(file))
+		So(err, ShouldBeNil)
+		p, err := NewPluginWithManifest(file, "", new(fileInfo), manifest)
+		So(err, ShouldBeNil)
+		So(p.Cycle, ShouldEqual, 30)
+		So(p.timeout, ShouldEqual, 5*time.Second)
+		So(p.Format, ShouldEqual, FormatInflux)
+
+		metrics, err := p.Exec(parsedAsNew)
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 1)
+		So(metrics[0].Tags["arg"], ShouldEqual, "a1")
+		So(metrics[0].Value, ShouldEqual, 9)
+		So(metrics[0].Fields["dir"], ShouldEqual, "/tmp")
+	})
+
+	Convey("manifest.overlap", t, func() {
+		file := filepath.Join(dir, "slow.sh")
+		ioutil.WriteFile(file, []byte("#!/bin/bash\nsleep 1\necho '[]'\n"), 0755)
+		p, err := NewPluginWithManifest(file, "", new(fileInfo), &Manifest{Cycle: 10})
+		So(err, ShouldBeNil)
+
+		var (
+			wg   sync.WaitGroup
+			errs = make([]error, 2)
+		)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = p.Exec(parsedAsNew)
+			}(i)
+			time.Sleep(time.Millisecond * 200)
+		}
+		wg.Wait()
+		So(errs[0], ShouldBeNil)
+		So(errs[1], ShouldEqual, ErrPluginRunning)
+	})
+
+	Convey("manifest.scan", t, func() {
+		oldDir, oldRunDirs := pluginDir, pluginRunDirList
+		pluginDir, pluginRunDirList = dir, []string{"sys"}
+		defer func() {
+			pluginDir, pluginRunDirList = oldDir, oldRunDirs
+			plugins = make(map[string]*Plugin)
+		}()
+		ioutil.WriteFile(filepath.Join(dir, "sys", "README"), []byte("readme"), 0644)
+
+		ScanFiles()
+		So(plugins, ShouldHaveLength, 1)
+		p := plugins[filepath.Join(dir, "sys", "check")]
+		So(p, ShouldNotBeNil)
+		So(p.Manifest(), ShouldNotBeNil)
+
+		os.Remove(ManifestFile(filepath.Join(dir, "sys", "check")))
+		ScanFiles()
+		So(plugins, ShouldBeEmpty)
+	})
+}
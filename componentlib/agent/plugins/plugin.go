@@ -12,6 +12,8 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/baishancloud/mallard/corelib/models"
@@ -29,6 +31,10 @@ var (
 	ErrNoFileInfo = errors.New("no-fileinfo")
 	// ErrWrongFilename means wrong filename
 	ErrWrongFilename = errors.New("wrong-filename")
+	// ErrPluginRunning means last execution is not finished and overlap is not allowed
+	ErrPluginRunning = errors.New("still-running")
+
+	counterRater = models.NewCounterRater(time.Hour)
 )
 
 // Plugin is executor of a plugin file
@@ -39,7 +45,20 @@ type Plugin struct {
 	LastExecTime int64
 	Cycle        int64
 	ReloadTime   int64
+	Format       string // output format, detected by output if empty
 	timeout      time.Duration
+
+	manifest     *Manifest
+	overlap      bool
+	running      int32
+	manifestTime int64
+	integrity    *pluginIntegrity
+
+	statusLock   sync.Mutex
+	lastDuration time.Duration
+	lastError    string
+	lastMetrics  []string
+	failCount    int64
 }
 
 // NewPlugin new plugin with file
@@ -48,6 +67,10 @@ func NewPlugin(file string, logFile string, info os.FileInfo) (*Plugin, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newPlugin(file, logFile, info, cycle)
+}
+
+func newPlugin(file string, logFile string, info os.FileInfo, cycle int64) (*Plugin, error) {
 	if info == nil {
 		return nil, ErrNoFileInfo
 	}
@@ -56,8 +79,10 @@ func NewPlugin(file string, logFile string, info os.FileInfo) (*Plugin, error) {
 		LogFile:      logFile,
 		FileModTime:  info.ModTime().Unix(),
 		Cycle:        cycle,
+		Format:       formatOfFilename(file),
 		LastExecTime: time.Now().Unix() - cycle + rand.Int63n(60), // set last exec time as an old time, so all plugins can run in different tick
 		timeout:      calTimeout(cycle),
+		overlap:      true,
 	}
 	return p, nil
 }
@@ -85,6 +110,19 @@ func (p *Plugin) ShouldExec(t int64) bool {
 // Exec execute plugin file
 func (p *Plugin) Exec(asNew bool) ([]*models.Metric, error) {
 	p.LastExecTime = time.Now().Unix()
+	return p.exec(asNew, p.LastExecTime)
+}
+
+func (p *Plugin) exec(asNew bool, now int64) ([]*models.Metric, error) {
+	if n := atomic.AddInt32(&p.running, 1); n > 1 && !p.overlap {
+		atomic.AddInt32(&p.running, -1)
+		return nil, ErrPluginRunning
+	}
+	defer atomic.AddInt32(&p.running, -1)
+
+	if err := p.verify(); err != nil {
+		return nil, err
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
 	defer cancel()
@@ -94,6 +132,9 @@ func (p *Plugin) Exec(asNew bool) ([]*models.Metric, error) {
 		stderr = bytes.NewBuffer(nil)
 	)
 	cmd := exec.CommandContext(ctx, p.File)
+	if err := p.command(cmd); err != nil {
+		return nil, err
+	}
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
 
@@ -113,18 +154,56 @@ func (p *Plugin) Exec(asNew bool) ([]*models.Metric, error) {
 	if stdout.Len() == 0 {
 		return nil, nil
 	}
+	return p.parse(stdout.Bytes(), asNew, now)
+}
+
+// parse parses output by plugin format,
+// errors of bad lines in prometheus or influxdb output are written to log file
+func (p *Plugin) parse(output []byte, asNew bool, now int64) ([]*models.Metric, error) {
+	format := p.Format
+	if format == FormatAuto {
+		format = DetectFormat(output)
+	}
+	switch format {
+	case FormatPrometheus, FormatInflux:
+		var (
+			metrics []*models.Metric
+			errs    []error
+		)
+		if format == FormatPrometheus {
+			metrics, errs = ParsePrometheus(output, now, int(p.Cycle))
+		} else {
+			metrics, errs = ParseInflux(output, now, int(p.Cycle))
+		}
+		if len(errs) > 0 {
+			buf := bytes.NewBuffer(nil)
+			for _, err := range errs {
+				buf.WriteString(format + " parse error: " + err.Error() + "\n")
+			}
+			p.writeLog(buf.Bytes())
+		}
+		if len(metrics) == 0 && len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return metrics, nil
+	}
+	if p.Format == FormatJSON {
+		// json format is declared by plugin, old or new metric objects are detected by output,
+		// not by process-wide flag of plugins without format
+		asNew = !isMetricRawJSON(output)
+	}
 	if asNew {
 		var metrics []*models.Metric
-		if err = json.Unmarshal(stdout.Bytes(), &metrics); err != nil {
-			p.writeLog(stdout.Bytes())
+		if err := json.Unmarshal(output, &metrics); err != nil {
+			p.writeLog(output)
 			return nil, err
 		}
 		return metrics, nil
 	}
 
 	var metricsOld []*models.MetricRaw
-	if err = json.Unmarshal(stdout.Bytes(), &metricsOld); err != nil {
-		p.writeLog(stdout.Bytes())
+	if err := json.Unmarshal(output, &metricsOld); err != nil {
+		p.writeLog(output)
 		return nil, err
 	}
 	if len(metricsOld) == 0 {
@@ -132,10 +211,13 @@ func (p *Plugin) Exec(asNew bool) ([]*models.Metric, error) {
 	}
 	metrics := make([]*models.Metric, 0, len(metricsOld))
 	for _, old := range metricsOld {
-		m, err := old.ToNew()
+		m, err := counterRater.ToNew(old)
 		if err != nil {
 			return nil, err
 		}
+		if m == nil {
+			continue
+		}
 		if m.Step == 0 {
 			m.Step = int(p.Cycle)
 		}
@@ -54,6 +54,7 @@ func Version() string {
 var (
 	plugins     = make(map[string]*Plugin)
 	pluginsLock sync.RWMutex
+	execQueue   chan<- []*models.Metric
 
 	log = zaplog.Zap("plugins")
 )
@@ -64,17 +65,30 @@ func SyncScan(interval time.Duration) {
 	defer ticker.Stop()
 	for {
 		ScanFiles()
+		if m := integrityMetric(int(interval.Seconds())); m != nil {
+			pluginsLock.RLock()
+			queue := execQueue
+			pluginsLock.RUnlock()
+			if queue != nil {
+				queue <- []*models.Metric{m}
+			}
+		}
 		<-ticker.C
 	}
 }
 
 // ScanFiles reads plugins files list to memory
 func ScanFiles() {
+	var stopping []*daemon
+	defer func() {
+		waitDaemons(stopping)
+	}()
 	pluginsLock.Lock()
 	defer pluginsLock.Unlock()
 
 	now := time.Now().Unix()
 	nowDirs := pluginRunDirList
+	nowMismatches := make(map[string]string)
 	for _, dir := range nowDirs {
 		fullDir := filepath.Join(pluginDir, dir)
 		fullDir, err := getCorrectDir(fullDir, serverinfo.Hostname())
@@ -93,24 +107,57 @@ func ScanFiles() {
 			if info.IsDir() {
 				return nil
 			}
-			if !isAllowFilename(info.Name()) {
+			if isManifestFile(fpath) || isSignFile(fpath) {
+				return nil
+			}
+			var (
+				manifestFile = ManifestFile(fpath)
+				manifestTime int64
+			)
+			if stat, err := os.Stat(manifestFile); err == nil && !stat.IsDir() {
+				manifestTime = stat.ModTime().UnixNano()
+			}
+			if manifestTime == 0 && !isAllowFilename(info.Name()) {
 				log.Debug("ignore-file", "file", info.Name())
+				delete(plugins, fpath) // manifest is removed
 				return nil
 			}
+			ig := integrities[dir]
+			if ig != nil {
+				if err := verifyPlugin(ig, fullDir, fpath, manifestTime > 0); err != nil {
+					log.Warn("integrity-fail", "file", fpath, "error", err)
+					nowMismatches[fpath] = err.Error()
+					delete(plugins, fpath)
+					return nil
+				}
+			}
 			plugin := plugins[fpath]
+			if plugin != nil && plugin.manifestTime != manifestTime {
+				plugin = nil // manifest is changed, re-create plugin
+				log.Info("manifest-changed", "file", fpath)
+			}
 			if plugin == nil {
 				logFile := filepath.Join(pluginDir, strings.Replace(filepath.ToSlash(fpath), "/", "_", -1)) + ".log"
 				var err error
-				plugin, err = NewPlugin(fpath, logFile, info)
+				if manifestTime > 0 {
+					var manifest *Manifest
+					if manifest, err = ReadManifest(manifestFile); err == nil {
+						plugin, err = NewPluginWithManifest(fpath, logFile, info, manifest)
+					}
+				} else {
+					plugin, err = NewPlugin(fpath, logFile, info)
+				}
 				if err != nil {
 					log.Warn("new-plugin-error", "file", fpath, "error", err)
 					return nil
 				}
+				plugin.manifestTime = manifestTime
 				plugins[fpath] = plugin
 				log.Debug("new-plugin", "file", fpath)
 			}
 			plugin.ReloadTime = now
 			plugin.FileModTime = info.ModTime().Unix()
+			plugin.setIntegrity(ig, fullDir)
 			return nil
 		})
 		log.Debug("reload-dir", "dir", fullDir)
@@ -121,7 +168,9 @@ func ScanFiles() {
 			log.Info("delete-plugin", "file", key)
 		}
 	}
-	log.Info("reload", "dir", pluginRunDirList, "plugins", len(plugins))
+	mismatches = nowMismatches
+	stopping = syncDaemons()
+	log.Info("reload", "dir", pluginRunDirList, "plugins", len(plugins), "daemons", len(daemons), "mismatches", len(mismatches))
 	pluginsCount.Set(int64(len(plugins)))
 }
 
@@ -155,8 +204,14 @@ func isAllowFilename(filename string) bool {
 	return false
 }
 
-// Exec starts plugins execution
+// Exec starts plugins execution, and starts daemon plugins
 func Exec(mCh chan<- []*models.Metric) {
+	pluginsLock.Lock()
+	execQueue = mCh
+	stopping := syncDaemons()
+	pluginsLock.Unlock()
+	waitDaemons(stopping)
+
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 
@@ -180,15 +235,22 @@ func execFiles(mCh chan<- []*models.Metric, now int64) {
 	defer pluginsLock.RUnlock()
 
 	for _, plugin := range plugins {
-		if plugin.ShouldExec(now) {
+		if !plugin.IsDaemon() && plugin.ShouldExec(now) {
 			go func(plugin *Plugin) {
 				st := time.Now()
 				metrics, err := plugin.Exec(parsedAsNew)
+				if err != ErrPluginRunning {
+					plugin.setStatus(time.Since(st), metrics, err)
+				}
 				if err != nil {
 					if err == ErrPluginExecBlank {
 						log.Debug("exec-0-data", "file", plugin.File)
 						return
 					}
+					if err == ErrPluginRunning {
+						log.Warn("exec-overlap", "file", plugin.File)
+						return
+					}
 					log.Warn("exec-error", "file", plugin.File, "error", err)
 					pluginsFailCount.Incr(1)
 					return
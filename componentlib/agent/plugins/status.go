@@ -0,0 +1,110 @@
+package plugins
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/models"
+)
+
+var (
+	// ErrPluginNotFound means no plugin of the file in manager
+	ErrPluginNotFound = errors.New("plugin-not-found")
+	// ErrPluginIsDaemon means daemon plugin can't be executed on demand
+	ErrPluginIsDaemon = errors.New("plugin-is-daemon")
+)
+
+// Status is execution status of a plugin
+type Status struct {
+	File         string   `json:"file"`
+	Cycle        int64    `json:"cycle"`
+	Format       string   `json:"format,omitempty"`
+	Daemon       bool     `json:"daemon,omitempty"`
+	LastExecTime int64    `json:"last_exec_time"`
+	LastDuration int64    `json:"last_duration_ms"`
+	LastError    string   `json:"last_error,omitempty"`
+	LastMetrics  []string `json:"last_metrics"`
+	FailCount    int64    `json:"fail_count"`
+}
+
+// setStatus records result of one execution
+func (p *Plugin) setStatus(du time.Duration, metrics []*models.Metric, err error) {
+	p.statusLock.Lock()
+	defer p.statusLock.Unlock()
+	p.lastDuration = du
+	if err != nil {
+		p.lastError = err.Error()
+		p.failCount++
+		return
+	}
+	p.lastError = ""
+	record := make(map[string]struct{})
+	for _, m := range metrics {
+		record[m.Name] = struct{}{}
+	}
+	p.lastMetrics = keysOfMap(record)
+	sort.Strings(p.lastMetrics)
+}
+
+// Status returns execution status of plugin
+func (p *Plugin) Status() *Status {
+	p.statusLock.Lock()
+	defer p.statusLock.Unlock()
+	st := &Status{
+		File:         p.File,
+		Cycle:        p.Cycle,
+		Format:       p.Format,
+		Daemon:       p.IsDaemon(),
+		LastExecTime: p.LastExecTime,
+		LastDuration: p.lastDuration.Nanoseconds() / 1e6,
+		LastError:    p.lastError,
+		LastMetrics:  p.lastMetrics,
+		FailCount:    p.failCount,
+	}
+	if st.LastMetrics == nil {
+		st.LastMetrics = []string{}
+	}
+	return st
+}
+
+// Statuses returns status of all plugins in manager, sorted by file
+func Statuses() []*Status {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+
+	list := make([]*Status, 0, len(plugins))
+	for _, plugin := range plugins {
+		list = append(list, plugin.Status())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].File < list[j].File
+	})
+	return list
+}
+
+// Run executes plugin of the file on demand and returns parsed metrics,
+// the metrics are not sent and the schedule of plugin is not changed.
+// file is full path or base name of plugin file
+func Run(file string) ([]*models.Metric, error) {
+	pluginsLock.RLock()
+	plugin := plugins[file]
+	if plugin == nil {
+		for _, p := range plugins {
+			if filepath.Base(p.File) == file {
+				plugin = p
+				break
+			}
+		}
+	}
+	pluginsLock.RUnlock()
+
+	if plugin == nil {
+		return nil, ErrPluginNotFound
+	}
+	if plugin.IsDaemon() {
+		return nil, ErrPluginIsDaemon
+	}
+	return plugin.exec(parsedAsNew, time.Now().Unix())
+}
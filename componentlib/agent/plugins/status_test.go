@@ -0,0 +1,70 @@
+package plugins
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStatus(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "plugins")
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "30_status.sh")
+	ioutil.WriteFile(file, []byte("#!/bin/bash\necho 'status value=1'\n"), 0755)
+	p, err := NewPlugin(file, "", new(fileInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("status.set", t, func() {
+		p.setStatus(time.Millisecond*20, []*models.Metric{{Name: "b"}, {Name: "a"}, {Name: "b"}}, nil)
+		st := p.Status()
+		So(st.Cycle, ShouldEqual, 30)
+		So(st.LastDuration, ShouldEqual, 20)
+		So(st.LastMetrics, ShouldResemble, []string{"a", "b"})
+		So(st.FailCount, ShouldEqual, 0)
+
+		p.setStatus(time.Second, nil, errors.New("exit status 1"))
+		st = p.Status()
+		So(st.LastError, ShouldEqual, "exit status 1")
+		So(st.LastMetrics, ShouldResemble, []string{"a", "b"})
+		So(st.FailCount, ShouldEqual, 1)
+	})
+
+	Convey("status.run", t, func() {
+		pluginsLock.Lock()
+		plugins[file] = p
+		pluginsLock.Unlock()
+		defer func() {
+			pluginsLock.Lock()
+			delete(plugins, file)
+			pluginsLock.Unlock()
+		}()
+
+		var st *Status
+		for _, s := range Statuses() {
+			if s.File == file {
+				st = s
+			}
+		}
+		So(st, ShouldNotBeNil)
+		So(st.FailCount, ShouldEqual, 1)
+
+		lastExec := p.LastExecTime
+		metrics, err := Run("30_status.sh")
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 1)
+		So(metrics[0].Name, ShouldEqual, "status")
+		So(p.LastExecTime, ShouldEqual, lastExec)
+
+		_, err = Run(filepath.Join(dir, "60_none.sh"))
+		So(err, ShouldEqual, ErrPluginNotFound)
+	})
+}
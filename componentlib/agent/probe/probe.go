@@ -0,0 +1,341 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/expvar"
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/corelib/zaplog"
+)
+
+const (
+	probeMetricName = "probe"
+	maxBodySize     = 1024 * 1024
+)
+
+var (
+	// DefaultTimeout is timeout of probe if not set
+	DefaultTimeout = time.Second * 5
+
+	// ErrUnknownType means probe type is not supported
+	ErrUnknownType = errors.New("unknown-type")
+	// ErrStatusMismatch means http status is not expected
+	ErrStatusMismatch = errors.New("status-mismatch")
+	// ErrBodyMismatch means http body does not match regexp
+	ErrBodyMismatch = errors.New("body-mismatch")
+	// ErrAnswerMismatch means no dns answer contains expected string
+	ErrAnswerMismatch = errors.New("answer-mismatch")
+
+	probes     []*probe
+	probesLock sync.Mutex
+	rootCAs    *x509.CertPool // system roots if nil
+
+	log = zaplog.Zap("probe")
+
+	probeCount     = expvar.NewDiff("probe.run")
+	probeFailCount = expvar.NewDiff("probe.fail")
+)
+
+func init() {
+	expvar.Register(probeCount, probeFailCount)
+}
+
+// probe is probe target with compiled body regexp
+type probe struct {
+	*models.Probe
+	timeout time.Duration
+	body    *regexp.Regexp
+}
+
+// result is result of one probe
+type result struct {
+	latency  time.Duration
+	status   int
+	certDays float64
+	hasCert  bool
+	answers  int
+	err      error
+}
+
+// SetProbes sets probe targets, invalid targets are ignored
+func SetProbes(targets []*models.Probe) {
+	var list []*probe
+	for _, t := range targets {
+		if t.Name == "" || t.Target == "" {
+			log.Warn("probe-invalid", "name", t.Name, "target", t.Target)
+			continue
+		}
+		switch t.Type {
+		case models.ProbeHTTP, models.ProbeTCP, models.ProbeDNS, models.ProbeTLS:
+		default:
+			log.Warn("probe-invalid", "name", t.Name, "type", t.Type)
+			continue
+		}
+		p := &probe{Probe: t, timeout: DefaultTimeout}
+		if t.Timeout > 0 {
+			p.timeout = time.Duration(t.Timeout) * time.Second
+		}
+		if t.Body != "" {
+			reg, err := regexp.Compile(t.Body)
+			if err != nil {
+				log.Warn("probe-body-error", "name", t.Name, "error", err)
+				continue
+			}
+			p.body = reg
+		}
+		list = append(list, p)
+	}
+	probesLock.Lock()
+	probes = list
+	probesLock.Unlock()
+	log.Info("set-probes", "probes", len(list))
+}
+
+// Run runs probes in interval concurrently
+func Run(interval time.Duration, ch chan<- []*models.Metric) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		now := <-ticker.C
+		if metrics := runOnce(now, int(interval.Seconds())); len(metrics) > 0 && ch != nil {
+			ch <- metrics
+		}
+	}
+}
+
+func runOnce(now time.Time, step int) []*models.Metric {
+	probesLock.Lock()
+	list := probes
+	probesLock.Unlock()
+	if len(list) == 0 {
+		return nil
+	}
+
+	var (
+		wg      sync.WaitGroup
+		metrics = make([]*models.Metric, len(list))
+	)
+	for i, p := range list {
+		wg.Add(1)
+		go func(i int, p *probe) {
+			defer wg.Done()
+			metrics[i] = p.metric(p.run(), now.Unix(), step)
+		}(i, p)
+	}
+	wg.Wait()
+	probeCount.Incr(int64(len(list)))
+	return metrics
+}
+
+func (p *probe) run() *result {
+	var r *result
+	st := time.Now()
+	switch p.Type {
+	case models.ProbeHTTP:
+		r = p.runHTTP()
+	case models.ProbeTCP:
+		r = p.runTCP()
+	case models.ProbeDNS:
+		r = p.runDNS()
+	case models.ProbeTLS:
+		r = p.runTLS()
+	default:
+		r = &result{err: ErrUnknownType}
+	}
+	r.latency = time.Since(st)
+	if r.err != nil {
+		probeFailCount.Incr(1)
+		log.Warn("probe-fail", "name", p.Name, "type", p.Type, "target", p.Target, "error", r.err)
+	}
+	return r
+}
+
+func (p *probe) metric(r *result, now int64, step int) *models.Metric {
+	m := &models.Metric{
+		Name: probeMetricName,
+		Time: now,
+		Step: step,
+		Tags: map[string]string{
+			"name":   p.Name,
+			"type":   p.Type,
+			"target": p.Target,
+		},
+		Fields: map[string]interface{}{
+			"success": 0,
+			"latency": float64(r.latency.Nanoseconds()) / 1e6, // ms
+		},
+	}
+	if r.err == nil {
+		m.Value = 1
+		m.Fields["success"] = 1
+	}
+	if r.status > 0 {
+		m.Fields["status"] = r.status
+	}
+	if r.hasCert {
+		m.Fields["cert_days"] = r.certDays
+	}
+	if p.Type == models.ProbeDNS {
+		m.Fields["answers"] = r.answers
+	}
+	return m
+}
+
+func certDays(certs []*x509.Certificate) float64 {
+	if len(certs) == 0 {
+		return 0
+	}
+	return time.Until(certs[0].NotAfter).Hours() / 24
+}
+
+func (p *probe) runHTTP() *result {
+	r := new(result)
+	client := &http.Client{
+		Timeout: p.timeout,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: rootCAs},
+			DisableKeepAlives: true,
+		},
+	}
+	resp, err := client.Get(p.Target)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	defer resp.Body.Close()
+	r.status = resp.StatusCode
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		r.hasCert = true
+		r.certDays = certDays(resp.TLS.PeerCertificates)
+	}
+	if p.Status > 0 && resp.StatusCode != p.Status || p.Status == 0 && resp.StatusCode >= 400 {
+		r.err = ErrStatusMismatch
+		return r
+	}
+	if p.body != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+		if err != nil {
+			r.err = err
+			return r
+		}
+		if !p.body.Match(body) {
+			r.err = ErrBodyMismatch
+		}
+	}
+	return r
+}
+
+func (p *probe) runTCP() *result {
+	r := new(result)
+	conn, err := net.DialTimeout("tcp", p.Target, p.timeout)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	conn.Close()
+	return r
+}
+
+func (p *probe) runDNS() *result {
+	r := new(result)
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	var (
+		resolver net.Resolver
+		answers  []string
+		err      error
+	)
+	switch strings.ToUpper(p.Record) {
+	case "", "A", "AAAA":
+		answers, err = resolver.LookupHost(ctx, p.Target)
+	case "CNAME":
+		var cname string
+		if cname, err = resolver.LookupCNAME(ctx, p.Target); err == nil {
+			answers = []string{cname}
+		}
+	case "MX":
+		var mxs []*net.MX
+		if mxs, err = resolver.LookupMX(ctx, p.Target); err == nil {
+			for _, mx := range mxs {
+				answers = append(answers, mx.Host)
+			}
+		}
+	case "NS":
+		var nss []*net.NS
+		if nss, err = resolver.LookupNS(ctx, p.Target); err == nil {
+			for _, ns := range nss {
+				answers = append(answers, ns.Host)
+			}
+		}
+	case "TXT":
+		answers, err = resolver.LookupTXT(ctx, p.Target)
+	default:
+		err = fmt.Errorf("unknown record %s", p.Record)
+	}
+	if err != nil {
+		r.err = err
+		return r
+	}
+	r.answers = len(answers)
+	if p.Expect != "" {
+		r.err = ErrAnswerMismatch
+		for _, answer := range answers {
+			if strings.Contains(answer, p.Expect) {
+				r.err = nil
+				break
+			}
+		}
+	}
+	return r
+}
+
+// runTLS handshakes without verifying to get certificate days even if it's invalid,
+// then verifies certificate chain and hostname
+func (p *probe) runTLS() *result {
+	r := new(result)
+	host, _, err := net.SplitHostPort(p.Target)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	dialer := &net.Dialer{Timeout: p.timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", p.Target, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		r.err = err
+		return r
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		r.err = errors.New("no certificate")
+		return r
+	}
+	r.hasCert = true
+	r.certDays = certDays(certs)
+	opts := x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         rootCAs,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, r.err = certs[0].Verify(opts)
+	return r
+}
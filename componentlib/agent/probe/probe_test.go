@@ -0,0 +1,132 @@
+package probe
+
+import (
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/corelib/zaplog"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func init() {
+	log = zaplog.Null()
+}
+
+func TestProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/404" {
+			rw.WriteHeader(404)
+			return
+		}
+		rw.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	tcpAddr := listener.Addr().String()
+	listener.Close()
+
+	Convey("probe.set", t, func() {
+		SetProbes([]*models.Probe{
+			{Name: "a", Type: "icmp", Target: "127.0.0.1"},
+			{Name: "b", Type: models.ProbeHTTP},
+			{Name: "c", Type: models.ProbeHTTP, Target: server.URL, Body: "("},
+		})
+		So(probes, ShouldBeEmpty)
+		So(runOnce(time.Now(), 60), ShouldBeNil)
+	})
+
+	Convey("probe.http", t, func() {
+		r := (&probe{Probe: &models.Probe{Type: models.ProbeHTTP, Target: server.URL}, timeout: time.Second}).run()
+		So(r.err, ShouldBeNil)
+		So(r.status, ShouldEqual, 200)
+
+		r = (&probe{Probe: &models.Probe{Type: models.ProbeHTTP, Target: server.URL + "/404"}, timeout: time.Second}).run()
+		So(r.err, ShouldEqual, ErrStatusMismatch)
+		r = (&probe{Probe: &models.Probe{Type: models.ProbeHTTP, Target: server.URL + "/404", Status: 404}, timeout: time.Second}).run()
+		So(r.err, ShouldBeNil)
+
+		SetProbes([]*models.Probe{{Name: "api", Type: models.ProbeHTTP, Target: server.URL, Body: `"status":"(ok|fail)"`}})
+		r = probes[0].run()
+		So(r.err, ShouldBeNil)
+		SetProbes([]*models.Probe{{Name: "api", Type: models.ProbeHTTP, Target: server.URL, Body: "error"}})
+		r = probes[0].run()
+		So(r.err, ShouldEqual, ErrBodyMismatch)
+	})
+
+	Convey("probe.https", t, func() {
+		r := (&probe{Probe: &models.Probe{Type: models.ProbeHTTP, Target: tlsServer.URL}, timeout: time.Second}).run()
+		So(r.err, ShouldNotBeNil) // unknown authority
+
+		rootCAs = x509.NewCertPool()
+		rootCAs.AddCert(tlsServer.Certificate())
+		defer func() {
+			rootCAs = nil
+		}()
+		r = (&probe{Probe: &models.Probe{Type: models.ProbeHTTP, Target: tlsServer.URL}, timeout: time.Second}).run()
+		So(r.err, ShouldBeNil)
+		So(r.hasCert, ShouldBeTrue)
+		So(r.certDays, ShouldBeGreaterThan, 30)
+	})
+
+	Convey("probe.tls", t, func() {
+		addr := strings.TrimPrefix(tlsServer.URL, "https://")
+		r := (&probe{Probe: &models.Probe{Type: models.ProbeTLS, Target: addr}, timeout: time.Second}).run()
+		So(r.err, ShouldNotBeNil)
+		So(r.hasCert, ShouldBeTrue)
+		So(r.certDays, ShouldBeGreaterThan, 30)
+
+		rootCAs = x509.NewCertPool()
+		rootCAs.AddCert(tlsServer.Certificate())
+		defer func() {
+			rootCAs = nil
+		}()
+		r = (&probe{Probe: &models.Probe{Type: models.ProbeTLS, Target: addr}, timeout: time.Second}).run()
+		So(r.err, ShouldBeNil)
+	})
+
+	Convey("probe.tcp", t, func() {
+		addr := strings.TrimPrefix(server.URL, "http://")
+		r := (&probe{Probe: &models.Probe{Type: models.ProbeTCP, Target: addr}, timeout: time.Second}).run()
+		So(r.err, ShouldBeNil)
+
+		r = (&probe{Probe: &models.Probe{Type: models.ProbeTCP, Target: tcpAddr}, timeout: time.Second}).run()
+		So(r.err, ShouldNotBeNil)
+	})
+
+	Convey("probe.dns", t, func() {
+		r := (&probe{Probe: &models.Probe{Type: models.ProbeDNS, Target: "localhost", Expect: "127.0.0.1"}, timeout: time.Second}).run()
+		So(r.err, ShouldBeNil)
+		So(r.answers, ShouldBeGreaterThan, 0)
+
+		r = (&probe{Probe: &models.Probe{Type: models.ProbeDNS, Target: "localhost", Expect: "10.0.0.1"}, timeout: time.Second}).run()
+		So(r.err, ShouldEqual, ErrAnswerMismatch)
+
+		r = (&probe{Probe: &models.Probe{Type: models.ProbeDNS, Target: "localhost", Record: "SRV"}, timeout: time.Second}).run()
+		So(r.err, ShouldNotBeNil)
+	})
+
+	Convey("probe.run", t, func() {
+		SetProbes([]*models.Probe{
+			{Name: "api", Type: models.ProbeHTTP, Target: server.URL},
+			{Name: "down", Type: models.ProbeTCP, Target: tcpAddr, Timeout: 1},
+		})
+		defer SetProbes(nil)
+		metrics := runOnce(time.Now(), 60)
+		So(metrics, ShouldHaveLength, 2)
+		So(metrics[0].Name, ShouldEqual, probeMetricName)
+		So(metrics[0].Tags["name"], ShouldEqual, "api")
+		So(metrics[0].Value, ShouldEqual, 1)
+		So(metrics[0].Fields["status"], ShouldEqual, 200)
+		So(metrics[1].Value, ShouldEqual, 0)
+		So(metrics[1].Fields["success"], ShouldEqual, 0)
+		So(metrics[1].Fields, ShouldContainKey, "latency")
+	})
+}
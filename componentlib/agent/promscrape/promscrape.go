@@ -0,0 +1,219 @@
+package promscrape
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/baishancloud/mallard/componentlib/agent/plugins"
+	"github.com/baishancloud/mallard/corelib/expvar"
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/corelib/zaplog"
+)
+
+const (
+	scrapeMetricName = "prom_scrape"
+	maxBodySize      = 10 * 1024 * 1024
+)
+
+var (
+	// DefaultTimeout is timeout of scraping if not set
+	DefaultTimeout = time.Second * 10
+
+	targets     []*Target
+	targetsLock sync.Mutex
+
+	log = zaplog.Zap("promscrape")
+
+	scrapeCount     = expvar.NewDiff("promscrape.scrape")
+	scrapeFailCount = expvar.NewDiff("promscrape.fail")
+	sampleCount     = expvar.NewDiff("promscrape.sample")
+	badLineCount    = expvar.NewDiff("promscrape.bad_line")
+)
+
+func init() {
+	expvar.Register(scrapeCount, scrapeFailCount, sampleCount, badLineCount)
+}
+
+// Target is exporter to scrape
+type Target struct {
+	Name    string            `json:"name"`              // tag value of scraped metrics
+	URL     string            `json:"url"`               // such as http://127.0.0.1:9100/metrics
+	Timeout int               `json:"timeout,omitempty"` // seconds
+	Allow   []string          `json:"allow,omitempty"`   // metric name patterns to keep, all if empty
+	Deny    []string          `json:"deny,omitempty"`    // metric name patterns to drop
+	Labels  map[string]string `json:"labels,omitempty"`  // label to tag name, empty tag name drops the label
+}
+
+// isAllowed checks metric name matches allow patterns and does not match deny patterns
+func (t *Target) isAllowed(name string) bool {
+	if len(t.Allow) > 0 && !matchAny(t.Allow, name) {
+		return false
+	}
+	return !matchAny(t.Deny, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTargets sets exporters to scrape, invalid targets are ignored
+func SetTargets(list []*Target) {
+	var valid []*Target
+	for _, t := range list {
+		if t.Name == "" || t.URL == "" {
+			log.Warn("target-invalid", "name", t.Name, "url", t.URL)
+			continue
+		}
+		valid = append(valid, t)
+	}
+	targetsLock.Lock()
+	targets = valid
+	targetsLock.Unlock()
+	log.Info("set-targets", "targets", len(valid))
+}
+
+// Scrape scrapes targets in interval concurrently
+func Scrape(interval time.Duration, ch chan<- []*models.Metric) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		now := <-ticker.C
+		if metrics := scrapeOnce(now, int(interval.Seconds())); len(metrics) > 0 && ch != nil {
+			ch <- metrics
+		}
+	}
+}
+
+func scrapeOnce(now time.Time, step int) []*models.Metric {
+	targetsLock.Lock()
+	list := targets
+	targetsLock.Unlock()
+	if len(list) == 0 {
+		return nil
+	}
+
+	var (
+		wg      sync.WaitGroup
+		results = make([][]*models.Metric, len(list))
+	)
+	for i, t := range list {
+		wg.Add(1)
+		go func(i int, t *Target) {
+			defer wg.Done()
+			results[i] = t.scrape(now.Unix(), step)
+		}(i, t)
+	}
+	wg.Wait()
+	scrapeCount.Incr(int64(len(list)))
+
+	var metrics []*models.Metric
+	for _, ms := range results {
+		metrics = append(metrics, ms...)
+	}
+	return metrics
+}
+
+// scrape returns samples as metrics and scraping status metric with up, duration and samples
+func (t *Target) scrape(now int64, step int) []*models.Metric {
+	st := time.Now()
+	metrics, err := t.fetch(now, step)
+	du := time.Since(st)
+
+	status := &models.Metric{
+		Name: scrapeMetricName,
+		Time: now,
+		Step: step,
+		Tags: map[string]string{
+			"target": t.Name,
+		},
+		Fields: map[string]interface{}{
+			"up":       0,
+			"duration": float64(du.Nanoseconds()) / 1e6, // ms
+			"samples":  len(metrics),
+		},
+	}
+	if err != nil {
+		scrapeFailCount.Incr(1)
+		log.Warn("scrape-error", "target", t.Name, "url", t.URL, "error", err)
+		metrics = nil
+		status.Fields["samples"] = 0
+	} else {
+		status.Value = 1
+		status.Fields["up"] = 1
+		sampleCount.Incr(int64(len(metrics)))
+	}
+	return append(metrics, status)
+}
+
+func (t *Target) fetch(now int64, step int) ([]*models.Metric, error) {
+	timeout := DefaultTimeout
+	if t.Timeout > 0 {
+		timeout = time.Duration(t.Timeout) * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+	req, err := http.NewRequest("GET", t.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("bad status %d", resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return nil, err
+	}
+	// bad lines are skipped and counted, other samples are kept
+	parsed, errs := plugins.ParsePrometheus(data, now, step)
+	if len(errs) > 0 {
+		badLineCount.Incr(int64(len(errs)))
+		log.Debug("parse-error", "target", t.Name, "errors", len(errs), "error", errs[0])
+	}
+	var metrics []*models.Metric
+	for _, m := range parsed {
+		if !t.isAllowed(m.Name) {
+			continue
+		}
+		metrics = append(metrics, t.metric(m, now))
+	}
+	return metrics, nil
+}
+
+// metric relabels tags and sets scraping time to parsed metric
+func (t *Target) metric(m *models.Metric, now int64) *models.Metric {
+	tags := make(map[string]string, len(m.Tags)+1)
+	for label, value := range m.Tags {
+		tag := label
+		if name, ok := t.Labels[label]; ok {
+			if name == "" {
+				continue
+			}
+			tag = name
+		}
+		tags[tag] = value
+	}
+	tags["target"] = t.Name
+	m.Tags = tags
+	m.Time = now
+	if m.Fields == nil {
+		m.Fields = map[string]interface{}{
+			"value": m.Value,
+		}
+	}
+	return m
+}
@@ -0,0 +1,97 @@
+package promscrape
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/corelib/zaplog"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func init() {
+	log = zaplog.Null()
+}
+
+const testText = `# HELP node_load1 1m load average.
+# TYPE node_load1 gauge
+node_load1 0.5
+# TYPE node_cpu_seconds_total counter
+node_cpu_seconds_total{cpu="0",mode="idle"} 1234.5 1500000000000
+node_cpu_seconds_total{cpu="0",mode="user",} 10
+go_gc_duration_seconds{quantile="0.5"} NaN
+node_bad{a="1" 1
+http_requests_total{path="/a\"b\\c",code="200"} 3
+`
+
+func findMetric(metrics []*models.Metric, name string) *models.Metric {
+	for _, m := range metrics {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestAllow(t *testing.T) {
+	Convey("allow", t, func() {
+		tg := &Target{Allow: []string{"node_*"}, Deny: []string{"node_cpu_*"}}
+		So(tg.isAllowed("node_load1"), ShouldBeTrue)
+		So(tg.isAllowed("node_cpu_seconds_total"), ShouldBeFalse)
+		So(tg.isAllowed("go_goroutines"), ShouldBeFalse)
+		So((&Target{}).isAllowed("go_goroutines"), ShouldBeTrue)
+	})
+}
+
+func TestScrape(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			rw.WriteHeader(404)
+			return
+		}
+		rw.Write([]byte(testText))
+	}))
+	defer server.Close()
+
+	Convey("scrape", t, func() {
+		SetTargets([]*Target{
+			{Name: "node", URL: server.URL + "/metrics", Deny: []string{"http_*"}, Labels: map[string]string{"cpu": "core", "mode": ""}},
+			{Name: "bad", URL: server.URL + "/404"},
+			{Name: "invalid"},
+		})
+		defer SetTargets(nil)
+		So(targets, ShouldHaveLength, 2)
+
+		bad := badLineCount.Count()
+		metrics := scrapeOnce(time.Unix(1500000000, 0), 60)
+		So(metrics, ShouldHaveLength, 5)
+		So(badLineCount.Count()-bad, ShouldEqual, 1)
+
+		m := findMetric(metrics, "node_load1")
+		So(m.Value, ShouldEqual, 0.5)
+		So(m.Time, ShouldEqual, 1500000000)
+		So(m.Step, ShouldEqual, 60)
+		So(m.Tags["target"], ShouldEqual, "node")
+		So(m.Fields["value"], ShouldEqual, 0.5)
+		cpu := findMetric(metrics, "node_cpu_seconds_total")
+		So(cpu.Tags, ShouldResemble, map[string]string{"core": "0", "target": "node"})
+		So(cpu.Time, ShouldEqual, 1500000000)
+		So(findMetric(metrics, "http_requests_total"), ShouldBeNil)
+
+		var status []*models.Metric
+		for _, m := range metrics {
+			if m.Name == scrapeMetricName {
+				status = append(status, m)
+			}
+		}
+		So(status, ShouldHaveLength, 2)
+		So(status[0].Tags["target"], ShouldEqual, "node")
+		So(status[0].Value, ShouldEqual, 1)
+		So(status[0].Fields["samples"], ShouldEqual, 3)
+		So(status[1].Tags["target"], ShouldEqual, "bad")
+		So(status[1].Value, ShouldEqual, 0)
+		So(status[1].Fields["up"], ShouldEqual, 0)
+	})
+}
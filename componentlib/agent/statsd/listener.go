@@ -0,0 +1,88 @@
+package statsd
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"time"
+)
+
+const (
+	maxPacketSize  = 65535
+	tcpReadTimeout = time.Minute * 5
+)
+
+// ListenUDP receives statsd packets on udp address
+func ListenUDP(addr string) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		log.Warn("listen-udp-error", "addr", addr, "error", err)
+		return
+	}
+	log.Info("listen-udp", "addr", addr)
+	serveUDP(conn)
+}
+
+// ListenTCP receives statsd lines on tcp address
+func ListenTCP(addr string) {
+	listen(addr, "statsd", parseStatsd)
+}
+
+// ListenGraphite receives graphite plaintext lines on tcp address
+func ListenGraphite(addr string) {
+	listen(addr, "graphite", parseGraphite)
+}
+
+func listen(addr string, protocol string, parseFn func([]byte) error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Warn("listen-tcp-error", "addr", addr, "protocol", protocol, "error", err)
+		return
+	}
+	log.Info("listen-tcp", "addr", addr, "protocol", protocol)
+	serveTCP(ln, parseFn)
+}
+
+func serveUDP(conn net.PacketConn) {
+	defer conn.Close()
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Warn("read-udp-error", "error", err)
+			return
+		}
+		for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+			parseLine(line, parseStatsd)
+		}
+	}
+}
+
+func serveTCP(ln net.Listener, parseFn func([]byte) error) {
+	defer ln.Close()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Warn("accept-error", "error", err)
+			return
+		}
+		go serveConn(conn, parseFn)
+	}
+}
+
+func serveConn(conn net.Conn, parseFn func([]byte) error) {
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(tcpReadTimeout))
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		parseLine(scanner.Bytes(), parseFn)
+		conn.SetReadDeadline(time.Now().Add(tcpReadTimeout))
+	}
+}
+
+func parseLine(line []byte, parseFn func([]byte) error) {
+	if err := parseFn(line); err != nil {
+		failCount.Incr(1)
+		log.Debug("parse-error", "line", string(line), "error", err)
+	}
+}
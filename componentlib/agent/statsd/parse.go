@@ -0,0 +1,90 @@
+package statsd
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// parseStatsd parses one statsd line as "name:value|type|@rate|#tag:value,tag2:value2",
+// tags are dogstatsd extension
+func parseStatsd(line []byte) error {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil
+	}
+	idx := bytes.IndexByte(line, ':')
+	if idx <= 0 {
+		return ErrBadLine
+	}
+	name := string(line[:idx])
+	parts := strings.Split(string(line[idx+1:]), "|")
+	if len(parts) < 2 || parts[0] == "" {
+		return ErrBadLine
+	}
+	var (
+		value = parts[0]
+		kind  = parts[1]
+		rate  = 1.0
+		tags  = make(map[string]string)
+	)
+	switch kind {
+	case kindCounter, kindGauge, kindTimer, kindSet:
+	case "h":
+		kind = kindTimer // histogram is the same as timer
+	default:
+		return ErrBadType
+	}
+	for _, part := range parts[2:] {
+		if strings.HasPrefix(part, "@") {
+			r, err := strconv.ParseFloat(part[1:], 64)
+			if err != nil || r <= 0 || r > 1 {
+				return ErrBadLine
+			}
+			rate = r
+			continue
+		}
+		if strings.HasPrefix(part, "#") {
+			for _, tag := range strings.Split(part[1:], ",") {
+				if tag == "" {
+					continue
+				}
+				kv := strings.SplitN(tag, ":", 2)
+				if len(kv) == 2 {
+					tags[kv[0]] = kv[1]
+				} else {
+					tags[kv[0]] = ""
+				}
+			}
+		}
+	}
+	// gauge value with sign as "+1" or "-1" is delta to current value
+	delta := kind == kindGauge && (value[0] == '+' || value[0] == '-')
+	return add(kind, name, tags, value, rate, delta)
+}
+
+// parseGraphite parses one graphite plaintext line as "path value timestamp",
+// path can contain tags as "path;tag=value;tag2=value2",
+// value is aggregated as gauge so timestamp is ignored
+func parseGraphite(line []byte) error {
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return nil
+	}
+	if len(fields) < 2 || len(fields) > 3 {
+		return ErrBadLine
+	}
+	parts := strings.Split(fields[0], ";")
+	if parts[0] == "" {
+		return ErrBadLine
+	}
+	tags := make(map[string]string)
+	for _, tag := range parts[1:] {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return ErrBadLine
+		}
+		tags[kv[0]] = kv[1]
+	}
+	return add(kindGauge, parts[0], tags, fields[1], 1, false)
+}
@@ -0,0 +1,214 @@
+package statsd
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baishancloud/mallard/componentlib/agent/serverinfo"
+	"github.com/baishancloud/mallard/corelib/expvar"
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/corelib/zaplog"
+)
+
+const (
+	kindCounter = "c"
+	kindGauge   = "g"
+	kindTimer   = "ms"
+	kindSet     = "s"
+
+	// maxTimerValues is max values of one timer in one interval, more values are dropped
+	maxTimerValues = 10000
+	// maxGaugeIdle is flushes to keep sending gauge value without updating
+	maxGaugeIdle = 10
+)
+
+var (
+	// ErrBadLine means line is not valid statsd or graphite format
+	ErrBadLine = errors.New("bad-line")
+	// ErrBadValue means value is not number
+	ErrBadValue = errors.New("bad-value")
+	// ErrBadType means statsd type is unknown
+	ErrBadType = errors.New("bad-type")
+
+	entries     = make(map[string]*entry)
+	entriesLock sync.Mutex
+
+	log = zaplog.Zap("statsd")
+
+	recvCount = expvar.NewDiff("statsd.recv")
+	failCount = expvar.NewDiff("statsd.fail")
+)
+
+func init() {
+	expvar.Register(recvCount, failCount)
+}
+
+// entry is aggregated values of one metric in one interval
+type entry struct {
+	name    string
+	kind    string
+	tags    map[string]string
+	value   float64 // counter sum or gauge value
+	values  []float64
+	count   float64 // timer count, scaled by sample rate
+	set     map[string]struct{}
+	updated bool
+	idle    int // flushes without updating
+}
+
+func entryKey(kind, name string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		keys = append(keys, k+"="+v)
+	}
+	sort.Strings(keys)
+	return kind + "|" + name + "|" + strings.Join(keys, ",")
+}
+
+// add adds one value to aggregation, delta means gauge value is added to current value
+func add(kind, name string, tags map[string]string, raw string, rate float64, delta bool) error {
+	var value float64
+	if kind != kindSet {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return ErrBadValue
+		}
+		value = v
+	}
+	key := entryKey(kind, name, tags)
+
+	entriesLock.Lock()
+	defer entriesLock.Unlock()
+	e := entries[key]
+	if e == nil {
+		e = &entry{name: name, kind: kind, tags: tags}
+		entries[key] = e
+	}
+	switch kind {
+	case kindCounter:
+		e.value += value / rate
+	case kindGauge:
+		if delta {
+			e.value += value
+		} else {
+			e.value = value
+		}
+	case kindTimer:
+		e.count += 1 / rate
+		if len(e.values) < maxTimerValues {
+			e.values = append(e.values, value)
+		}
+	case kindSet:
+		if e.set == nil {
+			e.set = make(map[string]struct{})
+		}
+		e.set[raw] = struct{}{}
+	}
+	e.updated, e.idle = true, 0
+	recvCount.Incr(1)
+	return nil
+}
+
+// Flush sends aggregated metrics in interval
+func Flush(interval time.Duration, ch chan<- []*models.Metric) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		now := <-ticker.C
+		if metrics := flush(now, int(interval.Seconds())); len(metrics) > 0 && ch != nil {
+			ch <- metrics
+			log.Info("flush", "metrics", len(metrics))
+		}
+	}
+}
+
+// flush returns metrics updated in the interval and resets them,
+// gauges keep values and are sent in next intervals without updating until idle too long
+func flush(now time.Time, step int) []*models.Metric {
+	entriesLock.Lock()
+	defer entriesLock.Unlock()
+
+	var (
+		metrics  []*models.Metric
+		endpoint = serverinfo.Hostname()
+	)
+	for key, e := range entries {
+		if !e.updated {
+			if e.idle++; e.kind != kindGauge || e.idle > maxGaugeIdle {
+				delete(entries, key)
+				continue
+			}
+		}
+		m := e.metric(step)
+		m.Time = now.Unix()
+		m.Endpoint = endpoint
+		metrics = append(metrics, m)
+		if e.kind == kindGauge {
+			e.updated = false
+			continue
+		}
+		delete(entries, key)
+	}
+	return metrics
+}
+
+func (e *entry) metric(step int) *models.Metric {
+	tags := make(map[string]string, len(e.tags))
+	for k, v := range e.tags {
+		tags[k] = v
+	}
+	m := &models.Metric{
+		Name:   e.name,
+		Step:   step,
+		Tags:   tags,
+		Fields: make(map[string]interface{}),
+	}
+	switch e.kind {
+	case kindCounter:
+		m.Value = e.value
+		m.Fields["count"] = e.value
+		if step > 0 {
+			m.Fields["rate"] = e.value / float64(step)
+		}
+	case kindGauge:
+		m.Value = e.value
+	case kindTimer:
+		values := e.values
+		sort.Float64s(values)
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		mean := sum / float64(len(values))
+		m.Value = mean
+		m.Fields["count"] = e.count
+		if step > 0 {
+			m.Fields["rate"] = e.count / float64(step)
+		}
+		m.Fields["sum"] = sum
+		m.Fields["mean"] = mean
+		m.Fields["min"] = values[0]
+		m.Fields["max"] = values[len(values)-1]
+		m.Fields["p50"] = percentile(values, 50)
+		m.Fields["p90"] = percentile(values, 90)
+		m.Fields["p99"] = percentile(values, 99)
+	case kindSet:
+		m.Value = float64(len(e.set))
+	}
+	m.Fields["value"] = m.Value
+	return m
+}
+
+// percentile returns nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	idx := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
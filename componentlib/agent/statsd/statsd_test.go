@@ -0,0 +1,148 @@
+package statsd
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/baishancloud/mallard/componentlib/agent/serverinfo"
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/corelib/zaplog"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func init() {
+	log = zaplog.Null()
+	serverinfo.Read("statsd-test")
+}
+
+func findMetric(metrics []*models.Metric, name string) *models.Metric {
+	for _, m := range metrics {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestParse(t *testing.T) {
+	Convey("parse.statsd", t, func() {
+		So(parseStatsd([]byte("")), ShouldBeNil)
+		So(parseStatsd([]byte("abc")), ShouldEqual, ErrBadLine)
+		So(parseStatsd([]byte("abc:1")), ShouldEqual, ErrBadLine)
+		So(parseStatsd([]byte("abc:1|x")), ShouldEqual, ErrBadType)
+		So(parseStatsd([]byte("abc:x|c")), ShouldEqual, ErrBadValue)
+		So(parseStatsd([]byte("abc:1|c|@2")), ShouldEqual, ErrBadLine)
+	})
+
+	Convey("parse.graphite", t, func() {
+		So(parseGraphite([]byte("")), ShouldBeNil)
+		So(parseGraphite([]byte("abc")), ShouldEqual, ErrBadLine)
+		So(parseGraphite([]byte("abc;tag 1")), ShouldEqual, ErrBadLine)
+		So(parseGraphite([]byte("abc x 1500000000")), ShouldEqual, ErrBadValue)
+	})
+	flush(time.Now(), 10)
+}
+
+func TestFlush(t *testing.T) {
+	Convey("flush", t, func() {
+		for _, line := range []string{
+			"req:1|c",
+			"req:2|c|@0.5",
+			"temp:10|g",
+			"temp:+5|g",
+			"temp:-3|g",
+			"latency:10|ms|#api:login",
+			"latency:20|ms|#api:login",
+			"latency:30|h|@0.5|#api:login",
+			"users:alice|s",
+			"users:bob|s",
+			"users:alice|s",
+		} {
+			So(parseStatsd([]byte(line)), ShouldBeNil)
+		}
+		So(parseGraphite([]byte("servers.web1.load;dc=bj -1.5 1500000000")), ShouldBeNil)
+
+		metrics := flush(time.Unix(1500000000, 0), 10)
+		So(metrics, ShouldHaveLength, 5)
+
+		m := findMetric(metrics, "req")
+		So(m.Value, ShouldEqual, 5)
+		So(m.Fields["rate"], ShouldEqual, 0.5)
+		So(m.Step, ShouldEqual, 10)
+		So(m.Time, ShouldEqual, 1500000000)
+		So(m.Endpoint, ShouldEqual, serverinfo.Hostname())
+		So(m.Endpoint, ShouldNotBeEmpty)
+
+		So(findMetric(metrics, "temp").Value, ShouldEqual, 12)
+		So(findMetric(metrics, "users").Value, ShouldEqual, 2)
+
+		m = findMetric(metrics, "latency")
+		So(m.Tags["api"], ShouldEqual, "login")
+		So(m.Value, ShouldEqual, 20)
+		So(m.Fields["count"], ShouldEqual, 4)
+		So(m.Fields["min"], ShouldEqual, 10)
+		So(m.Fields["max"], ShouldEqual, 30)
+		So(m.Fields["p50"], ShouldEqual, 20)
+		So(m.Fields["p99"], ShouldEqual, 30)
+
+		m = findMetric(metrics, "servers.web1.load")
+		So(m.Value, ShouldEqual, -1.5)
+		So(m.Tags["dc"], ShouldEqual, "bj")
+
+		Convey("gauge.keep", func() {
+			metrics := flush(time.Now(), 10) // gauges are sent without updating
+			So(metrics, ShouldHaveLength, 2)
+			So(findMetric(metrics, "temp").Value, ShouldEqual, 12)
+
+			So(parseStatsd([]byte("temp:+1|g")), ShouldBeNil)
+			metrics = flush(time.Now(), 10)
+			So(findMetric(metrics, "temp").Value, ShouldEqual, 13)
+			for i := 0; i < maxGaugeIdle; i++ {
+				So(findMetric(flush(time.Now(), 10), "temp"), ShouldNotBeNil)
+			}
+			So(flush(time.Now(), 10), ShouldBeEmpty)
+			So(entries, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestListen(t *testing.T) {
+	Convey("listen", t, func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		go serveUDP(conn)
+		defer conn.Close()
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		go serveTCP(ln, parseGraphite)
+		defer ln.Close()
+
+		client, err := net.Dial("udp", conn.LocalAddr().String())
+		So(err, ShouldBeNil)
+		client.Write([]byte("udp.req:1|c\nudp.req:1|c\nbad"))
+		client.Close()
+
+		client, err = net.Dial("tcp", ln.Addr().String())
+		So(err, ShouldBeNil)
+		client.Write([]byte("tcp.load 1.5 1500000000\n"))
+		client.Close()
+
+		var metrics []*models.Metric
+		for i := 0; i < 50; i++ {
+			time.Sleep(time.Millisecond * 20)
+			entriesLock.Lock()
+			n := len(entries)
+			entriesLock.Unlock()
+			if n == 2 {
+				break
+			}
+		}
+		metrics = flush(time.Now(), 10)
+		So(metrics, ShouldHaveLength, 2)
+		So(findMetric(metrics, "udp.req").Value, ShouldEqual, 2)
+		So(findMetric(metrics, "tcp.load").Value, ShouldEqual, 1.5)
+		entries = make(map[string]*entry)
+	})
+}
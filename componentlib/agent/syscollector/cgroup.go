@@ -0,0 +1,98 @@
+package syscollector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/extralib/sysprocfs"
+)
+
+const (
+	cgroupMetricName = "cgroup"
+)
+
+var (
+	lastCgroupStats = make(map[string]*sysprocfs.CgroupStat)
+	lastCgroupTime  time.Time
+	lastCgroupLock  sync.Mutex
+)
+
+func init() {
+	registerFactory("core.cgroup", CgroupMetrics)
+}
+
+// CgroupMetrics returns metrics of cgroups for containers or systemd services,
+// cpu usage, throttling and io are calculated by values of last collection,
+// so the first collection only returns memory values
+func CgroupMetrics() ([]*models.Metric, error) {
+	stats, err := sysprocfs.Cgroups()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	lastCgroupLock.Lock()
+	defer lastCgroupLock.Unlock()
+
+	seconds := now.Sub(lastCgroupTime).Seconds()
+	metrics := make([]*models.Metric, 0, len(stats))
+	currentStats := make(map[string]*sysprocfs.CgroupStat, len(stats))
+	for _, stat := range stats {
+		currentStats[stat.Path] = stat
+		m := &models.Metric{
+			Name: cgroupMetricName,
+			Tags: map[string]string{
+				"path": stat.Path,
+			},
+			Fields: map[string]interface{}{
+				"mem_usage": stat.MemoryUsage,
+				"mem_limit": stat.MemoryLimit,
+				"cpu_limit": stat.CPULimit,
+			},
+		}
+		if stat.ContainerID != "" {
+			m.Tags["container_id"] = stat.ContainerID
+		}
+		if stat.Unit != "" {
+			m.Tags["unit"] = stat.Unit
+		}
+		if stat.MemoryLimit > 0 {
+			m.Fields["mem_percent"] = float64(stat.MemoryUsage) * 100 / float64(stat.MemoryLimit)
+		}
+		last := lastCgroupStats[stat.Path]
+		if last != nil && seconds > 0 && !isCgroupReset(last, stat) {
+			cpu := float64(stat.CPUUsage-last.CPUUsage) / 1e9 * 100 / seconds
+			m.Value = cpu
+			m.Fields["cpu"] = cpu
+			if stat.CPULimit > 0 {
+				m.Fields["cpu_limit_percent"] = cpu / stat.CPULimit
+			}
+			periods := stat.CPUPeriods - last.CPUPeriods
+			throttled := stat.CPUThrottled - last.CPUThrottled
+			m.Fields["throttled_periods"] = throttled
+			m.Fields["throttled_time"] = float64(stat.CPUThrottledTime-last.CPUThrottledTime) / 1e6 // ms
+			if periods > 0 {
+				m.Fields["throttled_percent"] = float64(throttled) * 100 / float64(periods)
+			}
+			m.Fields["oom_kills"] = stat.OOMKills - last.OOMKills
+			m.Fields["io_read_bps"] = float64(stat.IOReadBytes-last.IOReadBytes) / seconds
+			m.Fields["io_write_bps"] = float64(stat.IOWriteBytes-last.IOWriteBytes) / seconds
+		}
+		metrics = append(metrics, m)
+	}
+	lastCgroupStats = currentStats
+	lastCgroupTime = now
+	return metrics, nil
+}
+
+// isCgroupReset checks counters are reset, such as cgroup is re-created with same path
+func isCgroupReset(last, stat *sysprocfs.CgroupStat) bool {
+	return stat.CPUUsage < last.CPUUsage ||
+		stat.CPUPeriods < last.CPUPeriods ||
+		stat.CPUThrottled < last.CPUThrottled ||
+		stat.CPUThrottledTime < last.CPUThrottledTime ||
+		stat.OOMKills < last.OOMKills ||
+		stat.IOReadBytes < last.IOReadBytes ||
+		stat.IOWriteBytes < last.IOWriteBytes
+}
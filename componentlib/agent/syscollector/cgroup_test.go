@@ -0,0 +1,55 @@
+package syscollector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/baishancloud/mallard/extralib/sysprocfs"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCgroupMetrics(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "cgroup")
+	defer os.RemoveAll(dir)
+	defer sysprocfs.SetCgroupDir("/sys/fs/cgroup")
+	sysprocfs.SetCgroupDir(dir)
+
+	unitDir := filepath.Join(dir, "system.slice", "app.service")
+	os.MkdirAll(unitDir, os.ModePerm)
+	ioutil.WriteFile(filepath.Join(dir, "cgroup.controllers"), []byte("cpu memory io\n"), 0644)
+	writeStat := func(usage, periods, throttled string) {
+		ioutil.WriteFile(filepath.Join(unitDir, "cpu.stat"), []byte("usage_usec "+usage+"\nnr_periods "+periods+"\nnr_throttled "+throttled+"\nthrottled_usec 0\n"), 0644)
+	}
+	ioutil.WriteFile(filepath.Join(unitDir, "cpu.max"), []byte("100000 100000\n"), 0644)
+	ioutil.WriteFile(filepath.Join(unitDir, "memory.current"), []byte("256\n"), 0644)
+	ioutil.WriteFile(filepath.Join(unitDir, "memory.max"), []byte("1024\n"), 0644)
+	writeStat("1000000", "10", "0")
+
+	Convey("cgroup.metrics", t, func() {
+		metrics, err := CgroupMetrics()
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 1)
+		So(metrics[0].Name, ShouldEqual, cgroupMetricName)
+		So(metrics[0].Tags, ShouldResemble, map[string]string{"path": "/system.slice/app.service", "unit": "app.service"})
+		So(metrics[0].Fields["mem_percent"], ShouldEqual, 25)
+		So(metrics[0].Fields, ShouldNotContainKey, "cpu")
+
+		time.Sleep(time.Millisecond * 100)
+		writeStat("1050000", "20", "5")
+		metrics, err = CgroupMetrics()
+		So(err, ShouldBeNil)
+		So(metrics[0].Value, ShouldBeGreaterThan, 0)
+		So(metrics[0].Fields["throttled_periods"], ShouldEqual, 5)
+		So(metrics[0].Fields["throttled_percent"], ShouldEqual, 50)
+		So(metrics[0].Fields, ShouldContainKey, "io_read_bps")
+		So(metrics[0].Fields, ShouldContainKey, "io_write_bps")
+
+		writeStat("10", "0", "0")
+		metrics, err = CgroupMetrics()
+		So(err, ShouldBeNil)
+		So(metrics[0].Fields, ShouldNotContainKey, "cpu")
+	})
+}
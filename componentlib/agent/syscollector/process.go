@@ -0,0 +1,236 @@
+package syscollector
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/extralib/sysprocfs"
+)
+
+const (
+	processMetricName = "proc"
+)
+
+var (
+	processRules   []*models.ProcessRule
+	processRegexps map[string]*regexp.Regexp
+	processSamples = make(map[int]*processSample)
+	processLock    sync.Mutex
+)
+
+func init() {
+	registerFactory("core.proc", ProcessMetrics)
+}
+
+// processSample is last counters of process to calculate rates
+type processSample struct {
+	StartTime  int64
+	CPUTicks   uint64
+	ReadBytes  int64
+	WriteBytes int64
+	Time       time.Time
+}
+
+// processMeta is name, cmdline and unit of process, read when needed in once collection
+type processMeta struct {
+	pid     int
+	name    *string
+	cmdline *string
+	unit    *string
+}
+
+func (pm *processMeta) Name() string {
+	if pm.name == nil {
+		name, _ := sysprocfs.ProcessName(pm.pid)
+		pm.name = &name
+	}
+	return *pm.name
+}
+
+func (pm *processMeta) Cmdline() string {
+	if pm.cmdline == nil {
+		cmdline, _ := sysprocfs.ProcessCmdline(pm.pid)
+		pm.cmdline = &cmdline
+	}
+	return *pm.cmdline
+}
+
+func (pm *processMeta) Unit() string {
+	if pm.unit == nil {
+		unit, _ := sysprocfs.ProcessUnit(pm.pid)
+		pm.unit = &unit
+	}
+	return *pm.unit
+}
+
+// SetProcessRules sets rules to match processes, invalid rules are ignored
+func SetProcessRules(rules []*models.ProcessRule) {
+	var (
+		validRules []*models.ProcessRule
+		regexps    = make(map[string]*regexp.Regexp)
+	)
+	for _, rule := range rules {
+		if !rule.IsValid() {
+			log.Warn("proc-rule-invalid", "name", rule.Name)
+			continue
+		}
+		if rule.Cmdline != "" {
+			reg, err := regexp.Compile(rule.Cmdline)
+			if err != nil {
+				log.Warn("proc-rule-regexp-error", "name", rule.Name, "error", err)
+				continue
+			}
+			regexps[rule.Cmdline] = reg
+		}
+		validRules = append(validRules, rule)
+	}
+	processLock.Lock()
+	processRules = validRules
+	processRegexps = regexps
+	processLock.Unlock()
+	log.Info("set-proc-rules", "rules", len(validRules))
+}
+
+func (pm *processMeta) match(rule *models.ProcessRule, regexps map[string]*regexp.Regexp) bool {
+	if rule.Process != "" && pm.Name() != rule.Process {
+		return false
+	}
+	if rule.Unit != "" {
+		unit := pm.Unit()
+		if unit != rule.Unit && strings.TrimSuffix(unit, ".service") != rule.Unit {
+			return false
+		}
+	}
+	if rule.Cmdline != "" {
+		reg := regexps[rule.Cmdline]
+		if reg == nil || !reg.MatchString(pm.Cmdline()) {
+			return false
+		}
+	}
+	return true
+}
+
+func readPidfile(file string) (int, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// ProcessMetrics returns metrics of processes matched by rules, one metric for each rule,
+// value is count of matched processes, read_bps and write_bps are disk io bytes per second
+func ProcessMetrics() ([]*models.Metric, error) {
+	processLock.Lock()
+	defer processLock.Unlock()
+
+	if len(processRules) == 0 {
+		return nil, nil
+	}
+	pids, err := sysprocfs.Pids()
+	if err != nil {
+		return nil, err
+	}
+	bootTime, err := sysprocfs.BootTime()
+	if err != nil {
+		return nil, err
+	}
+	metas := make(map[int]*processMeta, len(pids))
+	for _, pid := range pids {
+		metas[pid] = &processMeta{pid: pid}
+	}
+
+	var (
+		now      = time.Now()
+		infos    = make(map[int]*sysprocfs.ProcessInfo)
+		samples  = make(map[int]*processSample)
+		metrics  = make([]*models.Metric, 0, len(processRules))
+		readInfo = func(pid int) *sysprocfs.ProcessInfo {
+			info, ok := infos[pid]
+			if !ok {
+				info, _ = sysprocfs.ReadProcess(pid)
+				infos[pid] = info
+			}
+			return info
+		}
+	)
+	for _, rule := range processRules {
+		var matched []*processMeta
+		if rule.Pidfile != "" {
+			pid, err := readPidfile(rule.Pidfile)
+			if err == nil && metas[pid] != nil && metas[pid].match(rule, processRegexps) {
+				matched = append(matched, metas[pid])
+			}
+		} else {
+			for _, pid := range pids {
+				if metas[pid].match(rule, processRegexps) {
+					matched = append(matched, metas[pid])
+				}
+			}
+		}
+
+		var (
+			count                 int
+			cpu, readRate, wrRate float64
+			rss, fds, threads     int64
+			uptime                int64 = -1
+		)
+		for _, pm := range matched {
+			info := readInfo(pm.pid)
+			if info == nil {
+				continue // process is exited
+			}
+			count++
+			rss += info.RSS
+			fds += info.Fds
+			threads += info.Threads
+			if up := now.Unix() - bootTime - info.StartTime; uptime < 0 || up < uptime {
+				uptime = up
+			}
+			sample := &processSample{
+				StartTime:  info.StartTime,
+				CPUTicks:   info.CPUTicks,
+				ReadBytes:  info.ReadBytes,
+				WriteBytes: info.WriteBytes,
+				Time:       now,
+			}
+			samples[pm.pid] = sample
+			last := processSamples[pm.pid]
+			if last == nil || last.StartTime != sample.StartTime || info.CPUTicks < last.CPUTicks {
+				continue
+			}
+			if seconds := now.Sub(last.Time).Seconds(); seconds > 0 {
+				cpu += sysprocfs.ProcessCPUSeconds(info.CPUTicks-last.CPUTicks) * 100 / seconds
+				readRate += float64(info.ReadBytes-last.ReadBytes) / seconds
+				wrRate += float64(info.WriteBytes-last.WriteBytes) / seconds
+			}
+		}
+		m := &models.Metric{
+			Name:  processMetricName,
+			Value: float64(count),
+			Tags: map[string]string{
+				"name": rule.Name,
+			},
+			Fields: map[string]interface{}{
+				"count": count,
+			},
+		}
+		if count > 0 {
+			m.Fields["cpu"] = cpu
+			m.Fields["rss"] = rss
+			m.Fields["fds"] = fds
+			m.Fields["threads"] = threads
+			m.Fields["read_bps"] = readRate
+			m.Fields["write_bps"] = wrRate
+			m.Fields["uptime"] = uptime
+		}
+		metrics = append(metrics, m)
+	}
+	processSamples = samples
+	return metrics, nil
+}
@@ -0,0 +1,70 @@
+package syscollector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/extralib/sysprocfs"
+	"github.com/baishancloud/mallard/extralib/sysprocfs/procfstest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func writeUnitProcess(dir string, pid int, name string, cmdline string, unit string, ticks int) {
+	procfstest.WriteProcess(dir, pid, name, cmdline, "1:name=systemd:/system.slice/"+unit+"\n", ticks)
+}
+
+func TestProcessMetrics(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "procfs")
+	defer os.RemoveAll(dir)
+	defer sysprocfs.SetProcDir("/proc")
+	sysprocfs.SetProcDir(dir)
+	defer SetProcessRules(nil)
+
+	ioutil.WriteFile(filepath.Join(dir, "stat"), []byte("btime 1534000000\n"), 0644)
+	writeUnitProcess(dir, 10, "nginx", "nginx: master process", "nginx.service", 10)
+	writeUnitProcess(dir, 11, "nginx", "nginx: worker process", "nginx.service", 10)
+	writeUnitProcess(dir, 20, "java", "java\x00-jar\x00/opt/app.jar", "app.service", 10)
+	pidfile := filepath.Join(dir, "app.pid")
+	ioutil.WriteFile(pidfile, []byte("20\n"), 0644)
+
+	Convey("process.metrics", t, func() {
+		metrics, err := ProcessMetrics()
+		So(err, ShouldBeNil)
+		So(metrics, ShouldBeEmpty)
+
+		SetProcessRules([]*models.ProcessRule{
+			{Name: "nginx", Process: "nginx"},
+			{Name: "nginx-worker", Cmdline: "worker"},
+			{Name: "app", Pidfile: pidfile},
+			{Name: "app-unit", Unit: "app"},
+			{Name: "redis", Process: "redis-server"},
+			{Name: "bad-regexp", Cmdline: "("},
+			{Name: "no-condition"},
+		})
+		metrics, err = ProcessMetrics()
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 5)
+
+		So(metrics[0].Tags["name"], ShouldEqual, "nginx")
+		So(metrics[0].Value, ShouldEqual, 2)
+		So(metrics[0].Fields["rss"], ShouldEqual, 2*256*os.Getpagesize())
+		So(metrics[0].Fields["threads"], ShouldEqual, 8)
+		So(metrics[0].Fields["fds"], ShouldEqual, 6)
+		So(metrics[0].Fields, ShouldContainKey, "read_bps")
+		So(metrics[0].Fields, ShouldContainKey, "write_bps")
+		So(metrics[1].Value, ShouldEqual, 1)
+		So(metrics[2].Value, ShouldEqual, 1)
+		So(metrics[3].Value, ShouldEqual, 1)
+		So(metrics[4].Value, ShouldEqual, 0)
+		So(metrics[4].Fields, ShouldResemble, map[string]interface{}{"count": 0})
+
+		writeUnitProcess(dir, 20, "java", "java\x00-jar\x00/opt/app.jar", "app.service", 110)
+		metrics, err = ProcessMetrics()
+		So(err, ShouldBeNil)
+		So(metrics[2].Fields["cpu"], ShouldBeGreaterThan, 0)
+		So(metrics[0].Fields["cpu"], ShouldEqual, 0)
+	})
+}
@@ -1,6 +1,7 @@
 package syscollector
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 	"sync"
@@ -15,10 +16,28 @@ import (
 // Collector is the function to collect metrics
 type Collector func() ([]*models.Metric, error)
 
+// collector is registered collector with running state and counters
+type collector struct {
+	name     string
+	fn       Collector
+	running  int32
+	duration *expvar.AvgMeter // ms
+	errors   *expvar.DiffMeter
+}
+
 var (
-	collectorFactory = make(map[string]Collector, 15)
+	// ErrCollectTimeout means collector does not return in timeout
+	ErrCollectTimeout = errors.New("collect-timeout")
+	// ErrCollectRunning means collector is still running since last time, usually it's hung
+	ErrCollectRunning = errors.New("collect-running")
+
+	collectorFactory = make(map[string]*collector, 16)
 	collectorLock    sync.RWMutex
 	collectCounter   = expvar.NewDiff("sys.collect")
+
+	localOptions  map[string]*models.CollectorOption
+	remoteOptions map[string]*models.CollectorOption
+	optionsLock   sync.RWMutex
 )
 
 func init() {
@@ -26,62 +45,168 @@ func init() {
 }
 
 func registerFactory(name string, c Collector) {
+	col := &collector{
+		name:     name,
+		fn:       c,
+		duration: expvar.NewAverage("sys."+name+".duration", 10),
+		errors:   expvar.NewDiff("sys." + name + ".error"),
+	}
+	expvar.Register(col.duration, col.errors)
 	collectorLock.Lock()
-	collectorFactory[name] = c
+	collectorFactory[name] = col
 	collectorLock.Unlock()
 }
 
+// SetOptions sets options of collectors in local config
+func SetOptions(opts map[string]*models.CollectorOption) {
+	optionsLock.Lock()
+	localOptions = opts
+	optionsLock.Unlock()
+	log.Info("set-options", "options", len(opts))
+}
+
+// SetRemoteOptions sets options of collectors from center, they override local options
+func SetRemoteOptions(opts map[string]*models.CollectorOption) {
+	optionsLock.Lock()
+	defer optionsLock.Unlock()
+	if len(opts) > 0 || len(remoteOptions) > 0 {
+		log.Info("set-remote-options", "options", len(opts))
+	}
+	remoteOptions = opts
+}
+
+// option returns interval, timeout and enable flag of collector,
+// remote options override local options, and local options override default values,
+// timeout is the same as interval if not set
+func option(name string, interval time.Duration) (time.Duration, time.Duration, bool) {
+	var (
+		timeout time.Duration
+		enable  = true
+	)
+	optionsLock.RLock()
+	for _, opts := range []map[string]*models.CollectorOption{localOptions, remoteOptions} {
+		opt := opts[name]
+		if opt == nil {
+			continue
+		}
+		if opt.Interval > 0 {
+			interval = time.Duration(opt.Interval) * time.Second
+		}
+		if opt.Timeout > 0 {
+			timeout = time.Duration(opt.Timeout) * time.Second
+		}
+		if opt.Enable != nil {
+			enable = *opt.Enable
+		}
+	}
+	optionsLock.RUnlock()
+	if timeout <= 0 {
+		timeout = interval
+	}
+	return interval, timeout, enable
+}
+
 var (
 	collectorStopFlag uint64
 	log               = zaplog.Zap("syscollector")
 )
 
-// Collect runs sysycollectors , pushes metric values to channel and pushes error values to channel
+// Collect runs sysycollectors concurrently in their own intervals,
+// pushes metric values to channel and pushes error values to channel
 func Collect(prefix string, interval time.Duration, metricsChan chan<- []*models.Metric, errorChan chan<- error) {
 	log.Info("init", "prefix", prefix, "interval", int(interval.Seconds()))
 
 	// print running system collector
-	keys := make([]string, 0, len(collectorFactory))
 	collectorLock.RLock()
-	for key := range collectorFactory {
+	keys := make([]string, 0, len(collectorFactory))
+	collectors := make([]*collector, 0, len(collectorFactory))
+	for key, c := range collectorFactory {
+		if c.fn == nil {
+			continue
+		}
 		keys = append(keys, key)
+		collectors = append(collectors, c)
 	}
 	collectorLock.RUnlock()
 	sort.Sort(sort.StringSlice(keys))
 	log.Info("factory", "keys", keys)
 
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+	var wg sync.WaitGroup
+	for _, c := range collectors {
+		wg.Add(1)
+		go func(c *collector) {
+			defer wg.Done()
+			c.loop(prefix, interval, metricsChan, errorChan)
+		}(c)
+	}
+	wg.Wait()
+}
+
+// loop runs collector in interval until stopped,
+// options are read every time so changes take effect after current interval
+func (c *collector) loop(prefix string, interval time.Duration, metricsChan chan<- []*models.Metric, errorChan chan<- error) {
 	for {
 		if atomic.LoadUint64(&collectorStopFlag) > 0 {
 			return
 		}
-		metrics := make([]*models.Metric, 0, 100)
-		collectorLock.RLock()
-		for key, factory := range collectorFactory {
-			if factory == nil {
-				continue
-			}
-			values, err := factory()
+		st := time.Now()
+		itv, timeout, enable := option(c.name, interval)
+		if enable {
+			metrics, err := c.run(timeout)
 			if err != nil {
-				errorChan <- fmt.Errorf("%s, %s", key, err.Error())
-			}
-			if len(values) > 0 {
-				metrics = append(metrics, values...)
+				c.errors.Incr(1)
+				errorChan <- fmt.Errorf("%s, %s", c.name, err.Error())
 			}
-		}
-		collectorLock.RUnlock()
-		if len(metrics) > 0 {
-			if prefix != "" {
+			if len(metrics) > 0 {
+				step := int(itv.Seconds())
 				for _, m := range metrics {
-					m.Name = prefix + "." + m.Name
+					if prefix != "" {
+						m.Name = prefix + "." + m.Name
+					}
+					if m.Step == 0 {
+						m.Step = step
+					}
 				}
+				metricsChan <- metrics
+				log.Debug("collect", "name", c.name, "metrics", len(metrics))
+				collectCounter.Incr(int64(len(metrics)))
 			}
-			metricsChan <- metrics
-			log.Info("collect", "metrics", len(metrics))
-			collectCounter.Incr(int64(len(metrics)))
 		}
-		<-ticker.C
+		time.Sleep(time.Until(st.Add(itv)))
+	}
+}
+
+// run calls collector function in goroutine and waits until timeout,
+// the hung function is not called again until it returns
+func (c *collector) run(timeout time.Duration) ([]*models.Metric, error) {
+	if !atomic.CompareAndSwapInt32(&c.running, 0, 1) {
+		return nil, ErrCollectRunning
+	}
+	type result struct {
+		metrics []*models.Metric
+		err     error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		st := time.Now()
+		defer func() {
+			if r := recover(); r != nil {
+				ch <- result{err: fmt.Errorf("panic: %v", r)}
+			}
+			c.duration.Set(time.Since(st).Nanoseconds() / 1e6)
+			atomic.StoreInt32(&c.running, 0)
+		}()
+		metrics, err := c.fn()
+		ch <- result{metrics: metrics, err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		return r.metrics, r.err
+	case <-timer.C:
+		return nil, ErrCollectTimeout
 	}
 }
 
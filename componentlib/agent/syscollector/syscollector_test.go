@@ -1,13 +1,115 @@
 package syscollector
 
 import (
+	"errors"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/baishancloud/mallard/corelib/expvar"
+	"github.com/baishancloud/mallard/corelib/models"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
+func newTestCollector(name string, fn Collector) *collector {
+	return &collector{
+		name:     name,
+		fn:       fn,
+		duration: expvar.NewAverage(name+".duration", 10),
+		errors:   expvar.NewDiff(name + ".error"),
+	}
+}
+
 func TestCollector(t *testing.T) {
 	Convey("collector", t, func() {
-		So(collectorFactory, ShouldHaveLength, 14)
+		So(collectorFactory, ShouldHaveLength, 16)
+	})
+
+	Convey("collector.option", t, func() {
+		defer func() {
+			SetOptions(nil)
+			SetRemoteOptions(nil)
+		}()
+		interval, timeout, enable := option("disk.usage", time.Minute)
+		So(interval, ShouldEqual, time.Minute)
+		So(timeout, ShouldEqual, time.Minute)
+		So(enable, ShouldBeTrue)
+
+		disable := false
+		SetOptions(map[string]*models.CollectorOption{
+			"disk.usage": {Interval: 300, Timeout: 10},
+			"core.cpu":   {Enable: &disable},
+		})
+		interval, timeout, enable = option("disk.usage", time.Minute)
+		So(interval, ShouldEqual, time.Minute*5)
+		So(timeout, ShouldEqual, time.Second*10)
+		So(enable, ShouldBeTrue)
+		_, _, enable = option("core.cpu", time.Minute)
+		So(enable, ShouldBeFalse)
+
+		enabled := true
+		SetRemoteOptions(map[string]*models.CollectorOption{
+			"disk.usage": {Interval: 120},
+			"core.cpu":   {Enable: &enabled},
+		})
+		interval, timeout, _ = option("disk.usage", time.Minute)
+		So(interval, ShouldEqual, time.Minute*2)
+		So(timeout, ShouldEqual, time.Second*10)
+		_, _, enable = option("core.cpu", time.Minute)
+		So(enable, ShouldBeTrue)
+	})
+
+	Convey("collector.run", t, func() {
+		c := newTestCollector("test.ok", func() ([]*models.Metric, error) {
+			return []*models.Metric{{Name: "ok"}}, nil
+		})
+		metrics, err := c.run(time.Second)
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 1)
+
+		c = newTestCollector("test.panic", func() ([]*models.Metric, error) {
+			panic("bad")
+		})
+		_, err = c.run(time.Second)
+		So(err, ShouldNotBeNil)
+
+		release := make(chan struct{})
+		c = newTestCollector("test.hung", func() ([]*models.Metric, error) {
+			<-release
+			return nil, nil
+		})
+		_, err = c.run(time.Millisecond * 20)
+		So(err, ShouldEqual, ErrCollectTimeout)
+		_, err = c.run(time.Millisecond * 20)
+		So(err, ShouldEqual, ErrCollectRunning)
+		close(release)
+		for atomic.LoadInt32(&c.running) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		_, err = c.run(time.Millisecond * 20)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("collector.loop", t, func() {
+		defer atomic.StoreUint64(&collectorStopFlag, 0)
+		var (
+			metricsChan = make(chan []*models.Metric, 10)
+			errorChan   = make(chan error, 10)
+			done        = make(chan struct{})
+		)
+		c := newTestCollector("test.loop", func() ([]*models.Metric, error) {
+			return []*models.Metric{{Name: "loop"}}, errors.New("partial")
+		})
+		go func() {
+			c.loop("sys", time.Millisecond*20, metricsChan, errorChan)
+			close(done)
+		}()
+		metrics := <-metricsChan
+		So(metrics[0].Name, ShouldEqual, "sys.loop")
+		So(<-errorChan, ShouldNotBeNil)
+		<-metricsChan
+		StopCollect()
+		<-done
+		So(c.errors.Diff(), ShouldBeGreaterThanOrEqualTo, 2)
 	})
 }
@@ -43,7 +43,18 @@ func Events(events []*models.Event) {
 	sendWg.Add(1)
 	defer sendWg.Done()
 
-	var isSend bool
+	if !postEvents(events) {
+		log.Warn("events-send-fail", "len", dataLen)
+		eventFailCount.Incr(int64(len(events)))
+		writeSpool(spoolKindEvent, events, dataLen)
+		return
+	}
+	eventSendCount.Incr(int64(len(events)))
+}
+
+// postEvents tries to post events to transfers, returns true if one of them succeeds
+func postEvents(events []*models.Event) bool {
+	dataLen := len(events)
 	for i := 0; i <= 3; i++ {
 
 		urlLock.RLock()
@@ -55,19 +66,15 @@ func Events(events []*models.Event) {
 		if err != nil {
 			log.Debug("latency", "history", urlLatency.History())
 			log.Warn("events-send-once-error", "url", url, "error", err)
+			urlLatency.SetFail(idx)
 			continue
 		}
 		resp.Body.Close()
 		ds := du.Nanoseconds() / 1e6
 		log.Info("events-send-ok", "url", url, "len", dataLen, "ms", ds)
 		eventLatencyCount.Set(ds)
-		isSend = true
-		break
-	}
-	if !isSend {
-		log.Warn("events-send-fail", "len", dataLen)
-		eventFailCount.Incr(int64(len(events)))
-		return
+		urlLatency.Set(idx, ds)
+		return true
 	}
-	eventSendCount.Incr(int64(len(events)))
+	return false
 }
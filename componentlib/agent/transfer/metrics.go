@@ -43,7 +43,18 @@ func Metrics(metrics []*models.Metric) {
 	sendWg.Add(1)
 	defer sendWg.Done()
 
-	var isSend bool
+	if !postMetrics(metrics) {
+		log.Warn("metrics-send-fail", "len", dataLen)
+		metricFailCount.Incr(int64(len(metrics)))
+		writeSpool(spoolKindMetric, metrics, dataLen)
+		return
+	}
+	metricSendCount.Incr(int64(len(metrics)))
+}
+
+// postMetrics tries to post metrics to transfers, returns true if one of them succeeds
+func postMetrics(metrics []*models.Metric) bool {
+	dataLen := len(metrics)
 	for i := 0; i <= 3; i++ {
 
 		urlLock.RLock()
@@ -63,13 +74,7 @@ func Metrics(metrics []*models.Metric) {
 		log.Info("metrics-send-ok", "url", url, "len", dataLen, "ms", ds)
 		metricLatencyCount.Set(ds)
 		urlLatency.Set(idx, ds)
-		isSend = true
-		break
-	}
-	if !isSend {
-		log.Warn("metrics-send-fail", "len", dataLen)
-		metricFailCount.Incr(int64(len(metrics)))
-		return
+		return true
 	}
-	metricSendCount.Incr(int64(len(metrics)))
+	return false
 }
@@ -39,6 +39,7 @@ func SendSelfInfo(cfgData interface{}) {
 		"serverinfo": serverinfo.Cached(),
 		"config":     cfgData,
 		"plugins":    plugins.FilesHash(),
+		"mismatches": plugins.Mismatches(),
 	}
 
 	urlLock.RLock()
@@ -0,0 +1,269 @@
+package transfer
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/expvar"
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/corelib/utils"
+)
+
+const (
+	spoolKindMetric = "metric"
+	spoolKindEvent  = "event"
+
+	spoolFileExt = ".spool"
+)
+
+var (
+	// MaxSpoolReplayOnce is max number of spooled batches to replay in one loop
+	MaxSpoolReplayOnce = 20
+
+	spoolDir     string
+	spoolMaxSize int64
+	spoolMaxAge  time.Duration
+	spoolLock    sync.Mutex
+
+	spoolDepthCount  = expvar.NewBase("poster.spool_depth")
+	spoolBytesCount  = expvar.NewBase("poster.spool_bytes")
+	spoolWriteCount  = expvar.NewDiff("poster.spool_write")
+	spoolDropCount   = expvar.NewDiff("poster.spool_drop")
+	spoolReplayCount = expvar.NewQPS("poster.spool_replay")
+)
+
+func init() {
+	expvar.Register(spoolDepthCount, spoolBytesCount, spoolWriteCount, spoolDropCount, spoolReplayCount)
+}
+
+// SetSpool sets spool directory and limits for batches that fail to send to all transfers,
+// if dir is blank, failed batches are dropped as before
+func SetSpool(dir string, maxSize int64, maxAge time.Duration) {
+	spoolLock.Lock()
+	defer spoolLock.Unlock()
+	if dir == "" {
+		spoolDir = ""
+		return
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		log.Warn("init-spool-error", "dir", dir, "error", err)
+		return
+	}
+	spoolDir = dir
+	spoolMaxSize = maxSize
+	spoolMaxAge = maxAge
+	cleanSpool()
+	log.Info("init-spool", "dir", dir, "max_size", maxSize, "max_age", maxAge.String())
+}
+
+type spoolFile struct {
+	Path string
+	Kind string
+	Size int64
+	Time int64
+}
+
+func spoolFileName(dir, kind string, dataLen int) string {
+	return filepath.Join(dir, fmt.Sprintf("%019d_%s_%d%s", time.Now().UnixNano(), kind, dataLen, spoolFileExt))
+}
+
+func parseSpoolFile(fpath string, info os.FileInfo) (spoolFile, bool) {
+	name := strings.TrimSuffix(filepath.Base(fpath), spoolFileExt)
+	items := strings.Split(name, "_")
+	if len(items) != 3 {
+		return spoolFile{}, false
+	}
+	t, err := strconv.ParseInt(items[0], 10, 64)
+	if err != nil {
+		return spoolFile{}, false
+	}
+	if items[1] != spoolKindMetric && items[1] != spoolKindEvent {
+		return spoolFile{}, false
+	}
+	return spoolFile{
+		Path: fpath,
+		Kind: items[1],
+		Size: info.Size(),
+		Time: t,
+	}, true
+}
+
+// listSpool returns all spooled files, sorted by writing time
+func listSpool() ([]spoolFile, error) {
+	if spoolDir == "" {
+		return nil, nil
+	}
+	infos, err := ioutil.ReadDir(spoolDir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]spoolFile, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || filepath.Ext(info.Name()) != spoolFileExt {
+			continue
+		}
+		if sf, ok := parseSpoolFile(filepath.Join(spoolDir, info.Name()), info); ok {
+			files = append(files, sf)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Time < files[j].Time
+	})
+	return files, nil
+}
+
+// cleanSpool removes expired files and oldest files over max size,
+// it must be called with spoolLock
+func cleanSpool() {
+	files, err := listSpool()
+	if err != nil {
+		log.Warn("spool-list-error", "error", err)
+		return
+	}
+	var (
+		totalSize int64
+		now       = time.Now().UnixNano()
+		kept      = make([]spoolFile, 0, len(files))
+	)
+	for _, sf := range files {
+		if spoolMaxAge > 0 && now-sf.Time > spoolMaxAge.Nanoseconds() {
+			os.Remove(sf.Path)
+			spoolDropCount.Incr(1)
+			log.Info("spool-drop-expired", "file", sf.Path)
+			continue
+		}
+		totalSize += sf.Size
+		kept = append(kept, sf)
+	}
+	for len(kept) > 0 && spoolMaxSize > 0 && totalSize > spoolMaxSize {
+		sf := kept[0]
+		os.Remove(sf.Path)
+		spoolDropCount.Incr(1)
+		log.Info("spool-drop-oversize", "file", sf.Path, "total", totalSize)
+		totalSize -= sf.Size
+		kept = kept[1:]
+	}
+	spoolDepthCount.Set(int64(len(kept)))
+	spoolBytesCount.Set(totalSize)
+}
+
+// writeSpool saves failed batch to spool directory
+func writeSpool(kind string, value interface{}, dataLen int) bool {
+	spoolLock.Lock()
+	defer spoolLock.Unlock()
+	if spoolDir == "" {
+		return false
+	}
+	data, err := utils.GzipJSONBytes(value, 10240)
+	if err != nil {
+		log.Warn("spool-encode-error", "kind", kind, "error", err)
+		return false
+	}
+	fname := spoolFileName(spoolDir, kind, dataLen)
+	if err = ioutil.WriteFile(fname, data, 0644); err != nil {
+		log.Warn("spool-write-error", "file", fname, "error", err)
+		return false
+	}
+	spoolWriteCount.Incr(1)
+	log.Info("spool-write", "file", fname, "len", dataLen)
+	cleanSpool()
+	return true
+}
+
+// ReplaySpool replays spooled batches in order when any transfer is healthy
+func ReplaySpool(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		if atomic.LoadInt64(&stopFlag) > 0 {
+			log.Info("spool-replay-stop")
+			return
+		}
+		if !isHealthy() {
+			continue
+		}
+		replaySpoolOnce(MaxSpoolReplayOnce)
+	}
+}
+
+func replaySpoolOnce(max int) int {
+	spoolLock.Lock()
+	files, err := listSpool()
+	spoolLock.Unlock()
+	if err != nil {
+		log.Warn("spool-list-error", "error", err)
+		return 0
+	}
+	var count int
+	for i, sf := range files {
+		if i >= max {
+			break
+		}
+		ok, err := replaySpoolFile(sf)
+		if err != nil {
+			log.Warn("spool-replay-bad-file", "file", sf.Path, "error", err)
+			os.Remove(sf.Path)
+			spoolDropCount.Incr(1)
+			continue
+		}
+		if !ok {
+			log.Warn("spool-replay-fail", "file", sf.Path)
+			break
+		}
+		os.Remove(sf.Path)
+		count++
+	}
+	if count > 0 {
+		log.Info("spool-replay", "count", count)
+	}
+	spoolLock.Lock()
+	cleanSpool()
+	spoolLock.Unlock()
+	return count
+}
+
+func replaySpoolFile(sf spoolFile) (bool, error) {
+	data, err := ioutil.ReadFile(sf.Path)
+	if err != nil {
+		return false, err
+	}
+	switch sf.Kind {
+	case spoolKindMetric:
+		var metrics []*models.Metric
+		if err = utils.UngzipJSON(bytes.NewReader(data), &metrics); err != nil {
+			return false, err
+		}
+		if len(metrics) == 0 {
+			return true, nil
+		}
+		if !postMetrics(metrics) {
+			return false, nil
+		}
+		spoolReplayCount.Incr(int64(len(metrics)))
+		metricSendCount.Incr(int64(len(metrics)))
+	case spoolKindEvent:
+		var events []*models.Event
+		if err = utils.UngzipJSON(bytes.NewReader(data), &events); err != nil {
+			return false, err
+		}
+		if len(events) == 0 {
+			return true, nil
+		}
+		if !postEvents(events) {
+			return false, nil
+		}
+		spoolReplayCount.Incr(int64(len(events)))
+		eventSendCount.Incr(int64(len(events)))
+	}
+	return true, nil
+}
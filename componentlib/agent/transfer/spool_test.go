@@ -0,0 +1,112 @@
+package transfer
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/corelib/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSpool(t *testing.T) {
+	Convey("spool", t, func() {
+		dir := "./tests_spool"
+		defer os.RemoveAll(dir)
+		SetSpool(dir, 1024*1024, time.Hour)
+
+		metrics := []*models.Metric{{
+			Name:     "cpu",
+			Value:    1,
+			Time:     time.Now().Unix(),
+			Endpoint: "localhost",
+		}}
+		events := []*models.Event{{
+			ID:     "s_1_abc",
+			Status: models.EventProblem,
+			Time:   time.Now().Unix(),
+		}}
+
+		Convey("spool.write", func() {
+			So(writeSpool(spoolKindMetric, metrics, 1), ShouldBeTrue)
+			So(writeSpool(spoolKindEvent, events, 1), ShouldBeTrue)
+			files, err := listSpool()
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 2)
+			So(files[0].Kind, ShouldEqual, spoolKindMetric)
+			So(files[1].Kind, ShouldEqual, spoolKindEvent)
+			So(spoolDepthCount.Count(), ShouldEqual, 2)
+		})
+
+		Convey("spool.clean", func() {
+			writeSpool(spoolKindMetric, metrics, 1)
+			writeSpool(spoolKindMetric, metrics, 1)
+			spoolLock.Lock()
+			spoolMaxSize = 1
+			cleanSpool()
+			spoolLock.Unlock()
+			files, _ := listSpool()
+			So(files, ShouldHaveLength, 0)
+			So(spoolBytesCount.Count(), ShouldEqual, 0)
+		})
+
+		Convey("spool.replay", func(c C) {
+			defer setupServer()()
+			var recvMetrics, recvEvents int
+			mux.HandleFunc("/api/metric", func(rw http.ResponseWriter, r *http.Request) {
+				var ms []*models.Metric
+				c.So(utils.UngzipJSON(r.Body, &ms), ShouldBeNil)
+				recvMetrics += len(ms)
+				rw.WriteHeader(204)
+			})
+			mux.HandleFunc("/api/event", func(rw http.ResponseWriter, r *http.Request) {
+				var es []*models.Event
+				c.So(utils.UngzipJSON(r.Body, &es), ShouldBeNil)
+				recvEvents += len(es)
+				rw.WriteHeader(204)
+			})
+			SetURLs([]string{server.URL}, map[string]string{
+				"metric": "/api/metric",
+				"event":  "/api/event",
+			})
+
+			writeSpool(spoolKindMetric, metrics, 1)
+			writeSpool(spoolKindEvent, events, 1)
+			writeSpool(spoolKindMetric, metrics, 1)
+
+			So(replaySpoolOnce(2), ShouldEqual, 2)
+			So(recvMetrics, ShouldEqual, 1)
+			So(recvEvents, ShouldEqual, 1)
+			So(isHealthy(), ShouldBeTrue)
+
+			So(replaySpoolOnce(10), ShouldEqual, 1)
+			So(recvMetrics, ShouldEqual, 2)
+			files, _ := listSpool()
+			So(files, ShouldHaveLength, 0)
+		})
+	})
+}
+
+func TestHealthy(t *testing.T) {
+	Convey("healthy", t, func() {
+		SetURLs([]string{"http://127.0.0.1:1", "http://127.0.0.1:2"}, nil)
+		So(isHealthy(), ShouldBeFalse)
+		urlLatency.SetFail(0)
+		So(isHealthy(), ShouldBeFalse)
+		urlLatency.Set(1, 0)
+		So(isHealthy(), ShouldBeTrue)
+	})
+
+	Convey("healthy.events", t, func() {
+		defer setupServer()()
+		mux.HandleFunc("/api/event", func(rw http.ResponseWriter, r *http.Request) {
+			rw.WriteHeader(204)
+		})
+		SetURLs([]string{server.URL}, map[string]string{"event": "/api/event"})
+		So(isHealthy(), ShouldBeFalse)
+		So(postEvents([]*models.Event{{ID: "s_1_abc"}}), ShouldBeTrue)
+		So(isHealthy(), ShouldBeTrue)
+	})
+}
@@ -39,6 +39,22 @@ func SetURLs(urls []string, suffix map[string]string) {
 	urlLock.Unlock()
 }
 
+// isHealthy checks whether one of transfers responds ok recently
+func isHealthy() bool {
+	urlLock.RLock()
+	defer urlLock.RUnlock()
+	if urlLatency == nil {
+		return false
+	}
+	for _, lat := range urlLatency.History() {
+		// 0 ms is a successful request in local network
+		if lat != utils.InitValue && lat != utils.FailValue {
+			return true
+		}
+	}
+	return false
+}
+
 // Stop stops transfer sending, waits all requests finish
 func Stop() {
 	atomic.StoreInt64(&stopFlag, 1)
@@ -119,7 +119,7 @@ func checkAlarmStat(
 			}
 			result[event.StrategyID] = res
 		}
-		if event.Status == models.EventProblem.String() {
+		if event.Status == models.EventProblem.String() || event.Status == models.EventFlapping.String() {
 			res.ProblemCount++
 		} else {
 			res.OKCount++
@@ -23,10 +23,11 @@ var (
 	recvOKDiff       = expvar.NewDiff("alert.recv_ok")
 	recvOutdatedDiff = expvar.NewDiff("alert.recv_outdated")
 	recvProblemDiff  = expvar.NewDiff("alert.recv_problem")
+	recvFlappingDiff = expvar.NewDiff("alert.recv_flapping")
 )
 
 func init() {
-	expvar.Register(recvDiff, recvClosedDiff, recvOKDiff, recvOutdatedDiff, recvProblemDiff)
+	expvar.Register(recvDiff, recvClosedDiff, recvOKDiff, recvOutdatedDiff, recvProblemDiff, recvFlappingDiff)
 }
 
 // Register registers processer to handle metrics
@@ -53,6 +54,8 @@ func Process(evtCh <-chan redisdata.EventRecord) {
 					recvOutdatedDiff.Incr(1)
 				case models.EventProblem.String():
 					recvProblemDiff.Incr(1)
+				case models.EventFlapping.String():
+					recvFlappingDiff.Incr(1)
 				}
 				go handleEvent(evt)
 			}
@@ -88,7 +88,7 @@ func Call(record redisdata.EventRecord) {
 		go CallMsgg(record.Event, st)
 	}
 
-	if record.Event.Status == models.EventProblem.String() {
+	if record.Event.Status == models.EventProblem.String() || record.Event.Status == models.EventFlapping.String() {
 		// fill uic
 		uic := "000"
 		action := configapi.AlarmActionForStrategy(st.ID)
@@ -16,10 +16,15 @@ type Data struct {
 	Templates    map[int]*models.Template    `json:"templates,omitempty"`
 	AlarmActions map[int]*models.AlarmAction `json:"alarm_actions,omitempty"`
 
-	GroupPlugins   map[int][]string `json:"group_plugins,omitempty"`
-	GroupHosts     map[int][]int    `json:"group_hosts,omitempty"`
-	GroupTemplates map[int][]int    `json:"group_templates,omitempty"`
-	GroupNames     map[int]string   `json:"group_names,omitempty"`
+	GroupPlugins    map[int][]string                           `json:"group_plugins,omitempty"`
+	GroupHosts      map[int][]int                              `json:"group_hosts,omitempty"`
+	GroupTemplates  map[int][]int                              `json:"group_templates,omitempty"`
+	GroupNames      map[int]string                             `json:"group_names,omitempty"`
+	PluginIntegrity map[string]*models.PluginIntegrity         `json:"plugin_integrity,omitempty"`
+	GroupProcesses  map[int][]*models.ProcessRule              `json:"group_processes,omitempty"`
+	GroupLogs       map[int][]*models.LogRule                  `json:"group_logs,omitempty"`
+	GroupProbes     map[int][]*models.Probe                    `json:"group_probes,omitempty"`
+	GroupCollectors map[int]map[string]*models.CollectorOption `json:"group_collectors,omitempty"`
 
 	HostNames     map[int]string                 `json:"host_names,omitempty"`
 	HostInfos     map[string]string              `json:"-"`
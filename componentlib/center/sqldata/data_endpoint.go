@@ -23,12 +23,17 @@ func (eps *Endpoints) Len() int {
 
 // Endpoints is all configs to build each config of endpoints
 type Endpoints struct {
-	GroupStrategy map[string][]int
-	HostGroupKeys map[string]string
-	GroupPlugins  map[string][]string
-	Strategies    map[int]*models.Strategy
-	HostMaintians map[string]int64
-	CRC           uint32
+	GroupStrategy  map[string][]int
+	HostGroupKeys  map[string]string
+	GroupPlugins   map[string][]string
+	Integrity      map[string]*models.PluginIntegrity
+	GroupProcess   map[string][]*models.ProcessRule
+	GroupLogs      map[string][]*models.LogRule
+	GroupProbes    map[string][]*models.Probe
+	GroupCollector map[string]map[string]*models.CollectorOption
+	Strategies     map[int]*models.Strategy
+	HostMaintians  map[string]int64
+	CRC            uint32
 
 	cachedConfigs map[string]*models.EndpointConfig
 	cachedLock    sync.RWMutex
@@ -49,8 +54,14 @@ func (eps *Endpoints) Endpoint(endpoint string) *models.EndpointConfig {
 	}
 	slist := eps.GroupStrategy[key]
 	epData = &models.EndpointConfig{
-		Plugins: eps.GroupPlugins[key],
-		Builtin: &models.EndpointBuiltin{},
+		Plugins:          eps.GroupPlugins[key],
+		PluginsIntegrity: eps.pluginsIntegrity(eps.GroupPlugins[key]),
+		Processes:        eps.GroupProcess[key],
+		Logs:             eps.GroupLogs[key],
+		Builtin: &models.EndpointBuiltin{
+			Probes:     eps.GroupProbes[key],
+			Collectors: eps.GroupCollector[key],
+		},
 	}
 	if eps.HostMaintians[endpoint] > 0 {
 		eps.cachedLock.Lock()
@@ -74,6 +85,27 @@ func (eps *Endpoints) Endpoint(endpoint string) *models.EndpointConfig {
 	return epData
 }
 
+// pluginsIntegrity returns integrity of plugins dirs,
+// agent runs sys dir if no dirs
+func (eps *Endpoints) pluginsIntegrity(dirs []string) map[string]*models.PluginIntegrity {
+	if len(eps.Integrity) == 0 {
+		return nil
+	}
+	if len(dirs) == 0 {
+		dirs = []string{"sys"}
+	}
+	var result map[string]*models.PluginIntegrity
+	for _, dir := range dirs {
+		if ig := eps.Integrity[dir]; ig != nil {
+			if result == nil {
+				result = make(map[string]*models.PluginIntegrity, len(dirs))
+			}
+			result[dir] = ig
+		}
+	}
+	return result
+}
+
 func setBuiltin(ca *models.EndpointConfig, s *models.Strategy) {
 	if s.Metric == "net_port_listen" {
 		tags, err := models.ExtractTags(s.TagString)
@@ -101,8 +133,14 @@ func (eps *Endpoints) BuildAll() {
 			continue
 		}
 		epData := &models.EndpointConfig{
-			Plugins: eps.GroupPlugins[key],
-			Builtin: &models.EndpointBuiltin{},
+			Plugins:          eps.GroupPlugins[key],
+			PluginsIntegrity: eps.pluginsIntegrity(eps.GroupPlugins[key]),
+			Processes:        eps.GroupProcess[key],
+			Logs:             eps.GroupLogs[key],
+			Builtin: &models.EndpointBuiltin{
+				Probes:     eps.GroupProbes[key],
+				Collectors: eps.GroupCollector[key],
+			},
 		}
 		for _, sid := range slist {
 			s := eps.Strategies[sid]
@@ -123,6 +161,11 @@ func (eps *Endpoints) BuildAll() {
 		}
 		if cacheData != nil {
 			epData.Plugins = cacheData.Plugins
+			epData.PluginsIntegrity = cacheData.PluginsIntegrity
+			epData.Processes = cacheData.Processes
+			epData.Logs = cacheData.Logs
+			epData.Builtin.Probes = cacheData.Builtin.Probes
+			epData.Builtin.Collectors = cacheData.Builtin.Collectors
 		}
 		eps.HostGroupKeys[endpoint] = endpoint
 		eps.cachedConfigs[endpoint] = epData
@@ -175,6 +218,10 @@ func (da *Data) buildEndpoints() {
 	hostGroupKeys := make(map[string]string, len(da.HostNames))
 	groupKeyStrategies := make(map[string][]int, len(da.GroupHosts))
 	groupPlugins := make(map[string][]string, len(da.GroupHosts))
+	groupProcesses := make(map[string][]*models.ProcessRule, len(da.GroupHosts))
+	groupLogs := make(map[string][]*models.LogRule, len(da.GroupHosts))
+	groupProbes := make(map[string][]*models.Probe, len(da.GroupHosts))
+	groupCollectors := make(map[string]map[string]*models.CollectorOption, len(da.GroupHosts))
 	for hostID, groupIDs := range da.GroupHosts {
 		name := da.HostNames[hostID]
 		if name == "" {
@@ -201,11 +248,59 @@ func (da *Data) buildEndpoints() {
 			sort.Sort(sort.StringSlice(plugins))
 			groupPlugins[key] = plugins
 		}
+		if _, ok := groupProcesses[key]; !ok {
+			var (
+				rules []*models.ProcessRule
+				names = make(map[string]bool)
+			)
+			for _, groupID := range groupIDs {
+				for _, rule := range da.GroupProcesses[groupID] {
+					if !names[rule.Name] {
+						names[rule.Name] = true
+						rules = append(rules, rule)
+					}
+				}
+			}
+			groupProcesses[key] = rules
+		}
+		if _, ok := groupLogs[key]; !ok {
+			var rules []*models.LogRule
+			for _, groupID := range groupIDs {
+				rules = append(rules, da.GroupLogs[groupID]...)
+			}
+			groupLogs[key] = rules
+		}
+		if _, ok := groupProbes[key]; !ok {
+			var probes []*models.Probe
+			for _, groupID := range groupIDs {
+				probes = append(probes, da.GroupProbes[groupID]...)
+			}
+			groupProbes[key] = probes
+		}
+		if _, ok := groupCollectors[key]; !ok {
+			var options map[string]*models.CollectorOption
+			for _, groupID := range groupIDs {
+				for name, opt := range da.GroupCollectors[groupID] {
+					if options == nil {
+						options = make(map[string]*models.CollectorOption)
+					}
+					if options[name] == nil {
+						options[name] = opt
+					}
+				}
+			}
+			groupCollectors[key] = options
+		}
 	}
 
 	eps.HostGroupKeys = hostGroupKeys
 	eps.GroupStrategy = groupKeyStrategies
 	eps.GroupPlugins = groupPlugins
+	eps.Integrity = da.PluginIntegrity
+	eps.GroupProcess = groupProcesses
+	eps.GroupLogs = groupLogs
+	eps.GroupProbes = groupProbes
+	eps.GroupCollector = groupCollectors
 	eps.Strategies = make(map[int]*models.Strategy, len(da.Strategies))
 	// simplify strategy data
 	for id, s := range da.Strategies {
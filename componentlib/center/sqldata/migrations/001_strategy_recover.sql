@@ -0,0 +1,7 @@
+-- hysteresis and flap detection of strategies, read by sqldata.ReadStrategies
+-- empty recover_value and zero counts keep the old behavior, missing columns are read as empty
+ALTER TABLE `strategy`
+  ADD COLUMN `recover_value` VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'value to recover from problem',
+  ADD COLUMN `recover_count` INT NOT NULL DEFAULT 0 COMMENT 'consecutive ok count to recover from problem',
+  ADD COLUMN `flap_count` INT NOT NULL DEFAULT 0 COMMENT 'status changes in window to be flapping',
+  ADD COLUMN `flap_window` INT NOT NULL DEFAULT 0 COMMENT 'flapping window in seconds';
@@ -0,0 +1,10 @@
+-- weekday, calendar and timezone-aware running windows of strategies and expressions,
+-- read by sqldata.ReadStrategies and sqldata.ReadExpressions
+-- empty timezone uses timezone of center, missing columns are read as empty
+ALTER TABLE `strategy`
+  ADD COLUMN `schedule` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'running windows, such as mon-fri 09:00-18:00;!2018-10-01',
+  ADD COLUMN `timezone` VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'IANA timezone of run time and schedule';
+
+ALTER TABLE `expression`
+  ADD COLUMN `schedule` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'running windows, such as mon-fri 09:00-18:00;!2018-10-01',
+  ADD COLUMN `timezone` VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'IANA timezone of schedule';
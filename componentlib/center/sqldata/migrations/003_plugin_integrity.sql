@@ -0,0 +1,16 @@
+-- expected hashes and signing keys of plugins dirs, read by sqldata.ReadPluginIntegrity
+-- missing tables are read as no integrity checking
+CREATE TABLE IF NOT EXISTS `plugin_hash` (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `dir` VARCHAR(100) NOT NULL COMMENT 'plugins dir, such as sys',
+  `file` VARCHAR(150) NOT NULL COMMENT 'file path relative to dir',
+  `hash` CHAR(64) NOT NULL COMMENT 'sha256 hex',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_dir_file` (`dir`, `file`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `plugin_key` (
+  `dir` VARCHAR(100) NOT NULL COMMENT 'plugins dir, such as sys',
+  `pubkey` VARCHAR(64) NOT NULL COMMENT 'base64 ed25519 public key to verify <file>.sig',
+  PRIMARY KEY (`dir`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,13 @@
+-- process matching rules of host groups, read by sqldata.ReadGroupProcesses
+-- missing table is read as no rules
+CREATE TABLE IF NOT EXISTS `process_rule` (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `group_id` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(64) NOT NULL COMMENT 'tag value of process metric',
+  `process` VARCHAR(255) DEFAULT NULL COMMENT 'process name in /proc/<pid>/comm',
+  `cmdline` VARCHAR(255) DEFAULT NULL COMMENT 'regexp to match command line',
+  `pidfile` VARCHAR(255) DEFAULT NULL COMMENT 'path of pid file',
+  `unit` VARCHAR(255) DEFAULT NULL COMMENT 'systemd unit, such as nginx.service',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_group_name` (`group_id`, `name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
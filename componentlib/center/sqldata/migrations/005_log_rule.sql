@@ -0,0 +1,11 @@
+-- log matching rules of host groups, read by sqldata.ReadGroupLogs
+-- missing table is read as no rules
+CREATE TABLE IF NOT EXISTS `log_rule` (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `group_id` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(64) NOT NULL COMMENT 'metric name',
+  `file` VARCHAR(255) NOT NULL COMMENT 'file path, glob pattern is allowed',
+  `pattern` VARCHAR(1024) NOT NULL COMMENT 'regexp, named captures are fields',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_group_name` (`group_id`, `name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
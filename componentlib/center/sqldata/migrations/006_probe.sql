@@ -0,0 +1,16 @@
+-- synthetic probe targets of host groups, read by sqldata.ReadGroupProbes
+-- missing table is read as no probes, NULL optional columns are read as empty
+CREATE TABLE IF NOT EXISTS `probe` (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `group_id` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(64) NOT NULL COMMENT 'tag value of probe metric',
+  `type` VARCHAR(16) NOT NULL COMMENT 'http, tcp, dns or tls',
+  `target` VARCHAR(255) NOT NULL COMMENT 'url for http, host:port for tcp and tls, domain for dns',
+  `timeout` INT DEFAULT NULL COMMENT 'seconds',
+  `status` INT DEFAULT NULL COMMENT 'expected http status, status < 400 is success if 0',
+  `body` VARCHAR(1024) DEFAULT NULL COMMENT 'regexp to match http body',
+  `record` VARCHAR(16) DEFAULT NULL COMMENT 'dns record type, A if empty',
+  `expect` VARCHAR(255) DEFAULT NULL COMMENT 'string contained by one of dns answers',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_group_name` (`group_id`, `name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,12 @@
+-- system collector options of host groups, read by sqldata.ReadGroupCollectors
+-- missing table is read as no options, NULL enable keeps collector default
+CREATE TABLE IF NOT EXISTS `collector_option` (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `group_id` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(64) NOT NULL COMMENT 'builtin collector name',
+  `interval` INT DEFAULT NULL COMMENT 'seconds, collector default if 0',
+  `timeout` INT DEFAULT NULL COMMENT 'seconds, collector default if 0',
+  `enable` TINYINT(1) DEFAULT NULL COMMENT 'collector default if NULL',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_group_name` (`group_id`, `name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -1,6 +1,7 @@
 package sqldata
 
 import (
+	"database/sql"
 	"strings"
 	"time"
 
@@ -36,6 +37,194 @@ func ReadGroupPlugins() (map[int][]string, error) {
 	return plugins, nil
 }
 
+var (
+	selectGroupProcessesSQL = "SELECT group_id, name, IFNULL(process,''), IFNULL(cmdline,''), IFNULL(pidfile,''), IFNULL(unit,'') FROM process_rule ORDER BY group_id ASC, name ASC"
+)
+
+// ReadGroupProcesses reads process matching rules for each group
+// return as map[groupID][rule1,rule2]
+func ReadGroupProcesses() (map[int][]*models.ProcessRule, error) {
+	rules := make(map[int][]*models.ProcessRule)
+	rows, err := portalDB.Query(selectGroupProcessesSQL)
+	if err != nil {
+		if isTableMissing(err) {
+			log.Debug("process-rule-missing", "error", err)
+			return rules, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			groupID int
+			rule    = new(models.ProcessRule)
+		)
+		if err = rows.Scan(&groupID, &rule.Name, &rule.Process, &rule.Cmdline, &rule.Pidfile, &rule.Unit); err != nil {
+			continue
+		}
+		rules[groupID] = append(rules[groupID], rule)
+	}
+	return rules, nil
+}
+
+var (
+	selectGroupLogsSQL = "SELECT group_id, name, file, pattern FROM log_rule ORDER BY group_id ASC, name ASC"
+)
+
+// ReadGroupLogs reads log matching rules for each group
+// return as map[groupID][rule1,rule2]
+func ReadGroupLogs() (map[int][]*models.LogRule, error) {
+	rules := make(map[int][]*models.LogRule)
+	rows, err := portalDB.Query(selectGroupLogsSQL)
+	if err != nil {
+		if isTableMissing(err) {
+			log.Debug("log-rule-missing", "error", err)
+			return rules, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			groupID int
+			rule    = new(models.LogRule)
+		)
+		if err = rows.Scan(&groupID, &rule.Name, &rule.File, &rule.Pattern); err != nil {
+			continue
+		}
+		rules[groupID] = append(rules[groupID], rule)
+	}
+	return rules, nil
+}
+
+var (
+	selectGroupProbesSQL = "SELECT group_id, name, type, target, IFNULL(timeout,0), IFNULL(status,0), IFNULL(body,''), IFNULL(record,''), IFNULL(expect,'') FROM probe ORDER BY group_id ASC, name ASC"
+)
+
+// ReadGroupProbes reads synthetic probe targets for each group
+// return as map[group_id]probes
+func ReadGroupProbes() (map[int][]*models.Probe, error) {
+	probes := make(map[int][]*models.Probe)
+	rows, err := portalDB.Query(selectGroupProbesSQL)
+	if err != nil {
+		if isTableMissing(err) {
+			log.Debug("probe-missing", "error", err)
+			return probes, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			groupID int
+			p       = new(models.Probe)
+		)
+		if err = rows.Scan(&groupID, &p.Name, &p.Type, &p.Target, &p.Timeout, &p.Status, &p.Body, &p.Record, &p.Expect); err != nil {
+			log.Warn("probe-scan-error", "error", err)
+			continue
+		}
+		probes[groupID] = append(probes[groupID], p)
+	}
+	return probes, nil
+}
+
+var (
+	selectGroupCollectorsSQL = "SELECT group_id, name, IFNULL(`interval`,0), IFNULL(timeout,0), enable FROM collector_option ORDER BY group_id ASC, name ASC"
+)
+
+// ReadGroupCollectors reads system collector options for each group
+// return as map[group_id]map[collector_name]option, NULL enable keeps collector default
+func ReadGroupCollectors() (map[int]map[string]*models.CollectorOption, error) {
+	options := make(map[int]map[string]*models.CollectorOption)
+	rows, err := portalDB.Query(selectGroupCollectorsSQL)
+	if err != nil {
+		if isTableMissing(err) {
+			log.Debug("collector-option-missing", "error", err)
+			return options, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			groupID int
+			name    string
+			enable  sql.NullBool
+			opt     = new(models.CollectorOption)
+		)
+		if err = rows.Scan(&groupID, &name, &opt.Interval, &opt.Timeout, &enable); err != nil {
+			log.Warn("collector-option-scan-error", "error", err)
+			continue
+		}
+		if enable.Valid {
+			opt.Enable = &enable.Bool
+		}
+		if options[groupID] == nil {
+			options[groupID] = make(map[string]*models.CollectorOption)
+		}
+		options[groupID][name] = opt
+	}
+	return options, nil
+}
+
+var (
+	selectPluginHashesSQL = "SELECT dir, file, hash FROM plugin_hash ORDER BY dir ASC"
+	selectPluginKeysSQL   = "SELECT dir, pubkey FROM plugin_key ORDER BY dir ASC"
+)
+
+// ReadPluginIntegrity reads expected file hashes and signing keys for each plugins dir
+// return as map[dir]integrity
+func ReadPluginIntegrity() (map[string]*models.PluginIntegrity, error) {
+	integrity := make(map[string]*models.PluginIntegrity)
+	get := func(dir string) *models.PluginIntegrity {
+		ig := integrity[dir]
+		if ig == nil {
+			ig = &models.PluginIntegrity{Hashes: make(map[string]string)}
+			integrity[dir] = ig
+		}
+		return ig
+	}
+
+	rows, err := portalDB.Query(selectPluginHashesSQL)
+	if err != nil {
+		if !isTableMissing(err) {
+			return nil, err
+		}
+		log.Debug("plugin-hash-missing", "error", err)
+	} else {
+		defer rows.Close()
+		for rows.Next() {
+			var dir, file, hash string
+			if err = rows.Scan(&dir, &file, &hash); err != nil {
+				continue
+			}
+			get(dir).Hashes[file] = strings.ToLower(hash)
+		}
+	}
+
+	keyRows, err := portalDB.Query(selectPluginKeysSQL)
+	if err != nil {
+		if !isTableMissing(err) {
+			return nil, err
+		}
+		log.Debug("plugin-key-missing", "error", err)
+		return integrity, nil
+	}
+	defer keyRows.Close()
+	for keyRows.Next() {
+		var dir, pubkey string
+		if err = keyRows.Scan(&dir, &pubkey); err != nil {
+			continue
+		}
+		get(dir).PublicKey = pubkey
+	}
+	return integrity, nil
+}
+
 var (
 	selectGroupHostsSQL = "SELECT group_id, host_id FROM group_host ORDER BY group_id ASC, host_id ASC"
 )
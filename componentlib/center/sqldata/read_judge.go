@@ -35,13 +35,22 @@ func ReadTemplates() (map[int]*models.Template, error) {
 	return templates, nil
 }
 
+var defaultTimezone string
+
+// SetDefaultTimezone sets timezone of strategies and expressions that have running windows but no timezone
+func SetDefaultTimezone(tz string) {
+	defaultTimezone = tz
+}
+
 var (
-	selectStrategiesSQL = "SELECT id, metric, tags, func, field_transform, op, right_value, max_step,step, priority, note, template_id, run_begin, run_end, recover_notify, no_data, silences_time, mark_tags, status FROM strategy ORDER BY id ASC"
+	selectStrategiesSQL = "SELECT id, metric, tags, func, field_transform, op, right_value, max_step,step, priority, note, template_id, run_begin, run_end, recover_notify, no_data, silences_time, mark_tags, status, IFNULL(recover_value,'') AS recover_value, IFNULL(recover_count,0) AS recover_count, IFNULL(flap_count,0) AS flap_count, IFNULL(flap_window,0) AS flap_window, IFNULL(schedule,'') AS schedule, IFNULL(timezone,'') AS timezone FROM strategy ORDER BY id ASC"
+	// selectStrategiesLegacySQL reads strategy table before migrations 001 and 002
+	selectStrategiesLegacySQL = "SELECT id, metric, tags, func, field_transform, op, right_value, max_step,step, priority, note, template_id, run_begin, run_end, recover_notify, no_data, silences_time, mark_tags, status, '' AS recover_value, 0 AS recover_count, 0 AS flap_count, 0 AS flap_window, '' AS schedule, '' AS timezone FROM strategy ORDER BY id ASC"
 )
 
-// ReadStrategies reads all strategies as map
+// ReadStrategies reads all strategies as map, columns not migrated yet are read as empty
 func ReadStrategies() (map[int]*models.Strategy, error) {
-	rows, err := portalDB.Queryx(selectStrategiesSQL)
+	rows, err := queryxWithLegacy("strategy", selectStrategiesSQL, selectStrategiesLegacySQL)
 	if err != nil {
 		return nil, err
 	}
@@ -65,6 +74,14 @@ func ReadStrategies() (map[int]*models.Strategy, error) {
 			continue
 		}
 		s.RightValue = rightValue
+		if s.RecoverValueStr != "" {
+			if recoverValue, err := strconv.ParseFloat(s.RecoverValueStr, 64); err == nil {
+				s.RecoverValue = &recoverValue
+			}
+		}
+		if s.Timezone == "" && (s.Schedule != "" || (s.RunBegin != "" && s.RunEnd != "")) {
+			s.Timezone = defaultTimezone
+		}
 		if s.ID > 0 {
 			s2 := &s
 			s2.Marks()
@@ -76,12 +93,14 @@ func ReadStrategies() (map[int]*models.Strategy, error) {
 }
 
 var (
-	selectExpressionSQL = "SELECT id,expression,op,right_value,max_step,priority,note FROM expression ORDER BY id ASC"
+	selectExpressionSQL = "SELECT id,expression,op,right_value,max_step,priority,note,IFNULL(schedule,'') AS schedule,IFNULL(timezone,'') AS timezone FROM expression ORDER BY id ASC"
+	// selectExpressionLegacySQL reads expression table before migration 002
+	selectExpressionLegacySQL = "SELECT id,expression,op,right_value,max_step,priority,note,'' AS schedule,'' AS timezone FROM expression ORDER BY id ASC"
 )
 
-// ReadExpressions reads all expressions as map
+// ReadExpressions reads all expressions as map, columns not migrated yet are read as empty
 func ReadExpressions() (map[int]*models.Expression, error) {
-	rows, err := portalDB.Queryx(selectExpressionSQL)
+	rows, err := queryxWithLegacy("expression", selectExpressionSQL, selectExpressionLegacySQL)
 	if err != nil {
 		return nil, err
 	}
@@ -105,6 +124,9 @@ func ReadExpressions() (map[int]*models.Expression, error) {
 			continue
 		}
 		s.RightValue = rightValue
+		if s.Timezone == "" && s.Schedule != "" {
+			s.Timezone = defaultTimezone
+		}
 		if s.ID > 0 {
 			s2 := &s
 			exps[s2.ID] = s2
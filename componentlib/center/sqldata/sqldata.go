@@ -5,6 +5,7 @@ import (
 
 	"github.com/baishancloud/mallard/corelib/utils"
 	"github.com/baishancloud/mallard/corelib/zaplog"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -21,6 +22,34 @@ func SetDB(portal, uic *sqlx.DB) {
 	uicDB = uic
 }
 
+// isTableMissing checks error is mysql error of table doesn't exist,
+// tables of optional agent features are read as no data if they are not migrated yet
+func isTableMissing(err error) bool {
+	if e, ok := err.(*mysql.MySQLError); ok {
+		return e.Number == 1146
+	}
+	return false
+}
+
+// isColumnMissing checks error is mysql error of unknown column,
+// new columns of existing tables are read as empty values if they are not migrated yet
+func isColumnMissing(err error) bool {
+	if e, ok := err.(*mysql.MySQLError); ok {
+		return e.Number == 1054
+	}
+	return false
+}
+
+// queryxWithLegacy queries by query, and queries by legacy query without new columns if the columns are missing
+func queryxWithLegacy(name, query, legacyQuery string) (*sqlx.Rows, error) {
+	rows, err := portalDB.Queryx(query)
+	if err != nil && isColumnMissing(err) {
+		log.Warn(name+"-columns-missing", "error", err)
+		return portalDB.Queryx(legacyQuery)
+	}
+	return rows, err
+}
+
 func checkDB(db *sqlx.DB) {
 	if db == nil {
 		log.Fatal("db-nil")
@@ -56,6 +85,31 @@ func Read() (*Data, error) {
 	}
 	log.Debug("read-group-plugins", "groups", len(data.GroupPlugins))
 
+	if data.PluginIntegrity, err = ReadPluginIntegrity(); err != nil {
+		return nil, err
+	}
+	log.Debug("read-plugin-integrity", "dirs", len(data.PluginIntegrity))
+
+	if data.GroupProcesses, err = ReadGroupProcesses(); err != nil {
+		return nil, err
+	}
+	log.Debug("read-group-processes", "groups", len(data.GroupProcesses))
+
+	if data.GroupLogs, err = ReadGroupLogs(); err != nil {
+		return nil, err
+	}
+	log.Debug("read-group-logs", "groups", len(data.GroupLogs))
+
+	if data.GroupProbes, err = ReadGroupProbes(); err != nil {
+		return nil, err
+	}
+	log.Debug("read-group-probes", "groups", len(data.GroupProbes))
+
+	if data.GroupCollectors, err = ReadGroupCollectors(); err != nil {
+		return nil, err
+	}
+	log.Debug("read-group-collectors", "groups", len(data.GroupCollectors))
+
 	if data.GroupHosts, err = ReadGroupHosts(); err != nil {
 		return nil, err
 	}
@@ -20,12 +20,14 @@ var (
 	alarmNOCount         = expvar.NewDiff("rd.alarm_no")
 	alarmClosedCount     = expvar.NewDiff("rd.alarm_closed")
 	alarmOutdatedCount   = expvar.NewDiff("rd.alarm_outdated")
+	alarmFlappingCount   = expvar.NewDiff("rd.alarm_flapping")
 	alarmNODATACount     = expvar.NewDiff("rd.alarm_nodata")
 	alarmHappenCount     = expvar.NewBase("rd.alarm_happening")
 	alarmHandleFailCount = expvar.NewDiff("rd.alarm_handle_fail")
 
 	recvOKCount      = expvar.NewDiff("rd.recv_ok")
 	recvProblemCount = expvar.NewDiff("rd.recv_problem")
+	recvFlapping     = expvar.NewDiff("rd.recv_flapping")
 	recvClosed       = expvar.NewDiff("rd.recv_closed")
 	recvOutdated     = expvar.NewDiff("rd.recv_outdated")
 	recvMaintains    = expvar.NewDiff("rd.recv_maintains")
@@ -34,10 +36,10 @@ var (
 
 func init() {
 	expvar.Register(alarmCount, alarmOKCount, alarmNOCount,
-		alarmClosedCount, alarmOutdatedCount,
+		alarmClosedCount, alarmOutdatedCount, alarmFlappingCount,
 		alarmNODATACount, alarmHappenCount,
 		alarmHandleFailCount,
-		recvOKCount, recvProblemCount,
+		recvOKCount, recvProblemCount, recvFlapping,
 		recvClosed, recvMaintains, recvOutdated, recvOuttime)
 }
 
@@ -185,6 +187,28 @@ func Check(event *models.Event) (int, error) {
 		}
 		return opAlarm, nil
 	}
+	// agent sends flapping event once when it starts flapping,
+	// then holds the event until status is stable and sends it again in some steps to refresh alarm
+	if event.Status == models.EventFlapping {
+		recvFlapping.Incr(1)
+		if redisdata.CheckEndpointMaintain(event.Endpoint) {
+			log.Info("ignore-maintain", "status", event.Status.String(), "eid", event.ID, "endpoint", event.Endpoint)
+			recvMaintains.Incr(1)
+			return opIgore, nil
+		}
+		if isInProblem {
+			if last, err := redisdata.GetAlarmingEvent(event.ID); err == nil && last.Status == models.EventFlapping {
+				if err := SaveAlarming(event, false); err != nil {
+					log.Warn("save-event-error", "eid", event.ID, "status", event.Status.String(), "error", err)
+				}
+				return opUpdate, nil
+			}
+		}
+		if err := SaveAlarming(event, !isInProblem); err != nil {
+			log.Warn("save-event-error", "eid", event.ID, "status", event.Status.String(), "error", err)
+		}
+		return opAlarm, nil
+	}
 	return opIgore, nil
 }
 
@@ -213,7 +237,7 @@ func Handle(event *models.Event, op int, t int64) error {
 		return nil
 	}
 
-	if st, ok := fullEvent.Strategy.(*models.Strategy); ok && event.Status == models.EventProblem {
+	if st, ok := fullEvent.Strategy.(*models.Strategy); ok && (event.Status == models.EventProblem || event.Status == models.EventFlapping) {
 		// if strategy is not in time range, stop resolving the event
 		if !st.IsInTime(t) {
 			Remove(event.ID)
@@ -241,6 +265,8 @@ func Alarm(fullEvent *models.EventFull) {
 		alarmClosedCount.Incr(1)
 	case models.EventOutdated.String():
 		alarmOutdatedCount.Incr(1)
+	case models.EventFlapping.String():
+		alarmFlappingCount.Incr(1)
 	}
 	if strings.HasPrefix("nodata_", fullEvent.ID) {
 		alarmNODATACount.Incr(1)
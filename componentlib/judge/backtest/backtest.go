@@ -0,0 +1,234 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/baishancloud/mallard/componentlib/agent/judger"
+	"github.com/baishancloud/mallard/componentlib/judge/baseline"
+	"github.com/baishancloud/mallard/componentlib/judge/multijudge"
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/corelib/utils"
+	"github.com/baishancloud/mallard/corelib/zaplog"
+)
+
+var (
+	log = zaplog.Zap("backtest")
+
+	// ErrNoRule means request has no strategy or expression
+	ErrNoRule = errors.New("no-strategy-or-expression")
+	// ErrInvalidRange means request time range is invalid
+	ErrInvalidRange = errors.New("invalid-time-range")
+	// ErrRangeTooLarge means request time range is larger than limit
+	ErrRangeTooLarge = errors.New("time-range-too-large")
+	// ErrTooManyMetrics means metrics in request time range are more than limit
+	ErrTooManyMetrics = errors.New("too-many-metrics")
+)
+
+type (
+	// Request is backtest request with candidate strategy or expression in time range
+	Request struct {
+		Strategy   *models.Strategy   `json:"strategy,omitempty"`
+		Expression *models.Expression `json:"expression,omitempty"`
+		Start      int64              `json:"start"`
+		End        int64              `json:"end"`
+	}
+	// Event is event that would have fired in backtest
+	Event struct {
+		ID         string            `json:"id"`
+		Strategy   int               `json:"st,omitempty"`
+		Expression int               `json:"exp,omitempty"`
+		Endpoint   string            `json:"ep,omitempty"`
+		Tags       map[string]string `json:"tags,omitempty"`
+		LeftValue  float64           `json:"lv"`
+		Start      int64             `json:"start"`
+		End        int64             `json:"end"`
+		Duration   int64             `json:"duration"`
+		Recovered  bool              `json:"recovered"`
+	}
+	// Limit limits time range and metrics count of backtest, zero is no limit
+	Limit struct {
+		MaxRange   int64 // seconds
+		MaxMetrics int
+	}
+	// Result is backtest result
+	Result struct {
+		Files   int      `json:"files"`
+		Metrics int      `json:"metrics"`
+		Events  []*Event `json:"events"`
+	}
+)
+
+// Run runs backtest request with recorded metrics in dirs
+func Run(req *Request, dirs []string) (*Result, error) {
+	return RunWithLimit(req, dirs, Limit{})
+}
+
+// RunWithLimit runs backtest as Run, but fails if time range or metrics count exceeds limit
+func RunWithLimit(req *Request, dirs []string, limit Limit) (*Result, error) {
+	if req.Strategy == nil && req.Expression == nil {
+		return nil, ErrNoRule
+	}
+	if req.Start <= 0 || req.End < req.Start {
+		return nil, ErrInvalidRange
+	}
+	if limit.MaxRange > 0 && req.End-req.Start > limit.MaxRange {
+		return nil, ErrRangeTooLarge
+	}
+	names := make(map[string]bool)
+	if req.Strategy != nil {
+		strategyNames, err := strategyMetrics(req.Strategy)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range strategyNames {
+			names[name] = true
+		}
+	} else {
+		for _, name := range req.Expression.Metrics() {
+			names[name] = true
+		}
+	}
+	files, err := FindFiles(dirs, names, req.Start, req.End)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := ReadFiles(files, names, req.Start, req.End, limit.MaxMetrics)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{
+		Files:   len(files),
+		Metrics: len(metrics),
+	}
+	if req.Strategy != nil {
+		result.Events, err = RunStrategy(req.Strategy, metrics)
+	} else {
+		result.Events, err = RunExpression(req.Expression, metrics)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Info("run", "files", len(files), "metrics", len(metrics), "events", len(result.Events))
+	return result, nil
+}
+
+// strategyMetrics returns metric names accepted by strategy unit, including joined metric
+func strategyMetrics(st *models.Strategy) ([]string, error) {
+	if st.IsBaseline() {
+		return []string{st.Metric}, nil
+	}
+	unit, err := judger.NewUnit(st)
+	if err != nil {
+		return nil, err
+	}
+	return unit.Metrics(), nil
+}
+
+// RunStrategy replays metrics sorted by time through fresh strategy unit,
+// running windows, recover value and hysteresis are applied as agent judger does
+func RunStrategy(st *models.Strategy, metrics []*models.Metric) ([]*Event, error) {
+	if st.IsBaseline() {
+		return runBaseline(st, metrics)
+	}
+	unit, err := judger.NewUnit(st)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		tr      = newTracker()
+		current = judger.NewCurrent()
+	)
+	for _, metric := range metrics {
+		if !unit.Accept(metric) {
+			continue
+		}
+		m, ok := unit.Join(metric)
+		if !ok {
+			continue
+		}
+		event, _ := judger.JudgeUnit(unit, m, current, m.Time)
+		if event == nil {
+			continue
+		}
+		tr.update(&Event{
+			ID:        event.ID,
+			Strategy:  st.ID,
+			Endpoint:  m.Endpoint,
+			Tags:      m.Tags,
+			LeftValue: event.LeftValue,
+		}, event.Status, m.Time)
+	}
+	return tr.finish(), nil
+}
+
+func runBaseline(st *models.Strategy, metrics []*models.Metric) ([]*Event, error) {
+	store := baseline.NewStore(baseline.DefaultMaxDays)
+	unit, err := baseline.NewUnit(st, store.MaxDays())
+	if err != nil {
+		return nil, err
+	}
+	tr := newTracker()
+	for _, metric := range metrics {
+		if !unit.Accept(metric) {
+			continue
+		}
+		event, err := unit.Check(metric, store)
+		if err != nil || event == nil {
+			continue
+		}
+		tr.update(&Event{
+			ID:        event.ID,
+			Strategy:  st.ID,
+			Endpoint:  event.Endpoint,
+			Tags:      event.Tags,
+			LeftValue: event.LeftValue,
+		}, event.Status, metric.Time)
+	}
+	return tr.finish(), nil
+}
+
+// RunExpression replays metrics sorted by time through fresh expression unit,
+// scores are scanned when metrics time changes
+func RunExpression(exp *models.Expression, metrics []*models.Metric) ([]*Event, error) {
+	group := multijudge.NewScoreGroup()
+	unit, err := multijudge.NewExprUnitWithGroup(exp.ID, exp, group)
+	if err != nil {
+		return nil, err
+	}
+	tr := newTracker()
+	scan := func(t int64) {
+		results := group.Scan()
+		seen := make(map[string]bool, len(results))
+		for hash, result := range results {
+			status := models.EventOk
+			if unit.CheckScore(result.Total) {
+				status = models.EventProblem
+			}
+			evt := &Event{
+				ID:         fmt.Sprintf("e_%d_%s", exp.ID, utils.MD5HashString(hash)),
+				Expression: exp.ID,
+				Endpoint:   strings.TrimPrefix(hash, fmt.Sprintf("%d~", exp.ID)),
+				LeftValue:  result.Total,
+			}
+			seen[evt.ID] = true
+			tr.update(evt, status, t)
+		}
+		tr.recoverUnseen(seen, t)
+	}
+	var lastTime int64
+	for _, metric := range metrics {
+		if lastTime > 0 && metric.Time != lastTime {
+			scan(lastTime)
+		}
+		lastTime = metric.Time
+		if key, ok := unit.Accept(metric); ok {
+			unit.Check(key, metric)
+		}
+	}
+	if lastTime > 0 {
+		scan(lastTime)
+	}
+	return tr.finish(), nil
+}
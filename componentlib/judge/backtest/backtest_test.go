@@ -0,0 +1,161 @@
+package backtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/corelib/zaplog"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func init() {
+	log = zaplog.Null()
+}
+
+func writeTestFile(file string, values []float64, start int64) {
+	f, _ := os.Create(file)
+	defer f.Close()
+	encoder := json.NewEncoder(f)
+	for i, v := range values {
+		encoder.Encode(&models.Metric{
+			Name:     "cpu",
+			Value:    v,
+			Time:     start + int64(i)*60,
+			Endpoint: "host-1",
+		})
+	}
+}
+
+func readTestMetrics(dir string, start int64) []*models.Metric {
+	files, _ := FindFiles([]string{dir}, map[string]bool{"cpu": true}, start, start+3600)
+	metrics, _ := ReadFiles(files, map[string]bool{"cpu": true}, start, start+3600, 0)
+	return metrics
+}
+
+func TestBacktest(t *testing.T) {
+	Convey("backtest", t, func() {
+		dir := "./tests_backtest"
+		os.MkdirAll(dir, os.ModePerm)
+		defer os.RemoveAll(dir)
+		var start int64 = 1500000000
+		writeTestFile(filepath.Join(dir, fmt.Sprintf("cpu_%d.log", start)), []float64{1, 5, 6, 7, 1, 1, 8, 9}, start)
+		writeTestFile(filepath.Join(dir, "mem_1500000000.log"), []float64{1, 2}, start)
+
+		Convey("backtest.files", func() {
+			files, err := FindFiles([]string{dir}, map[string]bool{"cpu": true}, start, start+3600)
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 1)
+			files, _ = FindFiles([]string{dir}, map[string]bool{"cpu": true}, start+3600, start+7200)
+			So(files, ShouldHaveLength, 0)
+		})
+
+		Convey("backtest.strategy", func() {
+			result, err := Run(&Request{
+				Strategy: &models.Strategy{
+					ID:             1,
+					Metric:         "cpu",
+					FieldTransform: "select(value)",
+					Func:           "all(#2)",
+					Operator:       ">",
+					RightValue:     4,
+				},
+				Start: start,
+				End:   start + 3600,
+			}, []string{dir})
+			So(err, ShouldBeNil)
+			So(result.Files, ShouldEqual, 1)
+			So(result.Metrics, ShouldEqual, 8)
+			So(result.Events, ShouldHaveLength, 2)
+			So(result.Events[0].Start, ShouldEqual, start+120)
+			So(result.Events[0].Duration, ShouldEqual, 120)
+			So(result.Events[0].Recovered, ShouldBeTrue)
+			So(result.Events[1].Start, ShouldEqual, start+420)
+			So(result.Events[1].Recovered, ShouldBeFalse)
+		})
+
+		Convey("backtest.limit", func() {
+			req := &Request{
+				Strategy: &models.Strategy{
+					ID:             1,
+					Metric:         "cpu",
+					FieldTransform: "select(value)",
+					Func:           "all(#2)",
+					Operator:       ">",
+					RightValue:     4,
+				},
+				Start: start,
+				End:   start + 3600,
+			}
+			_, err := RunWithLimit(req, []string{dir}, Limit{MaxRange: 600})
+			So(err, ShouldEqual, ErrRangeTooLarge)
+			_, err = RunWithLimit(req, []string{dir}, Limit{MaxMetrics: 5})
+			So(err, ShouldEqual, ErrTooManyMetrics)
+			result, err := RunWithLimit(req, []string{dir}, Limit{MaxRange: 3600, MaxMetrics: 8})
+			So(err, ShouldBeNil)
+			So(result.Metrics, ShouldEqual, 8)
+		})
+
+		Convey("backtest.strategy.recover", func() {
+			events, err := RunStrategy(&models.Strategy{
+				ID:             1,
+				Metric:         "cpu",
+				FieldTransform: "select(value)",
+				Func:           "all(#2)",
+				Operator:       ">",
+				RightValue:     4,
+				RecoverCount:   4,
+			}, readTestMetrics(dir, start))
+			So(err, ShouldBeNil)
+			So(events, ShouldHaveLength, 1) // ok values are not enough to recover
+			So(events[0].Start, ShouldEqual, start+120)
+			So(events[0].Recovered, ShouldBeFalse)
+		})
+
+		Convey("backtest.strategy.schedule", func() {
+			events, err := RunStrategy(&models.Strategy{
+				ID:             1,
+				Metric:         "cpu",
+				FieldTransform: "select(value)",
+				Func:           "all(#2)",
+				Operator:       ">",
+				RightValue:     4,
+				Schedule:       "* 02:40-02:43", // start is 02:40 in UTC
+				Timezone:       "UTC",
+			}, readTestMetrics(dir, start))
+			So(err, ShouldBeNil)
+			So(events, ShouldHaveLength, 1) // problem after 02:43 is out of running windows
+			So(events[0].Start, ShouldEqual, start+120)
+			So(events[0].End, ShouldEqual, start+240)
+			So(events[0].Recovered, ShouldBeTrue)
+		})
+
+		Convey("backtest.expression", func() {
+			result, err := Run(&Request{
+				Expression: &models.Expression{
+					ID:         2,
+					Expression: `["cpu;select(value);all(#1)>4;;endpoint;10"]`,
+					Operator:   ">=",
+					RightValue: 10,
+				},
+				Start: start,
+				End:   start + 3600,
+			}, []string{dir})
+			So(err, ShouldBeNil)
+			So(result.Events, ShouldHaveLength, 2)
+			So(result.Events[0].Start, ShouldEqual, start+60)
+			So(result.Events[0].End, ShouldEqual, start+240)
+			So(result.Events[0].Endpoint, ShouldEqual, "host-1")
+		})
+
+		Convey("backtest.invalid", func() {
+			_, err := Run(&Request{Start: start, End: start + 60}, []string{dir})
+			So(err, ShouldEqual, ErrNoRule)
+			_, err = Run(&Request{Strategy: &models.Strategy{}, Start: start, End: start - 60}, []string{dir})
+			So(err, ShouldEqual, ErrInvalidRange)
+		})
+	})
+}
@@ -0,0 +1,133 @@
+package backtest
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/models"
+)
+
+var (
+	storeFileRegexp = regexp.MustCompile(`^(.+)_(\d+)\.log$`)
+	dailyFileRegexp = regexp.MustCompile(`_(\d{8})\.json(\.gz)?$`)
+)
+
+// FindFiles finds recorded metric files in dirs for metric names in time range,
+// it supports judgestore files as {metric}_{unix}.log and logutil daily files as *_{20060102}.json(.gz)
+func FindFiles(dirs []string, names map[string]bool, start, end int64) ([]string, error) {
+	var files []string
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(fpath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			base := filepath.Base(fpath)
+			if matches := storeFileRegexp.FindStringSubmatch(base); len(matches) == 3 {
+				t, _ := strconv.ParseInt(matches[2], 10, 64)
+				if names[matches[1]] && t+60 >= start && t <= end {
+					files = append(files, fpath)
+				}
+				return nil
+			}
+			if matches := dailyFileRegexp.FindStringSubmatch(base); len(matches) == 3 {
+				day, err := time.ParseInLocation("20060102", matches[1], time.Local)
+				if err != nil {
+					return nil
+				}
+				if day.Unix()+86400 >= start && day.Unix() <= end {
+					files = append(files, fpath)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// ReadFiles reads metrics with names in time range from files, metrics are sorted by time,
+// it returns ErrTooManyMetrics if metrics are more than maxMetrics, no limit if maxMetrics is 0
+func ReadFiles(files []string, names map[string]bool, start, end int64, maxMetrics int) ([]*models.Metric, error) {
+	var metrics []*models.Metric
+	add := func(m *models.Metric) error {
+		if maxMetrics > 0 && len(metrics) >= maxMetrics {
+			return ErrTooManyMetrics
+		}
+		metrics = append(metrics, m)
+		return nil
+	}
+	for _, file := range files {
+		if err := readFile(file, names, start, end, add); err != nil {
+			if err == ErrTooManyMetrics {
+				return nil, err
+			}
+			log.Warn("read-file-error", "file", file, "error", err)
+		}
+	}
+	sort.SliceStable(metrics, func(i, j int) bool {
+		return metrics[i].Time < metrics[j].Time
+	})
+	return metrics, nil
+}
+
+func readFile(file string, names map[string]bool, start, end int64, add func(*models.Metric) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if !strings.HasSuffix(file, ".gz") {
+		return decodeMetrics(f, names, start, end, add)
+	}
+	// logutil gzips daily files by tar
+	gzReader, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gzReader.Close()
+	tarReader := tar.NewReader(gzReader)
+	for {
+		if _, err := tarReader.Next(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := decodeMetrics(tarReader, names, start, end, add); err != nil {
+			return err
+		}
+	}
+}
+
+func decodeMetrics(r io.Reader, names map[string]bool, start, end int64, add func(*models.Metric) error) error {
+	decoder := json.NewDecoder(r)
+	for {
+		m := new(models.Metric)
+		if err := decoder.Decode(m); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if !names[m.Name] || m.Time < start || m.Time > end {
+			continue
+		}
+		if err := add(m); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package backtest
+
+import (
+	"sort"
+
+	"github.com/baishancloud/mallard/corelib/models"
+)
+
+// tracker tracks problem events from opening to recovery
+type tracker struct {
+	opened   map[string]*Event
+	events   []*Event
+	lastTime int64
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		opened: make(map[string]*Event),
+	}
+}
+
+func (tr *tracker) update(evt *Event, status models.EventStatus, t int64) {
+	if t > tr.lastTime {
+		tr.lastTime = t
+	}
+	opened := tr.opened[evt.ID]
+	if status == models.EventProblem || status == models.EventFlapping {
+		if opened == nil {
+			evt.Start = t
+			evt.End = t
+			tr.opened[evt.ID] = evt
+			tr.events = append(tr.events, evt)
+			return
+		}
+		opened.End = t
+		opened.LeftValue = evt.LeftValue
+		return
+	}
+	if status == models.EventOk && opened != nil {
+		tr.recover(opened, t)
+	}
+}
+
+func (tr *tracker) recover(evt *Event, t int64) {
+	evt.End = t
+	evt.Recovered = true
+	delete(tr.opened, evt.ID)
+}
+
+func (tr *tracker) recoverUnseen(seen map[string]bool, t int64) {
+	for id, evt := range tr.opened {
+		if !seen[id] {
+			tr.recover(evt, t)
+		}
+	}
+}
+
+func (tr *tracker) finish() []*Event {
+	for _, evt := range tr.opened {
+		evt.End = tr.lastTime
+	}
+	for _, evt := range tr.events {
+		evt.Duration = evt.End - evt.Start
+	}
+	sort.SliceStable(tr.events, func(i, j int) bool {
+		return tr.events[i].Start < tr.events[j].Start
+	})
+	return tr.events
+}
@@ -0,0 +1,157 @@
+package baseline
+
+import (
+	"sync"
+	"time"
+
+	"github.com/baishancloud/mallard/componentlib/agent/judger"
+	"github.com/baishancloud/mallard/componentlib/agent/transfer"
+	"github.com/baishancloud/mallard/corelib/expvar"
+	"github.com/baishancloud/mallard/corelib/models"
+	"github.com/baishancloud/mallard/corelib/zaplog"
+	"github.com/baishancloud/mallard/extralib/configapi"
+)
+
+// DefaultMaxDays is default days to keep seasonal buckets
+const DefaultMaxDays = 7
+
+var (
+	log = zaplog.Zap("baseline")
+
+	units       = make(map[int]*Unit)
+	unitsAccept = make(map[string][]int)
+	unitsLock   sync.RWMutex
+
+	store        = NewStore(DefaultMaxDays)
+	storeFile    string
+	eventCurrent = judger.NewCurrent()
+
+	unitCount         = expvar.NewBase("baseline.unit")
+	seriesCount       = expvar.NewBase("baseline.series")
+	eventOKCount      = expvar.NewDiff("baseline.ok")
+	eventProblemCount = expvar.NewDiff("baseline.problem")
+)
+
+func init() {
+	expvar.Register(unitCount, seriesCount, eventOKCount, eventProblemCount)
+}
+
+// SetStore sets file to dump baseline store and max days of seasonal buckets,
+// it loads existing data from the file
+func SetStore(file string, maxDays int) {
+	if maxDays <= 0 {
+		maxDays = DefaultMaxDays
+	}
+	store = NewStore(maxDays)
+	storeFile = file
+	if file == "" {
+		return
+	}
+	if err := store.Load(file); err != nil {
+		log.Warn("load-store-error", "error", err, "file", file)
+		return
+	}
+	log.Info("load-store", "file", file, "series", store.Len(), "days", maxDays)
+}
+
+// SetStrategies sets baseline strategies, other strategies are ignored
+func SetStrategies(ss map[int]*models.Strategy) {
+	newUnits := make(map[int]*Unit)
+	accepts := make(map[string][]int)
+	for id, st := range ss {
+		if !st.IsBaseline() {
+			continue
+		}
+		unitsLock.RLock()
+		u := units[id]
+		unitsLock.RUnlock()
+		if u == nil || u.st.Func != st.Func || u.st.FieldTransform != st.FieldTransform ||
+			u.st.TagString != st.TagString || u.st.Operator != st.Operator || u.st.RightValue != st.RightValue {
+			var err error
+			if u, err = NewUnit(st, store.MaxDays()); err != nil {
+				log.Warn("new-unit-error", "error", err, "sid", id)
+				continue
+			}
+		}
+		newUnits[id] = u
+		accepts[st.Metric] = append(accepts[st.Metric], id)
+	}
+	unitsLock.Lock()
+	units = newUnits
+	unitsAccept = accepts
+	unitsLock.Unlock()
+	unitCount.Set(int64(len(newUnits)))
+}
+
+// Judge judges metrics with baseline strategies, and sends events to transfer
+func Judge(metrics []*models.Metric) {
+	events := judgeMetrics(metrics)
+	if len(events) > 0 {
+		transfer.Events(events)
+	}
+}
+
+func judgeMetrics(metrics []*models.Metric) []*models.Event {
+	var events []*models.Event
+	now := time.Now().Unix()
+	unitsLock.RLock()
+	defer unitsLock.RUnlock()
+	for _, metric := range metrics {
+		for _, id := range unitsAccept[metric.Name] {
+			u := units[id]
+			if u == nil || !u.Accept(metric) {
+				continue
+			}
+			event, err := u.Check(metric, store)
+			if err != nil {
+				log.Debug("check-error", "sid", id, "error", err)
+				continue
+			}
+			if event == nil {
+				continue
+			}
+			event.CreateTime = now
+			if !eventCurrent.ShouldAlarm(event) {
+				continue
+			}
+			if event.Status == models.EventProblem {
+				eventProblemCount.Incr(1)
+				log.Info("problem-event", "event", event)
+			} else {
+				eventOKCount.Incr(1)
+			}
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Sync reloads baseline strategies from config api, cleans and dumps store in interval
+func Sync(interval time.Duration) {
+	time.Sleep(time.Second * 5) // wait for configapi load data
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		SetStrategies(configapi.GetStrategies())
+		removed := store.Clean(time.Now().Unix())
+		seriesCount.Set(int64(store.Len()))
+		dumpStore()
+		log.Info("sync", "units", unitCount.Count(), "series", store.Len(), "removed", removed)
+		<-ticker.C
+	}
+}
+
+// Close dumps store to file
+func Close() {
+	dumpStore()
+}
+
+func dumpStore() {
+	if storeFile == "" {
+		return
+	}
+	if err := store.Dump(storeFile); err != nil {
+		log.Warn("dump-store-error", "error", err, "file", storeFile)
+	}
+}
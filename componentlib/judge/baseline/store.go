@@ -0,0 +1,173 @@
+package baseline
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+var (
+	// BucketSeconds is time range of one seasonal bucket
+	BucketSeconds int64 = 300
+)
+
+type bucket struct {
+	Sum   float64 `json:"s"`
+	Count int64   `json:"c"`
+}
+
+type series struct {
+	Buckets  map[int64]*bucket `json:"b,omitempty"`
+	EWMA     float64           `json:"e,omitempty"`
+	EWMAInit bool              `json:"ei,omitempty"`
+	Time     int64             `json:"t"`
+}
+
+// Store is compact baseline store of series,
+// it keeps average values in buckets for seasonal comparison and EWMA value for rolling baseline
+type Store struct {
+	Series map[string]*series `json:"series"`
+	maxAge int64
+	lock   sync.RWMutex
+}
+
+// NewStore creates baseline store, buckets older than maxDays are removed when cleaning
+func NewStore(maxDays int) *Store {
+	return &Store{
+		Series: make(map[string]*series),
+		maxAge: int64(maxDays) * 86400,
+	}
+}
+
+// MaxDays returns max days of buckets in store
+func (s *Store) MaxDays() int {
+	return int(s.maxAge / 86400)
+}
+
+func (s *Store) getSeries(key string, t int64) *series {
+	sr := s.Series[key]
+	if sr == nil {
+		sr = &series{
+			Buckets: make(map[int64]*bucket),
+		}
+		s.Series[key] = sr
+	}
+	if t > sr.Time {
+		sr.Time = t
+	}
+	return sr
+}
+
+// Record records value to bucket of time t
+func (s *Store) Record(key string, value float64, t int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sr := s.getSeries(key, t)
+	if sr.Buckets == nil {
+		sr.Buckets = make(map[int64]*bucket)
+	}
+	bt := t / BucketSeconds * BucketSeconds
+	b := sr.Buckets[bt]
+	if b == nil {
+		b = new(bucket)
+		sr.Buckets[bt] = b
+	}
+	b.Sum += value
+	b.Count++
+}
+
+// Season returns average value in the bucket of same time days ago
+func (s *Store) Season(key string, t int64, days int) (float64, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	sr := s.Series[key]
+	if sr == nil {
+		return 0, false
+	}
+	bt := (t - int64(days)*86400) / BucketSeconds * BucketSeconds
+	b := sr.Buckets[bt]
+	if b == nil || b.Count == 0 {
+		return 0, false
+	}
+	return b.Sum / float64(b.Count), true
+}
+
+// EWMA returns current EWMA value before updating with the value,
+// the first value only initializes EWMA, so it returns false
+func (s *Store) EWMA(key string, value float64, t int64, alpha float64) (float64, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sr := s.getSeries(key, t)
+	if !sr.EWMAInit {
+		sr.EWMA = value
+		sr.EWMAInit = true
+		return 0, false
+	}
+	prev := sr.EWMA
+	sr.EWMA = alpha*value + (1-alpha)*prev
+	return prev, true
+}
+
+// Clean removes expired buckets and series,
+// returns number of removed series
+func (s *Store) Clean(now int64) int {
+	if s.maxAge <= 0 {
+		return 0
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var count int
+	expire := now - s.maxAge - 86400 // keep one more day for window in the oldest day
+	for key, sr := range s.Series {
+		if sr.Time < expire {
+			delete(s.Series, key)
+			count++
+			continue
+		}
+		for bt := range sr.Buckets {
+			if bt < expire {
+				delete(sr.Buckets, bt)
+			}
+		}
+	}
+	return count
+}
+
+// Len returns number of series in store
+func (s *Store) Len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return len(s.Series)
+}
+
+// Dump dumps store to file
+func (s *Store) Dump(file string) error {
+	s.lock.RLock()
+	b, err := json.Marshal(s.Series)
+	s.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+	tmpFile := file + ".tmp"
+	if err = ioutil.WriteFile(tmpFile, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, file)
+}
+
+// Load loads store from file
+func (s *Store) Load(file string) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	data := make(map[string]*series)
+	if err = json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	s.Series = data
+	s.lock.Unlock()
+	return nil
+}
@@ -0,0 +1,135 @@
+package baseline
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+
+	"github.com/baishancloud/mallard/componentlib/agent/judger"
+	"github.com/baishancloud/mallard/corelib/models"
+)
+
+const (
+	funcSeason = "season"
+	funcEWMA   = "ewma"
+)
+
+var (
+	funcRegexp = regexp.MustCompile(`^(season|ewma)\(#(\d+),(\d+)\)$`)
+
+	// ErrUnknownBaselineFunc means strategy func is not season(#N,D) or ewma(#N,A)
+	ErrUnknownBaselineFunc = errors.New("unknown-baseline-func")
+	// ErrSeasonDaysOverflow means season days is larger than store max days
+	ErrSeasonDaysOverflow = errors.New("season-days-overflow")
+	// ErrEWMAAlphaInvalid means ewma alpha is not in 1-100 percent
+	ErrEWMAAlphaInvalid = errors.New("ewma-alpha-invalid")
+)
+
+// Unit is unit to check one baseline strategy.
+// season(#N,D) compares average of latest N values with the value at same time D days ago,
+// ewma(#N,A) compares average of latest N values with EWMA baseline, A is alpha percent.
+// The left value is deviation percent from baseline
+type Unit struct {
+	st        *models.Strategy
+	unit      *judger.StrategyUnit
+	compareFn judger.CompareFunc
+	fn        string
+	arg       int
+}
+
+// NewUnit creates baseline unit with strategy
+func NewUnit(st *models.Strategy, maxDays int) (*Unit, error) {
+	matches := funcRegexp.FindStringSubmatch(st.Func)
+	if len(matches) != 4 {
+		return nil, ErrUnknownBaselineFunc
+	}
+	arg, _ := strconv.Atoi(matches[3])
+	if matches[1] == funcSeason && (arg < 1 || arg > maxDays) {
+		return nil, ErrSeasonDaysOverflow
+	}
+	if matches[1] == funcEWMA && (arg < 1 || arg > 100) {
+		return nil, ErrEWMAAlphaInvalid
+	}
+	avgSt := *st
+	avgSt.Func = fmt.Sprintf("avg(#%s)", matches[2])
+	unit, err := judger.NewUnit(&avgSt)
+	if err != nil {
+		return nil, err
+	}
+	compareFn := judger.NewCompareFunc(st.Operator)
+	if compareFn == nil {
+		return nil, judger.ErrUnknownCompareFunc
+	}
+	return &Unit{
+		st:        st,
+		unit:      unit,
+		compareFn: compareFn,
+		fn:        matches[1],
+		arg:       arg,
+	}, nil
+}
+
+// ID returns strategy id
+func (u *Unit) ID() int {
+	return u.st.ID
+}
+
+// Accept checks metric is suited for this unit
+func (u *Unit) Accept(metric *models.Metric) bool {
+	return u.unit.Accept(metric)
+}
+
+// Check checks metric with baseline in store,
+// it returns nil event if baseline or enough values are not ready
+func (u *Unit) Check(metric *models.Metric, store *Store) (*models.Event, error) {
+	value, err := u.unit.Operator().Transform(metric)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%d_%s", u.st.ID, metric.Hash())
+	var (
+		baseline float64
+		ok       bool
+	)
+	if u.fn == funcSeason {
+		baseline, ok = store.Season(key, metric.Time, u.arg)
+		store.Record(key, value, metric.Time)
+	} else {
+		baseline, ok = store.EWMA(key, value, metric.Time, float64(u.arg)/100)
+	}
+	current, status, err := u.unit.Check(metric, "")
+	if err != nil {
+		return nil, err
+	}
+	if status == models.EventIgnore || !ok || baseline == 0 {
+		return nil, nil
+	}
+	deviation := (current - baseline) / math.Abs(baseline) * 100
+	status = models.EventOk
+	if u.compareFn(deviation, u.st.RightValue) {
+		status = models.EventProblem
+	}
+	fields := make(map[string]interface{}, len(metric.Fields)+3)
+	for k, v := range metric.Fields {
+		fields[k] = v
+	}
+	if _, ok := fields["value"]; !ok {
+		fields["value"] = metric.Value
+	}
+	fields["baseline"] = baseline
+	fields["current"] = current
+	return &models.Event{
+		ID:        fmt.Sprintf("s_%d_%s", u.st.ID, metric.Hash()),
+		Status:    status,
+		Time:      metric.Time,
+		Strategy:  u.st.ID,
+		LeftValue: deviation,
+		History:   u.unit.History(metric.Hash()),
+		Tags:      metric.Tags,
+		Cycle:     metric.Step,
+		Fields:    fields,
+		Endpoint:  metric.Endpoint,
+	}, nil
+}
@@ -0,0 +1,101 @@
+package baseline
+
+import (
+	"os"
+	"testing"
+
+	"github.com/baishancloud/mallard/corelib/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func testMetric(value float64, t int64) *models.Metric {
+	return &models.Metric{
+		Name:     "net.traffic",
+		Value:    value,
+		Time:     t,
+		Endpoint: "localhost",
+		Step:     60,
+		Tags:     map[string]string{"iface": "eth0"},
+	}
+}
+
+func TestUnit(t *testing.T) {
+	Convey("baseline", t, func() {
+		st := &models.Strategy{
+			ID:             1,
+			Metric:         "net.traffic",
+			FieldTransform: "select(value)",
+			Operator:       ">",
+			RightValue:     50,
+			TagString:      "iface=eth0",
+		}
+		store := NewStore(7)
+		var t0 int64 = 1500000000
+
+		Convey("baseline.parse", func() {
+			st.Func = "max(#3)"
+			_, err := NewUnit(st, 7)
+			So(err, ShouldEqual, ErrUnknownBaselineFunc)
+			st.Func = "season(#3,8)"
+			_, err = NewUnit(st, 7)
+			So(err, ShouldEqual, ErrSeasonDaysOverflow)
+			st.Func = "ewma(#3,0)"
+			_, err = NewUnit(st, 7)
+			So(err, ShouldEqual, ErrEWMAAlphaInvalid)
+			So(st.IsBaseline(), ShouldBeTrue)
+		})
+
+		Convey("baseline.season", func() {
+			st.Func = "season(#1,1)"
+			u, err := NewUnit(st, 7)
+			So(err, ShouldBeNil)
+			So(u.Accept(testMetric(100, t0)), ShouldBeTrue)
+
+			event, err := u.Check(testMetric(100, t0), store)
+			So(err, ShouldBeNil)
+			So(event, ShouldBeNil)
+
+			event, _ = u.Check(testMetric(120, t0+86400), store)
+			So(event.Status, ShouldEqual, models.EventOk)
+			So(event.LeftValue, ShouldEqual, 20)
+
+			event, _ = u.Check(testMetric(200, t0+86400+60), store)
+			So(event.Status, ShouldEqual, models.EventProblem)
+			So(event.LeftValue, ShouldEqual, 100)
+			So(event.Fields["baseline"], ShouldEqual, 100)
+			So(event.Fields["current"], ShouldEqual, 200)
+		})
+
+		Convey("baseline.ewma", func() {
+			st.Func = "ewma(#1,50)"
+			u, _ := NewUnit(st, 7)
+			event, _ := u.Check(testMetric(100, t0), store)
+			So(event, ShouldBeNil)
+			event, _ = u.Check(testMetric(100, t0+60), store)
+			So(event.Status, ShouldEqual, models.EventOk)
+			event, _ = u.Check(testMetric(300, t0+120), store)
+			So(event.Status, ShouldEqual, models.EventProblem)
+			So(event.LeftValue, ShouldEqual, 200)
+			event, _ = u.Check(testMetric(200, t0+180), store)
+			So(event.Fields["baseline"], ShouldEqual, 200)
+		})
+
+		Convey("baseline.store", func() {
+			store.Record("a", 10, t0)
+			store.Record("a", 20, t0+10)
+			v, ok := store.Season("a", t0+86400, 1)
+			So(ok, ShouldBeTrue)
+			So(v, ShouldEqual, 15)
+
+			file := "./tests_baseline.dump"
+			defer os.RemoveAll(file)
+			So(store.Dump(file), ShouldBeNil)
+			store2 := NewStore(7)
+			So(store2.Load(file), ShouldBeNil)
+			So(store2.Len(), ShouldEqual, 1)
+
+			So(store2.Clean(t0+86400*9), ShouldEqual, 1)
+			So(store2.Len(), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,31 @@
+package judgehandler
+
+import (
+	"net/http"
+
+	"github.com/baishancloud/mallard/componentlib/judge/backtest"
+	"github.com/baishancloud/mallard/componentlib/judge/judgestore"
+	"github.com/baishancloud/mallard/corelib/httputil"
+	"github.com/julienschmidt/httprouter"
+)
+
+// BacktestLimit limits time range and metrics count of backtest request,
+// so one request does not exhaust memory of judge service
+var BacktestLimit = backtest.Limit{
+	MaxRange:   86400 * 7,
+	MaxMetrics: 1000000,
+}
+
+func backtestRun(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	req := new(backtest.Request)
+	if err := httputil.LoadJSON(r, req); err != nil {
+		httputil.ResponseErrorJSON(rw, r, 400, err)
+		return
+	}
+	result, err := backtest.RunWithLimit(req, []string{judgestore.Dir()}, BacktestLimit)
+	if err != nil {
+		httputil.ResponseErrorJSON(rw, r, 400, err)
+		return
+	}
+	httputil.ResponseJSON(rw, result, false, false)
+}
@@ -30,6 +30,7 @@ func Create() http.Handler {
 	r.POST("/write", influxRecv)
 
 	r.GET("/api/events/memory", judgeEvents)
+	r.POST("/api/backtest", backtestRun)
 	//r.GET("/api/query", s.metricQuery)
 
 	r.NotFound = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
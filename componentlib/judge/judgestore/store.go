@@ -52,6 +52,11 @@ func SetDir(dir string) {
 	os.MkdirAll(dir, os.ModePerm)
 }
 
+// Dir returns directory to store
+func Dir() string {
+	return writingDir
+}
+
 // Close closes file manager, sync file handlers to disk and close
 func Close() {
 	atomic.StoreInt64(&writingStopFlag, 1)
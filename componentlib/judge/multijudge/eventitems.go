@@ -102,6 +102,13 @@ type (
 	}
 )
 
+// NewScoreGroup creates empty score group
+func NewScoreGroup() *ScoreGroup {
+	return &ScoreGroup{
+		Groups: make(map[string]*ScoreItems),
+	}
+}
+
 // Add adds item to group
 func (eg *ScoreGroup) Add(item *ScoreItem) {
 	eg.lock.RLock()
@@ -158,9 +165,7 @@ func setEventItem(item *ScoreItem) {
 	group := cachedEvents[item.MultiStrategyID]
 	cachedEventsLock.RUnlock()
 	if group == nil {
-		group = &ScoreGroup{
-			Groups: make(map[string]*ScoreItems),
-		}
+		group = NewScoreGroup()
 		cachedEventsLock.Lock()
 		cachedEvents[item.MultiStrategyID] = group
 		cachedEventsLock.Unlock()
@@ -262,8 +267,11 @@ func ScanForEvents(interval time.Duration) {
 		exprList, hash := configapi.CheckExpressionsCache(expHash)
 		if hash != expHash {
 			expHash = hash
-			SetExpressions(exprList)
-			log.Info("reload-expressions", "expr", len(exprList))
+			syntaxEvents := SetExpressions(exprList)
+			if len(syntaxEvents) > 0 {
+				transfer.Events(syntaxEvents)
+			}
+			log.Info("reload-expressions", "expr", len(exprList), "syntax", len(syntaxEvents))
 		}
 		go func() {
 			events := scanItems()
@@ -27,6 +27,9 @@ type ExprUnit struct {
 	id         int
 	compareFn  judger.CompareFunc
 	rightValue float64
+	group      *ScoreGroup
+	syntaxErrs []error
+	expr       *models.Expression
 
 	lastTouchTime int64
 }
@@ -116,6 +119,9 @@ func parseScoreOperator(opt string) (string, float64, error) {
 
 // NewExprUnit creates multi-strategy unit
 func NewExprUnit(id int, exp *models.Expression) (*ExprUnit, error) {
+	if err := exp.CheckSchedule(); err != nil {
+		return nil, err
+	}
 	rules := make([]string, 0, 2)
 	if err := json.Unmarshal([]byte(exp.Expression), &rules); err != nil {
 		return nil, err
@@ -125,6 +131,7 @@ func NewExprUnit(id int, exp *models.Expression) (*ExprUnit, error) {
 		id:         id,
 		compareFn:  judger.NewCompareFunc(exp.Operator),
 		rightValue: exp.RightValue,
+		expr:       exp,
 	}
 	strategies, err := parseRulesToStrategy(rules)
 	if err != nil {
@@ -134,6 +141,7 @@ func NewExprUnit(id int, exp *models.Expression) (*ExprUnit, error) {
 		unit, err := judger.NewUnit(st)
 		if err != nil {
 			log.Warn("new-unit-error", "error", err, "st", st)
+			mu.syntaxErrs = append(mu.syntaxErrs, err)
 			continue
 		}
 		key := st.Metric + "-" + st.TagString
@@ -144,6 +152,17 @@ func NewExprUnit(id int, exp *models.Expression) (*ExprUnit, error) {
 	return mu, nil
 }
 
+// NewExprUnitWithGroup creates multi-strategy unit that writes score items to the group,
+// instead of global cached events, it's used to run expression in isolation such as backtest
+func NewExprUnitWithGroup(id int, exp *models.Expression, group *ScoreGroup) (*ExprUnit, error) {
+	mu, err := NewExprUnit(id, exp)
+	if err != nil {
+		return nil, err
+	}
+	mu.group = group
+	return mu, nil
+}
+
 // Accept checks metric to accepting
 // returns sub-strategy key and accepted status
 func (mu *ExprUnit) Accept(metric *models.Metric) (string, bool) {
@@ -190,7 +209,7 @@ func (mu *ExprUnit) Check(key string, metric *models.Metric) {
 	}
 	metricHash := fmt.Sprintf("%s~%s", groupHash, metric.Name)
 	metricValueHash := metric.Name + "~" + metric.Hash()
-	if status == models.EventProblem {
+	if status == models.EventProblem && mu.expr.IsInTime(metric.Time) {
 		item := &ScoreItem{
 			Metric:          metric,
 			MultiStrategyID: mu.id,
@@ -201,15 +220,28 @@ func (mu *ExprUnit) Check(key string, metric *models.Metric) {
 			Score:           unit.Score(),
 			strategy:        unit.GetStrategy(),
 		}
+		if mu.group != nil {
+			mu.group.Add(item)
+			return
+		}
 		setEventItem(item)
 		log.Debug("set", "expID", mu.id, "mhash", metricHash, "vhash", metricValueHash, "left", leftValue)
 	} else {
+		if mu.group != nil {
+			mu.group.Remove(groupHash, metricValueHash)
+			return
+		}
 		if removeEventItem(mu.id, groupHash, metricValueHash) {
 			log.Debug("remove", "mhash", metricHash, "vhash", metricValueHash, "left", leftValue)
 		}
 	}
 }
 
+// SyntaxErrors returns errors of wrong syntax rules in the unit
+func (mu *ExprUnit) SyntaxErrors() []error {
+	return mu.syntaxErrs
+}
+
 // CheckScore checks score with compare function
 func (mu *ExprUnit) CheckScore(leftValue float64) bool {
 	if mu.compareFn == nil {
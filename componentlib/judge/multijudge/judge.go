@@ -1,6 +1,7 @@
 package multijudge
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -11,21 +12,33 @@ var (
 	units        = make(map[int]*ExprUnit)
 	unitsLock    sync.RWMutex
 	unitsAccepts map[string][]int
+
+	syntaxReported = make(map[int]bool)
 )
 
-// SetExpressions sets expression to generate unit
-func SetExpressions(exprList map[int]*models.Expression) {
+// SetExpressions sets expression to generate unit,
+// it returns syntax events for wrong expressions that are not reported before
+func SetExpressions(exprList map[int]*models.Expression) []*models.Event {
 	nowUnix := time.Now().Unix()
 	unitsLock.Lock()
 
+	var events []*models.Event
 	for id, expr := range exprList {
 		unit := units[id]
 		if unit == nil {
 			unit, err := NewExprUnit(id, expr)
 			if err != nil {
 				log.Warn("new-exprunit-error", "id", id, "error", err)
+				if !syntaxReported[id] {
+					events = append(events, genSyntaxEvent(id, []error{err}, nowUnix))
+					syntaxReported[id] = true
+				}
 				continue
 			}
+			if errs := unit.SyntaxErrors(); len(errs) > 0 {
+				events = append(events, genSyntaxEvent(id, errs, nowUnix))
+			}
+			delete(syntaxReported, id)
 			units[id] = unit
 		}
 		if unit != nil {
@@ -47,6 +60,24 @@ func SetExpressions(exprList map[int]*models.Expression) {
 
 	unitsAccepts = accepts
 	unitsLock.Unlock()
+	return events
+}
+
+func genSyntaxEvent(id int, errs []error, now int64) *models.Event {
+	errStrs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		errStrs = append(errStrs, err.Error())
+	}
+	return &models.Event{
+		ID:         fmt.Sprintf("e_%d_syntax", id),
+		Status:     models.EventSyntax,
+		Time:       now,
+		Expression: id,
+		Fields: map[string]interface{}{
+			"error": errStrs,
+		},
+		CreateTime: now,
+	}
 }
 
 // GetUnit gets unit by id
@@ -19,8 +19,16 @@ var (
 )
 
 func getURL(url string, timeout time.Duration) (*http.Response, time.Duration, error) {
+	return requestURL("GET", url, timeout)
+}
+
+func postURL(url string, timeout time.Duration) (*http.Response, time.Duration, error) {
+	return requestURL("POST", url, timeout)
+}
+
+func requestURL(method string, url string, timeout time.Duration) (*http.Response, time.Duration, error) {
 	t := time.Now()
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -3,6 +3,7 @@ package puller
 import (
 	"encoding/json"
 	"io/ioutil"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -19,6 +20,7 @@ var (
 	urlsLock     sync.RWMutex
 	stopFlag     int64
 	metricsQueue container.LimitedQueue
+	leaseTimeout int
 
 	log = zaplog.Zap("puller")
 )
@@ -36,6 +38,15 @@ func init() {
 	expvar.Register(queuePushCount, queuePushFailCount)
 }
 
+// SetAckLease enables lease mode with timeout in seconds,
+// popped metrics are acked to transfer only after they are pushed to queue,
+// so transfer pushes them back when store fails to handle them.
+// It should be called before Prepare
+func SetAckLease(timeout int) {
+	leaseTimeout = timeout
+	log.Info("set-ack-lease", "timeout", timeout)
+}
+
 // Prepare sets options to pullerxs
 func Prepare(queue container.LimitedQueue, urlList map[string]string, concurrency int) {
 	metricsQueue = queue
@@ -44,19 +55,27 @@ func Prepare(queue container.LimitedQueue, urlList map[string]string, concurrenc
 
 func setURLs(urlList map[string]string, concurrency int) {
 	realURLs := make(map[string]string, len(urlList))
+	ackURLs := make(map[string]string, len(urlList))
 	for key, url := range urlList {
-		realURLs[key] = strings.TrimSuffix(url, "/") + "/api/metric_pop"
+		url = strings.TrimSuffix(url, "/")
+		if leaseTimeout > 0 {
+			realURLs[key] = url + "/api/metric/pop?lease=" + strconv.Itoa(leaseTimeout)
+			ackURLs[key] = url + "/api/metric/ack"
+			continue
+		}
+		realURLs[key] = url + "/api/metric_pop"
 	}
 	now := time.Now().Unix()
 	urlsLock.Lock()
 	for key, url := range realURLs {
 		unit := urlsUnit[key]
 		if unit == nil {
-			unit = NewUnit(key, url, concurrency)
+			unit = NewUnit(key, url, ackURLs[key], concurrency)
 			urlsUnit[key] = unit
 			log.Info("add-url-unit", "key", key)
 		}
 		unit.SetURL(url)
+		unit.SetAckURL(ackURLs[key])
 		unit.createTime = now
 		unit.Start(concurrency)
 	}
@@ -1,6 +1,7 @@
 package puller
 
 import (
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"strconv"
@@ -21,10 +22,17 @@ const (
 	NextWaitDuration = time.Second * 5
 )
 
+var (
+	// ErrQueuePushFail means pulled metrics are failed to push to queue
+	ErrQueuePushFail = errors.New("queue-push-fail")
+)
+
 // Unit is pulling unit for one url
 type Unit struct {
 	key           string
 	url           string
+	ackURL        string
+	ackLock       sync.RWMutex
 	createTime    int64
 	isErrorStatus int
 
@@ -37,20 +45,26 @@ type Unit struct {
 	okCount        *expvar.DiffMeter
 	metricCounter  *expvar.DiffMeter
 	latencyCounter *expvar.AvgMeter
+	ackCount       *expvar.DiffMeter
+	ackFailCount   *expvar.DiffMeter
 }
 
-// NewUnit creates new unit with keyname and url
+// NewUnit creates new unit with keyname, url and ack url,
+// if ack url is not empty, leased metrics are acked after pushing to queue.
 // it starts pulling auto
-func NewUnit(key, url string, concurrency int) *Unit {
+func NewUnit(key, url, ackURL string, concurrency int) *Unit {
 	unit := &Unit{
 		key:            key,
 		url:            url,
+		ackURL:         ackURL,
 		failCount:      expvar.NewDiff("fail"),
 		reqCount:       expvar.NewDiff("req"),
 		zeroCounter:    expvar.NewDiff("zero"),
 		okCount:        expvar.NewDiff("ok"),
 		metricCounter:  expvar.NewDiff("metric"),
 		latencyCounter: expvar.NewAverage("latency", 50),
+		ackCount:       expvar.NewDiff("ack"),
+		ackFailCount:   expvar.NewDiff("ack_fail"),
 	}
 	unit.Start(concurrency)
 	return unit
@@ -94,11 +108,17 @@ func (u *Unit) loop() {
 			continue
 		}
 		u.isErrorStatus = 0
-		if err := u.parsePullResponse(resp); err != nil {
+		err = u.parsePullResponse(resp)
+		if err != nil {
 			u.failCount.Incr(1)
 		} else {
 			u.okCount.Incr(1)
 		}
+		// data failed to decode is acked too, it never succeeds after redelivering,
+		// only data failed to push to queue is left to redeliver
+		if leaseID := resp.Header.Get("Lease-ID"); leaseID != "" && err != ErrQueuePushFail {
+			u.ack(leaseID)
+		}
 		resp.Body.Close()
 		du := utils.DurationMS(duration)
 		log.Debug("req-url-ok", "key", u.key, "ms", du)
@@ -132,6 +152,7 @@ func (u *Unit) parseResponseMetrics(resp *http.Response) error {
 		if !metricsQueue.PushBatch(values) {
 			queuePushFailCount.Incr(int64(len(metrics)))
 			log.Warn("push-queue-fail", "datalen", len(values))
+			return ErrQueuePushFail
 		}
 	}
 	return nil
@@ -156,6 +177,8 @@ func (u *Unit) parseResponsePacket(resp *http.Response) error {
 			}
 			if !metricsQueue.PushBatch(values) {
 				queuePushFailCount.Incr(int64(len(metrics)))
+				log.Warn("push-queue-fail", "datalen", len(values))
+				return ErrQueuePushFail
 			}
 		}
 	}
@@ -166,6 +189,35 @@ func (u *Unit) parseResponsePacket(resp *http.Response) error {
 	return nil
 }
 
+func (u *Unit) ack(leaseID string) {
+	u.ackLock.RLock()
+	ackURL := u.ackURL
+	u.ackLock.RUnlock()
+	if ackURL == "" {
+		return
+	}
+	resp, _, err := postURL(ackURL+"?lease="+leaseID, time.Second*10)
+	if err != nil {
+		log.Warn("ack-error", "key", u.key, "lease", leaseID, "error", err)
+		u.ackFailCount.Incr(1)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		log.Warn("ack-bad-status", "key", u.key, "lease", leaseID, "status", resp.StatusCode)
+		u.ackFailCount.Incr(1)
+		return
+	}
+	u.ackCount.Incr(1)
+}
+
+// SetAckURL sets ack url to the unit
+func (u *Unit) SetAckURL(url string) {
+	u.ackLock.Lock()
+	u.ackURL = url
+	u.ackLock.Unlock()
+}
+
 // SetURL sets url to the unit
 func (u *Unit) SetURL(url string) {
 	if u.url == url {
@@ -198,6 +250,7 @@ func (u *Unit) Stop() {
 }
 
 func (u *Unit) counters() map[string]interface{} {
-	values := []interface{}{u.failCount, u.reqCount, u.zeroCounter, u.okCount, u.metricCounter, u.latencyCounter}
+	values := []interface{}{u.failCount, u.reqCount, u.zeroCounter, u.okCount, u.metricCounter, u.latencyCounter,
+		u.ackCount, u.ackFailCount}
 	return expvar.ExposeFactory(values, false)
 }
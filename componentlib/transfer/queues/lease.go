@@ -0,0 +1,206 @@
+package queues
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const leaseFileExt = ".lease"
+
+var (
+	// ErrLeaseNotFound means lease id is not found, it's acked or expired
+	ErrLeaseNotFound = errors.New("lease-not-found")
+	// MaxLeaseDeliveries is max expired leases count of one packet, the packet is dropped when reaching it,
+	// so data never acked does not circulate forever
+	MaxLeaseDeliveries = 5
+
+	leaseSeq int64
+)
+
+type lease struct {
+	packets  Packets
+	expireAt time.Time
+}
+
+type leaseMap struct {
+	sync.Mutex
+	leases map[string]*lease
+	dir    string // saves leases to files if it's set, for queues with write-ahead-log
+}
+
+func (lm *leaseMap) file(id string) string {
+	return filepath.Join(lm.dir, id+leaseFileExt)
+}
+
+// save writes leased packets to file, so they are not lost if crashing before ack
+func (lm *leaseMap) save(id string, packets Packets) error {
+	if lm.dir == "" {
+		return nil
+	}
+	b, err := json.Marshal(packets)
+	if err != nil {
+		return err
+	}
+	tmpFile := lm.file(id) + ".tmp"
+	if err = ioutil.WriteFile(tmpFile, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, lm.file(id))
+}
+
+func (lm *leaseMap) remove(id string) {
+	if lm.dir == "" {
+		return
+	}
+	if err := os.Remove(lm.file(id)); err != nil && !os.IsNotExist(err) {
+		log.Warn("lease-remove-error", "id", id, "error", err)
+	}
+}
+
+func newLeaseID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatInt(atomic.AddInt64(&leaseSeq, 1), 36)
+}
+
+// PopLease pops items as Pop, but keeps them in lease until acked or expired,
+// expired lease items are pushed back to queue
+func (q *Queue) PopLease(size int, timeout time.Duration) (string, Packets, error) {
+	packets, err := q.Pop(size)
+	if err != nil || len(packets) == 0 {
+		return "", packets, err
+	}
+	id := newLeaseID()
+	if err = q.leases.save(id, packets); err != nil {
+		// push back, the packets are not safe to lease without file
+		for _, p := range packets {
+			q.Push(p)
+		}
+		return "", nil, err
+	}
+	q.leases.Lock()
+	q.leases.leases[id] = &lease{
+		packets:  packets,
+		expireAt: time.Now().Add(timeout),
+	}
+	q.leases.Unlock()
+	return id, packets, nil
+}
+
+// Ack acknowledges lease by id, returns the number of leased packets
+func (q *Queue) Ack(id string) (int, error) {
+	q.leases.Lock()
+	defer q.leases.Unlock()
+	l := q.leases.leases[id]
+	if l == nil {
+		return 0, ErrLeaseNotFound
+	}
+	delete(q.leases.leases, id)
+	q.leases.remove(id)
+	return len(l.packets), nil
+}
+
+// LeaseLen returns number of leases waiting for acknowledgement
+func (q *Queue) LeaseLen() int {
+	q.leases.Lock()
+	defer q.leases.Unlock()
+	return len(q.leases.leases)
+}
+
+// ExpireLeases pushes expired leases back to queue, returns the number of packets pushed back,
+// packets expired for MaxLeaseDeliveries times are dropped
+func (q *Queue) ExpireLeases() int {
+	return q.requeueLeases(time.Now())
+}
+
+// ReleaseLeases pushes all leases back to queue,
+// use it before dumping queue when stopping
+func (q *Queue) ReleaseLeases() int {
+	return q.requeueLeases(time.Time{})
+}
+
+func (q *Queue) requeueLeases(before time.Time) int {
+	expired := make(map[string]Packets)
+	q.leases.Lock()
+	for id, l := range q.leases.leases {
+		if before.IsZero() || l.expireAt.Before(before) {
+			expired[id] = l.packets
+			delete(q.leases.leases, id)
+		}
+	}
+	q.leases.Unlock()
+	var count int
+	for id, packets := range expired {
+		var dropped int
+		for _, p := range packets {
+			if !before.IsZero() {
+				p.Deliveries++
+				if MaxLeaseDeliveries > 0 && p.Deliveries >= MaxLeaseDeliveries {
+					dropped++
+					continue
+				}
+			}
+			q.Push(p)
+			count++
+		}
+		if dropped > 0 {
+			log.Warn("lease-drop", "id", id, "packets", dropped)
+		}
+		q.leases.remove(id)
+	}
+	return count
+}
+
+// recoverLeases pushes leases saved in files back to queue,
+// they are not acked before last stopping or crashing
+func (q *Queue) recoverLeases() (int, error) {
+	if err := os.MkdirAll(q.leases.dir, os.ModePerm); err != nil {
+		return 0, err
+	}
+	infos, err := ioutil.ReadDir(q.leases.dir)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || filepath.Ext(name) != leaseFileExt {
+			continue
+		}
+		id := strings.TrimSuffix(name, leaseFileExt)
+		b, err := ioutil.ReadFile(q.leases.file(id))
+		if err != nil {
+			return count, err
+		}
+		var packets Packets
+		if err = json.Unmarshal(b, &packets); err != nil {
+			log.Warn("lease-broken-file", "id", id, "error", err)
+		}
+		for _, p := range packets {
+			if _, ok := q.Push(p); !ok {
+				log.Warn("lease-recover-push-fail", "id", id)
+				continue
+			}
+			count++
+		}
+		q.leases.remove(id)
+	}
+	return count, nil
+}
+
+// ScanLeases checks expired leases in loop
+func (q *Queue) ScanLeases(interval time.Duration, fn func(count int)) {
+	for {
+		time.Sleep(interval)
+		count := q.ExpireLeases()
+		if count > 0 && fn != nil {
+			fn(count)
+		}
+	}
+}
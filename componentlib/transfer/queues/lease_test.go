@@ -0,0 +1,112 @@
+package queues
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLease(t *testing.T) {
+	Convey("lease", t, func() {
+		queue := NewQueue(10, "")
+		for i := 0; i < 5; i++ {
+			queue.Push(testPack)
+		}
+
+		Convey("lease.ack", func() {
+			id, packs, err := queue.PopLease(3, time.Minute)
+			So(err, ShouldBeNil)
+			So(id, ShouldNotBeEmpty)
+			So(packs, ShouldHaveLength, 3)
+			So(queue.Len(), ShouldEqual, 2)
+			So(queue.LeaseLen(), ShouldEqual, 1)
+
+			count, err := queue.Ack(id)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 3)
+			So(queue.LeaseLen(), ShouldEqual, 0)
+
+			_, err = queue.Ack(id)
+			So(err, ShouldEqual, ErrLeaseNotFound)
+		})
+
+		Convey("lease.expire", func() {
+			id, _, _ := queue.PopLease(3, time.Millisecond*50)
+			So(queue.ExpireLeases(), ShouldEqual, 0)
+			time.Sleep(time.Millisecond * 60)
+			So(queue.ExpireLeases(), ShouldEqual, 3)
+			So(queue.Len(), ShouldEqual, 5)
+
+			_, err := queue.Ack(id)
+			So(err, ShouldEqual, ErrLeaseNotFound)
+		})
+
+		Convey("lease.expire.drop", func() {
+			defer func(max int) { MaxLeaseDeliveries = max }(MaxLeaseDeliveries)
+			MaxLeaseDeliveries = 2
+			queue.PopLease(5, time.Millisecond)
+			time.Sleep(time.Millisecond * 5)
+			So(queue.ExpireLeases(), ShouldEqual, 5)
+			So(queue.Len(), ShouldEqual, 5)
+
+			queue.PopLease(5, time.Millisecond)
+			time.Sleep(time.Millisecond * 5)
+			So(queue.ExpireLeases(), ShouldEqual, 0)
+			So(queue.Len(), ShouldEqual, 0)
+			So(queue.LeaseLen(), ShouldEqual, 0)
+		})
+
+		Convey("lease.release", func() {
+			queue.PopLease(2, time.Hour)
+			queue.PopLease(2, time.Hour)
+			So(queue.Len(), ShouldEqual, 1)
+			So(queue.ReleaseLeases(), ShouldEqual, 4)
+			So(queue.Len(), ShouldEqual, 5)
+			So(queue.LeaseLen(), ShouldEqual, 0)
+		})
+
+		Convey("lease.empty", func() {
+			queue.Pop(10)
+			id, packs, err := queue.PopLease(3, time.Minute)
+			So(err, ShouldBeNil)
+			So(id, ShouldBeEmpty)
+			So(packs, ShouldHaveLength, 0)
+		})
+	})
+}
+
+func TestLeaseWAL(t *testing.T) {
+	Convey("lease.wal", t, func() {
+		dir := "./tests_lease_wal"
+		os.RemoveAll(dir)
+		defer os.RemoveAll(dir)
+
+		queue, err := NewWALQueue(10, dir, WALOption{SyncPolicy: WALSyncAlways})
+		So(err, ShouldBeNil)
+		for i := 0; i < 5; i++ {
+			queue.Push(testPack)
+		}
+		id, _, _ := queue.PopLease(2, time.Minute)
+		queue.PopLease(2, time.Minute)
+		files, _ := filepath.Glob(filepath.Join(dir, "leases", "*"+leaseFileExt))
+		So(files, ShouldHaveLength, 2)
+
+		count, err := queue.Ack(id)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+		files, _ = filepath.Glob(filepath.Join(dir, "leases", "*"+leaseFileExt))
+		So(files, ShouldHaveLength, 1)
+
+		// crash without releasing leases, the unacked lease is pushed back when opening
+		queue.Close()
+		queue, err = NewWALQueue(10, dir, WALOption{SyncPolicy: WALSyncAlways})
+		So(err, ShouldBeNil)
+		So(queue.Len(), ShouldEqual, 3)
+		files, _ = filepath.Glob(filepath.Join(dir, "leases", "*"+leaseFileExt))
+		So(files, ShouldHaveLength, 0)
+		So(queue.Close(), ShouldBeNil)
+	})
+}
@@ -19,6 +19,8 @@ type (
 		Data []byte `json:"data,omitempty"`
 		Type int    `json:"type,omitempty"`
 		Len  int    `json:"len,omitempty"`
+		// Deliveries is count of expired leases of the packet, it's not kept in write-ahead-log
+		Deliveries int `json:"deliveries,omitempty"`
 	}
 	// Packets is list of several packet
 	Packets []Packet
@@ -4,12 +4,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/baishancloud/mallard/corelib/container"
+	"github.com/baishancloud/mallard/corelib/zaplog"
+)
+
+var (
+	log = zaplog.Zap("queues")
 )
 
 /*
@@ -30,6 +36,7 @@ type Queue struct {
 	queue     container.LimitedQueue
 	dumpDir   string
 	queueSize int
+	leases    leaseMap
 }
 
 // Push pushes raw pack to queue
@@ -53,7 +60,45 @@ func NewQueue(size int, dumpDir string) *Queue {
 		queue:     container.NewLimitedList(size),
 		queueSize: size,
 		dumpDir:   dumpDir,
+		leases: leaseMap{
+			leases: make(map[string]*lease),
+		},
+	}
+}
+
+// NewWALQueue creates new queue with size and write-ahead-log in dir,
+// it does not dump values to files, values are saved in log segments,
+// leases are saved in dir too, unacked leases are pushed back when opening
+func NewWALQueue(size int, dir string, opt WALOption) (*Queue, error) {
+	wal, err := OpenWAL(dir, size, opt)
+	if err != nil {
+		return nil, err
+	}
+	q := &Queue{
+		queue:     wal,
+		queueSize: size,
+		leases: leaseMap{
+			leases: make(map[string]*lease),
+			dir:    filepath.Join(dir, "leases"),
+		},
+	}
+	count, err := q.recoverLeases()
+	if err != nil {
+		wal.Close()
+		return nil, err
+	}
+	if count > 0 {
+		log.Info("lease-recover", "dir", dir, "count", count)
+	}
+	return q, nil
+}
+
+// Close closes queue backend if it needs, such as write-ahead-log
+func (q *Queue) Close() error {
+	if closer, ok := q.queue.(io.Closer); ok {
+		return closer.Close()
 	}
+	return nil
 }
 
 // Pop pop items from item and encode to bytes with json and gzip
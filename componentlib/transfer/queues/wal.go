@@ -0,0 +1,541 @@
+package queues
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baishancloud/mallard/corelib/container"
+)
+
+const (
+	// WALSyncAlways means fsync segment after each pushing and save checkpoint after each popping
+	WALSyncAlways = "always"
+	// WALSyncInterval means fsync segment and save checkpoint in time interval
+	WALSyncInterval = "interval"
+	// WALSyncNone means never fsync segment, leave flushing to os, checkpoint is saved in time interval
+	WALSyncNone = "none"
+
+	walSegmentExt      = ".wal"
+	walCheckpointFile  = "checkpoint"
+	walRecordHeaderLen = 8
+)
+
+var (
+	// ErrWALClosed means wal is closed
+	ErrWALClosed = errors.New("wal-closed")
+	// ErrWALCorrupted means wal record is broken
+	ErrWALCorrupted = errors.New("wal-corrupted")
+
+	_ container.LimitedQueue = (*WAL)(nil)
+)
+
+// WALOption is option of write-ahead-log
+type WALOption struct {
+	SegmentSize  int64
+	SyncPolicy   string
+	SyncInterval time.Duration
+}
+
+type walCheckpoint struct {
+	Segment int64 `json:"segment"`
+	Offset  int64 `json:"offset"`
+}
+
+// WAL is append-only segmented write-ahead-log for packets,
+// it implements container.LimitedQueue, so it can be the backend of Queue
+type WAL struct {
+	lock    sync.Mutex
+	dir     string
+	opt     WALOption
+	maxSize int
+	length  int
+	closed  bool
+	dirty   bool
+
+	segments []int64
+
+	writer      *os.File
+	writeSeg    int64
+	writeOffset int64
+
+	reader     *os.File
+	readerBuf  *bufio.Reader
+	readSeg    int64
+	readOffset int64
+
+	stopCh chan struct{}
+}
+
+// OpenWAL opens wal in dir with max size, it recovers unconsumed records from segments
+func OpenWAL(dir string, maxSize int, opt WALOption) (*WAL, error) {
+	if opt.SegmentSize <= 0 {
+		opt.SegmentSize = 64 * 1024 * 1024
+	}
+	if opt.SyncInterval <= 0 {
+		opt.SyncInterval = time.Second
+	}
+	if opt.SyncPolicy == "" {
+		opt.SyncPolicy = WALSyncInterval
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	w := &WAL{
+		dir:     dir,
+		opt:     opt,
+		maxSize: maxSize,
+		stopCh:  make(chan struct{}),
+	}
+	if err := w.recover(); err != nil {
+		return nil, err
+	}
+	if opt.SyncPolicy != WALSyncAlways {
+		go w.syncLoop()
+	}
+	return w, nil
+}
+
+func (w *WAL) segmentFile(seg int64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%016d%s", seg, walSegmentExt))
+}
+
+func (w *WAL) listSegments() ([]int64, error) {
+	infos, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []int64
+	for _, info := range infos {
+		if info.IsDir() || filepath.Ext(info.Name()) != walSegmentExt {
+			continue
+		}
+		seg, err := strconv.ParseInt(strings.TrimSuffix(info.Name(), walSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i] < segments[j]
+	})
+	return segments, nil
+}
+
+func (w *WAL) readCheckpoint() walCheckpoint {
+	var cp walCheckpoint
+	b, err := ioutil.ReadFile(filepath.Join(w.dir, walCheckpointFile))
+	if err != nil {
+		return cp
+	}
+	if err = json.Unmarshal(b, &cp); err != nil {
+		log.Warn("wal-checkpoint-error", "dir", w.dir, "error", err)
+	}
+	return cp
+}
+
+func (w *WAL) saveCheckpoint() error {
+	b, _ := json.Marshal(walCheckpoint{
+		Segment: w.readSeg,
+		Offset:  w.readOffset,
+	})
+	tmpFile := filepath.Join(w.dir, walCheckpointFile+".tmp")
+	if err := ioutil.WriteFile(tmpFile, b, 0644); err != nil {
+		return err
+	}
+	w.dirty = false
+	return os.Rename(tmpFile, filepath.Join(w.dir, walCheckpointFile))
+}
+
+// recover loads checkpoint, removes consumed segments, truncates broken tail records
+// and counts unconsumed records
+func (w *WAL) recover() error {
+	segments, err := w.listSegments()
+	if err != nil {
+		return err
+	}
+	cp := w.readCheckpoint()
+	var kept []int64
+	for _, seg := range segments {
+		if seg < cp.Segment {
+			os.Remove(w.segmentFile(seg))
+			continue
+		}
+		kept = append(kept, seg)
+	}
+	if len(kept) == 0 {
+		kept = []int64{cp.Segment + 1}
+	}
+	if kept[0] != cp.Segment {
+		cp = walCheckpoint{Segment: kept[0]}
+	}
+	w.segments = kept
+	w.readSeg, w.readOffset = cp.Segment, cp.Offset
+
+	for i, seg := range kept {
+		offset := int64(0)
+		if seg == cp.Segment {
+			offset = cp.Offset
+		}
+		count, validEnd, err := scanSegment(w.segmentFile(seg), offset)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		w.length += count
+		if i == len(kept)-1 {
+			w.writeSeg = seg
+			w.writeOffset = validEnd
+		}
+	}
+	if err = w.openWriter(); err != nil {
+		return err
+	}
+	// drop broken tail records of last segment
+	if err = w.writer.Truncate(w.writeOffset); err != nil {
+		return err
+	}
+	if w.readSeg == w.writeSeg && w.readOffset > w.writeOffset {
+		w.readOffset = w.writeOffset
+	}
+	if w.length > 0 {
+		log.Info("wal-recover", "dir", w.dir, "length", w.length, "segments", len(w.segments))
+	}
+	return w.saveCheckpoint()
+}
+
+// scanSegment counts valid records from offset and returns the end offset of valid records
+func scanSegment(file string, offset int64) (int, int64, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return 0, offset, err
+	}
+	defer fh.Close()
+	if _, err = fh.Seek(offset, io.SeekStart); err != nil {
+		return 0, offset, err
+	}
+	var (
+		count int
+		rd    = bufio.NewReader(fh)
+	)
+	for {
+		_, n, err := readRecord(rd)
+		if err != nil {
+			if err != io.EOF {
+				log.Warn("wal-broken-record", "file", file, "offset", offset, "error", err)
+			}
+			return count, offset, nil
+		}
+		offset += n
+		count++
+	}
+}
+
+func (w *WAL) openWriter() error {
+	fh, err := os.OpenFile(w.segmentFile(w.writeSeg), os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = fh.Seek(w.writeOffset, io.SeekStart); err != nil {
+		fh.Close()
+		return err
+	}
+	w.writer = fh
+	return nil
+}
+
+func (w *WAL) rotate() error {
+	if err := w.writer.Sync(); err != nil {
+		return err
+	}
+	w.writer.Close()
+	w.writeSeg++
+	w.writeOffset = 0
+	w.segments = append(w.segments, w.writeSeg)
+	return w.openWriter()
+}
+
+func encodeRecord(p Packet) []byte {
+	payload := make([]byte, binary.MaxVarintLen64*2+len(p.Data))
+	n := binary.PutUvarint(payload, uint64(p.Type))
+	n += binary.PutUvarint(payload[n:], uint64(p.Len))
+	n += copy(payload[n:], p.Data)
+	payload = payload[:n]
+
+	record := make([]byte, walRecordHeaderLen+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[walRecordHeaderLen:], payload)
+	return record
+}
+
+func readRecord(rd *bufio.Reader) (Packet, int64, error) {
+	header := make([]byte, walRecordHeaderLen)
+	if _, err := io.ReadFull(rd, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Packet{}, 0, ErrWALCorrupted
+		}
+		return Packet{}, 0, err
+	}
+	size := binary.BigEndian.Uint32(header[0:4])
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(rd, payload); err != nil {
+		return Packet{}, 0, ErrWALCorrupted
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return Packet{}, 0, ErrWALCorrupted
+	}
+	pType, n1 := binary.Uvarint(payload)
+	if n1 <= 0 {
+		return Packet{}, 0, ErrWALCorrupted
+	}
+	pLen, n2 := binary.Uvarint(payload[n1:])
+	if n2 <= 0 {
+		return Packet{}, 0, ErrWALCorrupted
+	}
+	return Packet{
+		Type: int(pType),
+		Len:  int(pLen),
+		Data: payload[n1+n2:],
+	}, int64(walRecordHeaderLen) + int64(size), nil
+}
+
+func (w *WAL) append(p Packet) error {
+	if w.closed {
+		return ErrWALClosed
+	}
+	if w.writeOffset >= w.opt.SegmentSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	record := encodeRecord(p)
+	n, err := w.writer.Write(record)
+	if err != nil {
+		// rewind partial record
+		w.writer.Truncate(w.writeOffset)
+		w.writer.Seek(w.writeOffset, io.SeekStart)
+		return err
+	}
+	w.writeOffset += int64(n)
+	w.length++
+	return nil
+}
+
+// Push appends packet value to log, returns false if it's full or failed
+func (w *WAL) Push(v interface{}) bool {
+	p, ok := v.(Packet)
+	if !ok {
+		return false
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.length >= w.maxSize {
+		return false
+	}
+	if err := w.append(p); err != nil {
+		log.Warn("wal-push-error", "dir", w.dir, "error", err)
+		return false
+	}
+	if w.opt.SyncPolicy == WALSyncAlways {
+		w.writer.Sync()
+	}
+	return true
+}
+
+// PushBatch appends packet values to log, returns false if it's full or failed,
+// values before full or failed one are kept in log
+func (w *WAL) PushBatch(vs []interface{}) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	ok := w.appendBatch(vs)
+	if w.opt.SyncPolicy == WALSyncAlways {
+		w.writer.Sync()
+	}
+	return ok
+}
+
+func (w *WAL) appendBatch(vs []interface{}) bool {
+	for _, v := range vs {
+		p, ok := v.(Packet)
+		if !ok {
+			continue
+		}
+		if w.length >= w.maxSize {
+			return false
+		}
+		if err := w.append(p); err != nil {
+			log.Warn("wal-push-error", "dir", w.dir, "error", err)
+			return false
+		}
+	}
+	return true
+}
+
+// Pop pops one packet from log
+func (w *WAL) Pop() interface{} {
+	values := w.PopBatch(1)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// PopBatch pops some packets from log
+func (w *WAL) PopBatch(max int) []interface{} {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed || w.length == 0 {
+		return []interface{}{}
+	}
+	count := w.length
+	if count > max {
+		count = max
+	}
+	items := make([]interface{}, 0, count)
+	for len(items) < count {
+		p, err := w.next()
+		if err != nil {
+			log.Warn("wal-pop-error", "dir", w.dir, "segment", w.readSeg, "offset", w.readOffset, "error", err)
+			break
+		}
+		items = append(items, p)
+	}
+	if len(items) > 0 {
+		w.dirty = true
+		if w.opt.SyncPolicy == WALSyncAlways {
+			w.saveCheckpoint()
+		}
+	}
+	return items
+}
+
+// next reads next record from read position,
+// it moves to next segment and removes consumed segment at the end of segment
+func (w *WAL) next() (Packet, error) {
+	for {
+		if w.reader == nil {
+			fh, err := os.Open(w.segmentFile(w.readSeg))
+			if err != nil {
+				return Packet{}, err
+			}
+			if _, err = fh.Seek(w.readOffset, io.SeekStart); err != nil {
+				fh.Close()
+				return Packet{}, err
+			}
+			w.reader = fh
+			w.readerBuf = bufio.NewReader(fh)
+		}
+		p, n, err := readRecord(w.readerBuf)
+		if err == nil {
+			w.readOffset += n
+			w.length--
+			return p, nil
+		}
+		if w.readSeg >= w.writeSeg {
+			if err == io.EOF {
+				// all records are consumed, fix length if some broken records are skipped
+				w.length = 0
+				w.closeReader()
+				return Packet{}, err
+			}
+			// broken record in writing segment, records after it can't be read,
+			// rotates to new segment and skips the rest of this one
+			if rerr := w.rotate(); rerr != nil {
+				w.closeReader()
+				return Packet{}, rerr
+			}
+			w.length = 0
+		}
+		if err != io.EOF {
+			log.Warn("wal-skip-broken-segment", "dir", w.dir, "segment", w.readSeg, "offset", w.readOffset, "error", err)
+		}
+		w.closeReader()
+		os.Remove(w.segmentFile(w.readSeg))
+		w.segments = w.segments[1:]
+		w.readSeg = w.segments[0]
+		w.readOffset = 0
+		w.dirty = true
+	}
+}
+
+func (w *WAL) closeReader() {
+	if w.reader != nil {
+		w.reader.Close()
+		w.reader = nil
+		w.readerBuf = nil
+	}
+}
+
+// Len returns number of unconsumed records
+func (w *WAL) Len() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.length
+}
+
+// Sync flushes segment to disk and saves checkpoint
+func (w *WAL) Sync() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.sync()
+}
+
+func (w *WAL) sync() error {
+	if w.closed {
+		return ErrWALClosed
+	}
+	if w.opt.SyncPolicy != WALSyncNone {
+		if err := w.writer.Sync(); err != nil {
+			return err
+		}
+	}
+	if w.dirty {
+		return w.saveCheckpoint()
+	}
+	return nil
+}
+
+func (w *WAL) syncLoop() {
+	ticker := time.NewTicker(w.opt.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.Sync(); err != nil && err != ErrWALClosed {
+				log.Warn("wal-sync-error", "dir", w.dir, "error", err)
+			}
+		case <-w.stopCh:
+			return
+		}
+	}
+}
+
+// Close syncs and closes log files
+func (w *WAL) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return nil
+	}
+	if w.opt.SyncPolicy == WALSyncNone {
+		w.writer.Sync()
+	}
+	err := w.sync()
+	w.closed = true
+	close(w.stopCh)
+	w.closeReader()
+	w.writer.Close()
+	return err
+}
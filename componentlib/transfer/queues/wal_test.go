@@ -0,0 +1,119 @@
+package queues
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWAL(t *testing.T) {
+	Convey("wal", t, func() {
+		dir := "./tests_wal"
+		os.RemoveAll(dir)
+		defer os.RemoveAll(dir)
+
+		Convey("wal.push.pop", func() {
+			wal, err := OpenWAL(dir, 10, WALOption{SyncPolicy: WALSyncAlways})
+			So(err, ShouldBeNil)
+			for i := 0; i < 10; i++ {
+				So(wal.Push(Packet{Data: []byte{byte(i)}, Len: i}), ShouldBeTrue)
+			}
+			So(wal.Push(testPack), ShouldBeFalse)
+			So(wal.Len(), ShouldEqual, 10)
+
+			values := wal.PopBatch(3)
+			So(values, ShouldHaveLength, 3)
+			So(values[0].(Packet).Len, ShouldEqual, 0)
+			So(values[2].(Packet).Data, ShouldResemble, []byte{2})
+			So(wal.Pop().(Packet).Len, ShouldEqual, 3)
+			So(wal.Len(), ShouldEqual, 6)
+			So(wal.Close(), ShouldBeNil)
+
+			Convey("wal.recover", func() {
+				wal, err := OpenWAL(dir, 10, WALOption{SyncPolicy: WALSyncAlways})
+				So(err, ShouldBeNil)
+				So(wal.Len(), ShouldEqual, 6)
+				So(wal.Pop().(Packet).Len, ShouldEqual, 4)
+				wal.Close()
+			})
+		})
+
+		Convey("wal.broken.tail", func() {
+			wal, _ := OpenWAL(dir, 10, WALOption{SyncPolicy: WALSyncAlways})
+			wal.Push(testPack)
+			wal.Push(testPack)
+			wal.Close()
+
+			file := wal.segmentFile(wal.writeSeg)
+			fh, _ := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0644)
+			fh.Write([]byte{0, 0, 1})
+			fh.Close()
+
+			wal, err := OpenWAL(dir, 10, WALOption{SyncPolicy: WALSyncAlways})
+			So(err, ShouldBeNil)
+			So(wal.Len(), ShouldEqual, 2)
+			So(wal.Push(testPack), ShouldBeTrue)
+			So(wal.PopBatch(10), ShouldHaveLength, 3)
+			So(wal.Len(), ShouldEqual, 0)
+			wal.Close()
+		})
+
+		Convey("wal.push.full", func() {
+			wal, _ := OpenWAL(dir, 3, WALOption{SyncPolicy: WALSyncAlways})
+			So(wal.PushBatch([]interface{}{testPack, testPack}), ShouldBeTrue)
+			So(wal.PushBatch([]interface{}{testPack, testPack, testPack}), ShouldBeFalse)
+			So(wal.Len(), ShouldEqual, 3)
+			wal.Close()
+		})
+
+		Convey("wal.broken.writing", func() {
+			wal, _ := OpenWAL(dir, 10, WALOption{SyncPolicy: WALSyncAlways})
+			for i := 0; i < 3; i++ {
+				wal.Push(Packet{Data: []byte{byte(i)}, Len: i})
+			}
+			// break crc of second record in writing segment
+			size := int64(len(encodeRecord(Packet{Data: []byte{0}})))
+			fh, _ := os.OpenFile(wal.segmentFile(wal.writeSeg), os.O_WRONLY, 0644)
+			fh.WriteAt([]byte{0xff, 0xff}, size+4)
+			fh.Close()
+
+			So(wal.PopBatch(10), ShouldHaveLength, 1)
+			So(wal.Len(), ShouldEqual, 0)
+			So(wal.Push(Packet{Data: []byte{9}, Len: 9}), ShouldBeTrue)
+			values := wal.PopBatch(10)
+			So(values, ShouldHaveLength, 1)
+			So(values[0].(Packet).Len, ShouldEqual, 9)
+			files, _ := filepath.Glob(filepath.Join(dir, "*"+walSegmentExt))
+			So(files, ShouldHaveLength, 1)
+			wal.Close()
+		})
+
+		Convey("wal.segments", func() {
+			wal, _ := OpenWAL(dir, 100, WALOption{SegmentSize: 500, SyncPolicy: WALSyncNone})
+			for i := 0; i < 10; i++ {
+				wal.Push(testPack)
+			}
+			files, _ := filepath.Glob(filepath.Join(dir, "*"+walSegmentExt))
+			So(len(files), ShouldBeGreaterThan, 1)
+
+			So(wal.PopBatch(10), ShouldHaveLength, 10)
+			files, _ = filepath.Glob(filepath.Join(dir, "*"+walSegmentExt))
+			So(files, ShouldHaveLength, 1)
+			wal.Close()
+		})
+
+		Convey("wal.queue", func() {
+			queue, err := NewWALQueue(10, dir, WALOption{})
+			So(err, ShouldBeNil)
+			_, ok := queue.Push(testPack)
+			So(ok, ShouldBeTrue)
+			packs, err := queue.Pop(5)
+			So(err, ShouldBeNil)
+			So(packs, ShouldHaveLength, 1)
+			So(packs[0].Data, ShouldResemble, testPack.Data)
+			So(queue.Close(), ShouldBeNil)
+		})
+	})
+}
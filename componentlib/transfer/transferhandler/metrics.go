@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/baishancloud/mallard/componentlib/transfer/queues"
 	"github.com/baishancloud/mallard/corelib/expvar"
@@ -21,13 +22,19 @@ var (
 	metricsOpenRecvQPS = expvar.NewQPS("http.metrics_open_recv")
 	metricsRopQPS      = expvar.NewQPS("http.metrics_pop")
 	metricsPopDataQPS  = expvar.NewQPS("http.metrics_pop_data")
+	metricsAckQPS      = expvar.NewQPS("http.metrics_ack")
+	metricsAckMissDiff = expvar.NewDiff("http.metrics_ack_miss")
 )
 
 func init() {
-	expvar.Register(metricsReqQPS, metricsRecvQPS, metricsOpenReqQPS, metricsOpenRecvQPS, metricsPopDataQPS, metricsRopQPS)
+	expvar.Register(metricsReqQPS, metricsRecvQPS, metricsOpenReqQPS, metricsOpenRecvQPS, metricsPopDataQPS, metricsRopQPS,
+		metricsAckQPS, metricsAckMissDiff)
 }
 
-var mQueue *queues.Queue
+var (
+	mQueue       *queues.Queue
+	counterRater = models.NewCounterRater(time.Hour)
+)
 
 var (
 	// ErrMetricsPushFail means failure when pushing metrics to queue
@@ -71,14 +78,35 @@ func getPopSize(r *http.Request) int {
 	return size
 }
 
+// getLeaseTimeout returns lease timeout from request,
+// if it's zero, popped packets are removed from queue immediately
+func getLeaseTimeout(r *http.Request) time.Duration {
+	if leaseStr := r.FormValue("lease"); leaseStr != "" {
+		if s, err := strconv.Atoi(leaseStr); err == nil && s > 0 {
+			return time.Second * time.Duration(s)
+		}
+	}
+	return 0
+}
+
 func metricsPop(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	metricsRopQPS.Incr(1)
 	if mQueue == nil {
 		httputil.Response404(rw, r)
 		return
 	}
-	size := getPopSize(r)
-	packets, err := mQueue.Pop(size)
+	var (
+		size    = getPopSize(r)
+		timeout = getLeaseTimeout(r)
+		leaseID string
+		packets queues.Packets
+		err     error
+	)
+	if timeout > 0 {
+		leaseID, packets, err = mQueue.PopLease(size, timeout)
+	} else {
+		packets, err = mQueue.Pop(size)
+	}
 	if err != nil {
 		httputil.ResponseFail(rw, r, err)
 		return
@@ -90,12 +118,37 @@ func metricsPop(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	}
 	rw.Header().Set("Data-Type", "pack")
 	rw.Header().Set("Data-Length", strconv.Itoa(len(packets)))
+	if leaseID != "" {
+		rw.Header().Set("Lease-ID", leaseID)
+	}
 	bytesLen, err := httputil.ResponseJSON(rw, packets, false, false)
 	if err != nil {
 		httputil.ResponseFail(rw, r, err)
 		return
 	}
-	log.Debug("m-pop-ok", "size", len(packets), "bytes", bytesLen, "r", r.RemoteAddr)
+	log.Debug("m-pop-ok", "size", len(packets), "bytes", bytesLen, "lease", leaseID, "r", r.RemoteAddr)
+}
+
+func metricsAck(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	metricsAckQPS.Incr(1)
+	if mQueue == nil {
+		httputil.Response404(rw, r)
+		return
+	}
+	leaseID := r.FormValue("lease")
+	if leaseID == "" {
+		httputil.ResponseFail(rw, r, errors.New("bad-params"))
+		return
+	}
+	count, err := mQueue.Ack(leaseID)
+	if err != nil {
+		metricsAckMissDiff.Incr(1)
+		httputil.Response404(rw, r)
+		log.Warn("m-ack-miss", "lease", leaseID, "r", r.RemoteAddr)
+		return
+	}
+	rw.WriteHeader(204)
+	log.Debug("m-ack-ok", "lease", leaseID, "size", count, "r", r.RemoteAddr)
 }
 
 func metricsPopOld(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -151,14 +204,14 @@ func openMetricRecv(rw http.ResponseWriter, r *http.Request, ps httprouter.Param
 		return
 	}
 	if r.Form.Get("v2") == "" {
-		pack.Data, err = convertOldMetric(pack.Data)
+		pack.Data, pack.Len, err = convertOldMetric(pack.Data)
 		if err != nil {
 			httputil.ResponseErrorJSON(rw, r, 400, err)
 			log.Warn("open-m-recv-error", "remote", httputil.RealIP(r), "tokens", users, "error", err)
 			return
 		}
 	}
-	if mQueue != nil {
+	if mQueue != nil && len(pack.Data) > 0 {
 		dump, ok := mQueue.Push(*pack)
 		if !ok {
 			httputil.ResponseFail(rw, r, ErrMetricsPushFail)
@@ -176,7 +229,7 @@ func openMetricRecv(rw http.ResponseWriter, r *http.Request, ps httprouter.Param
 		"store", r.Form.Get("store") != "",
 		"v1", r.Form.Get("v2") == "",
 		"user", users["user"])
-	metricsOpenRecvQPS.Incr(int64(len(pack.Data)))
+	metricsOpenRecvQPS.Incr(int64(pack.Len))
 }
 
 func getVerifyUsers(ps httprouter.Params) map[string]interface{} {
@@ -186,21 +239,31 @@ func getVerifyUsers(ps httprouter.Params) map[string]interface{} {
 	}
 }
 
-func convertOldMetric(data []byte) ([]byte, error) {
+// convertOldMetric converts old metrics to new metrics, returns data and count of new metrics,
+// counters without previous values are dropped
+func convertOldMetric(data []byte) ([]byte, int, error) {
 	var oldMs []*models.MetricRaw
 	if err := json.Unmarshal(data, &oldMs); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	if len(oldMs) == 0 {
-		return nil, errors.New("empty-metric-slice")
+		return nil, 0, errors.New("empty-metric-slice")
 	}
 	ms := make([]*models.Metric, 0, len(oldMs))
 	for _, m := range oldMs {
-		m2, err := m.ToNew()
+		m2, err := counterRater.ToNew(m)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
+		}
+		if m2 == nil {
+			continue
 		}
 		ms = append(ms, m2)
 	}
-	return json.Marshal(ms)
+	if len(ms) == 0 {
+		// all values are counters without previous values
+		return nil, 0, nil
+	}
+	data, err := json.Marshal(ms)
+	return data, len(ms), err
 }
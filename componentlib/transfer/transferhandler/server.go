@@ -23,6 +23,7 @@ func Create(isPublic bool) http.Handler {
 	r.GET("/api/config", (configGet))
 	r.GET("/api/metric_pop", buildAuthorized(metricsPopOld))
 	r.GET("/api/metric/pop", buildAuthorized(metricsPop))
+	r.POST("/api/metric/ack", buildAuthorized(metricsAck))
 	r.POST("/api/event", buildAuthorized(eventsRecv))
 	/*r.POST("/api/agentself", s.buildAuthorized(s.selfInfo))*/
 
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// CounterTypeGauge means value is used as it is
+	CounterTypeGauge = "GAUGE"
+	// CounterTypeCounter means value is monotonically increasing, it may wrap at 32 or 64 bits
+	CounterTypeCounter = "COUNTER"
+	// CounterTypeDerive means value is increasing, but it may be reset to zero
+	CounterTypeDerive = "DERIVE"
+)
+
+// IsCounter checks the raw metric is COUNTER or DERIVE type
+func (m *MetricRaw) IsCounter() bool {
+	t := strings.ToUpper(m.Type)
+	return t == CounterTypeCounter || t == CounterTypeDerive
+}
+
+type counterValue struct {
+	Value float64
+	Time  int64
+}
+
+// CounterRater keeps previous values of COUNTER and DERIVE series,
+// and converts their values to per-second rates
+type CounterRater struct {
+	lock      sync.Mutex
+	values    map[string]counterValue
+	expire    int64
+	lastClean int64
+}
+
+// NewCounterRater creates counter rater,
+// previous values that are not updated in expire duration are removed
+func NewCounterRater(expire time.Duration) *CounterRater {
+	return &CounterRater{
+		values:    make(map[string]counterValue),
+		expire:    int64(expire.Seconds()),
+		lastClean: time.Now().Unix(),
+	}
+}
+
+// ToNew converts raw metric to new metric, COUNTER and DERIVE values are converted to per-second rates.
+// It returns nil metric without error when no rate can be calculated,
+// such as the first sample, counter reset or duplicated timestamp
+func (cr *CounterRater) ToNew(raw *MetricRaw) (*Metric, error) {
+	m, err := raw.ToNew()
+	if err != nil || !raw.IsCounter() {
+		return m, err
+	}
+	now := m.Time
+	if now == 0 {
+		now = time.Now().Unix()
+	}
+	key := m.Hash()
+
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+	cr.clean(now)
+
+	prev, ok := cr.values[key]
+	if ok && now <= prev.Time {
+		return nil, nil
+	}
+	cr.values[key] = counterValue{Value: m.Value, Time: now}
+	if !ok {
+		return nil, nil
+	}
+	delta, ok := counterDelta(strings.ToUpper(raw.Type), prev.Value, m.Value)
+	if !ok {
+		return nil, nil
+	}
+	m.Value = delta / float64(now-prev.Time)
+	return m, nil
+}
+
+// counterDelta calculates increment from previous value,
+// COUNTER less than previous value is wrapped if previous value is close to 32 or 64 bits limit,
+// otherwise it's reset
+func counterDelta(counterType string, prev, current float64) (float64, bool) {
+	if current >= prev {
+		return current - prev, true
+	}
+	if counterType != CounterTypeCounter {
+		return 0, false
+	}
+	if prev <= math.MaxUint32 && prev > math.MaxUint32/2 && current < math.MaxUint32/2 {
+		return math.MaxUint32 - prev + current + 1, true
+	}
+	if prev > math.MaxUint32 && prev > math.MaxUint64/2 && current < math.MaxUint64/2 {
+		return math.MaxUint64 - prev + current + 1, true
+	}
+	return 0, false
+}
+
+func (cr *CounterRater) clean(now int64) {
+	if cr.expire <= 0 || now-cr.lastClean < cr.expire {
+		return
+	}
+	for key, v := range cr.values {
+		if now-v.Time > cr.expire {
+			delete(cr.values, key)
+		}
+	}
+	cr.lastClean = now
+}
+
+// Len returns number of counter series
+func (cr *CounterRater) Len() int {
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+	return len(cr.values)
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCounterRater(t *testing.T) {
+	Convey("counter", t, func() {
+		rater := NewCounterRater(time.Hour)
+		now := time.Now().Unix()
+		raw := func(value float64, t int64, counterType string) *MetricRaw {
+			return &MetricRaw{
+				Metric:    "net.if.in.bytes",
+				Endpoint:  "localhost",
+				Value:     value,
+				Timestamp: t,
+				Type:      counterType,
+				Tags:      "iface=eth0",
+			}
+		}
+
+		Convey("counter.gauge", func() {
+			m, err := rater.ToNew(raw(100, now, ""))
+			So(err, ShouldBeNil)
+			So(m.Value, ShouldEqual, 100)
+			m, _ = rater.ToNew(raw(100, now, CounterTypeGauge))
+			So(m.Value, ShouldEqual, 100)
+			So(rater.Len(), ShouldEqual, 0)
+		})
+
+		Convey("counter.rate", func() {
+			m, err := rater.ToNew(raw(100, now, CounterTypeCounter))
+			So(err, ShouldBeNil)
+			So(m, ShouldBeNil)
+
+			m, err = rater.ToNew(raw(700, now+60, "counter"))
+			So(err, ShouldBeNil)
+			So(m.Value, ShouldEqual, 10)
+			So(m.Tags["iface"], ShouldEqual, "eth0")
+
+			m, _ = rater.ToNew(raw(800, now+60, CounterTypeCounter))
+			So(m, ShouldBeNil)
+		})
+
+		Convey("counter.wrap", func() {
+			rater.ToNew(raw(math.MaxUint32-99, now, CounterTypeCounter))
+			m, _ := rater.ToNew(raw(500, now+60, CounterTypeCounter))
+			So(m.Value, ShouldEqual, 10)
+		})
+
+		Convey("counter.reset", func() {
+			rater.ToNew(raw(1000, now, CounterTypeCounter))
+			m, _ := rater.ToNew(raw(10, now+60, CounterTypeCounter))
+			So(m, ShouldBeNil)
+			m, _ = rater.ToNew(raw(70, now+120, CounterTypeCounter))
+			So(m.Value, ShouldEqual, 1)
+		})
+
+		Convey("counter.derive", func() {
+			rater.ToNew(raw(math.MaxUint32-99, now, CounterTypeDerive))
+			m, _ := rater.ToNew(raw(500, now+60, CounterTypeDerive))
+			So(m, ShouldBeNil)
+			m, _ = rater.ToNew(raw(620, now+120, CounterTypeDerive))
+			So(m.Value, ShouldEqual, 2)
+		})
+
+		Convey("counter.clean", func() {
+			rater.ToNew(raw(100, now-7200, CounterTypeCounter))
+			So(rater.Len(), ShouldEqual, 1)
+			rater.lastClean = now - 7200
+			rater.ToNew(raw(100, now, CounterTypeCounter))
+			So(rater.Len(), ShouldEqual, 1)
+			m, _ := rater.ToNew(raw(160, now+60, CounterTypeCounter))
+			So(m.Value, ShouldEqual, 1)
+		})
+	})
+}
@@ -18,10 +18,13 @@ type EndpointData struct {
 type EndpointConfig struct {
 	// Groups  []int    `json:"-"`
 	// Templates  []*Template     `json:"-"`
-	Plugins    []string         `json:"plgs,omitempty"`
-	Strategies []*Strategy      `json:"ss,omitempty"`
-	Builtin    *EndpointBuiltin `json:"bt,omitempty"`
-	hashCode   string
+	Plugins          []string                    `json:"plgs,omitempty"`
+	PluginsIntegrity map[string]*PluginIntegrity `json:"plgs_ig,omitempty"` // plugin dir -> integrity
+	Processes        []*ProcessRule              `json:"procs,omitempty"`
+	Logs             []*LogRule                  `json:"logs,omitempty"`
+	Strategies       []*Strategy                 `json:"ss,omitempty"`
+	Builtin          *EndpointBuiltin            `json:"bt,omitempty"`
+	hashCode         string
 }
 
 // Len is data length of en endpoint,
@@ -51,7 +54,70 @@ func (ec *EndpointConfig) IsUsingStrategy(id int) bool {
 
 // EndpointBuiltin is config for agent builtin service
 type EndpointBuiltin struct {
-	Ports []int64 `json:"ports,omitempty"`
+	Ports      []int64                     `json:"ports,omitempty"`
+	Probes     []*Probe                    `json:"probes,omitempty"`
+	Collectors map[string]*CollectorOption `json:"collectors,omitempty"` // collector name -> option
+}
+
+// CollectorOption is scheduling option of one system collector,
+// zero or nil values use default values
+type CollectorOption struct {
+	Interval int   `json:"interval,omitempty"` // seconds
+	Timeout  int   `json:"timeout,omitempty"`  // seconds
+	Enable   *bool `json:"enable,omitempty"`
+}
+
+const (
+	// ProbeHTTP requests url and checks status and body
+	ProbeHTTP = "http"
+	// ProbeTCP connects to host:port
+	ProbeTCP = "tcp"
+	// ProbeDNS resolves domain
+	ProbeDNS = "dns"
+	// ProbeTLS handshakes with host:port and checks certificate
+	ProbeTLS = "tls"
+)
+
+// Probe is target of agent builtin synthetic probe
+type Probe struct {
+	Name    string `json:"name"`              // tag value of probe metric
+	Type    string `json:"type"`              // http, tcp, dns or tls
+	Target  string `json:"target"`            // url for http, host:port for tcp and tls, domain for dns
+	Timeout int    `json:"timeout,omitempty"` // seconds
+	Status  int    `json:"status,omitempty"`  // expected http status, status < 400 is success if 0
+	Body    string `json:"body,omitempty"`    // regexp to match http body
+	Record  string `json:"record,omitempty"`  // dns record type, A, CNAME, MX, NS or TXT, A if empty
+	Expect  string `json:"expect,omitempty"`  // string contained by one of dns answers
+}
+
+// PluginIntegrity is expected hashes or signing key of files in one plugin dir,
+// agent only runs the files matching the hashes or signed by the key
+type PluginIntegrity struct {
+	Hashes    map[string]string `json:"hashes,omitempty"` // file path relative to dir -> sha256 hex
+	PublicKey string            `json:"pubkey,omitempty"` // base64 ed25519 public key to verify <file>.sig
+}
+
+// ProcessRule is rule to match processes for process metrics,
+// process should match all non-empty conditions
+type ProcessRule struct {
+	Name    string `json:"name"`              // tag value of process metric
+	Process string `json:"process,omitempty"` // process name in /proc/<pid>/comm
+	Cmdline string `json:"cmdline,omitempty"` // regexp to match command line
+	Pidfile string `json:"pidfile,omitempty"`
+	Unit    string `json:"unit,omitempty"` // systemd unit, such as nginx.service
+}
+
+// IsValid checks rule has name and conditions
+func (pr *ProcessRule) IsValid() bool {
+	return pr.Name != "" && (pr.Process != "" || pr.Cmdline != "" || pr.Pidfile != "" || pr.Unit != "")
+}
+
+// LogRule is rule to count lines matching pattern in log files,
+// named captures in pattern are parsed as numeric fields
+type LogRule struct {
+	Name    string `json:"name"`    // metric name
+	File    string `json:"file"`    // file path, glob pattern is allowed
+	Pattern string `json:"pattern"` // regexp, such as "upstream_time=(?P<upstream_time>[0-9.]+)"
 }
 
 // EndpointHeartbeat is endpoint heartbeat item
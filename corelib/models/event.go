@@ -94,6 +94,8 @@ const (
 	EventMissingStrategy EventStatus = -9
 	// EventClosed means event strategy is closed to the endpoint
 	EventClosed EventStatus = -11
+	// EventFlapping means event status changes too often, it's held until status is stable
+	EventFlapping EventStatus = -13
 )
 
 // String implement stringer interface
@@ -121,6 +123,8 @@ func (status EventStatus) String() string {
 		return "MISSING"
 	case EventClosed:
 		return "CLOSED"
+	case EventFlapping:
+		return "FLAPPING"
 	}
 	return ""
 }
@@ -15,6 +15,8 @@ type Expression struct {
 	MaxStep       int     `json:"max_step" db:"max_step"`
 	Priority      int     `json:"priority" db:"priority"`
 	Note          string  `json:"note" db:"note"`
+	Schedule      string  `json:"schedule,omitempty" db:"schedule"` // running windows, see Schedule
+	Timezone      string  `json:"timezone,omitempty" db:"timezone"` // IANA timezone of schedule
 
 	parsedMetrics []string
 }
@@ -37,3 +39,17 @@ func (exp *Expression) Metrics() []string {
 	}
 	return exp.parsedMetrics
 }
+
+// IsInTime checks t is in expression running windows
+func (exp *Expression) IsInTime(t int64) bool {
+	return isInRunTime(t, "", "", exp.Schedule, exp.Timezone)
+}
+
+// CheckSchedule checks schedule and timezone are valid
+func (exp *Expression) CheckSchedule() error {
+	if exp.Schedule == "" && exp.Timezone == "" {
+		return nil
+	}
+	_, err := GetSchedule(exp.Schedule, exp.Timezone)
+	return err
+}
@@ -0,0 +1,242 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrScheduleInvalid is error of invalid schedule spec
+	ErrScheduleInvalid = errors.New("schedule-invalid")
+)
+
+// Schedule is running windows of strategy or expression.
+//
+// The spec is rules separated by ";", each rule is "[!]days [HH:MM-HH:MM]".
+// days is "*", weekday or weekday range as "mon-fri", date as "2018-10-01",
+// or list of them separated by "|". Time range could cross midnight as "22:00-06:00",
+// omitted time range means whole day.
+// Rules with "!" are excluded windows, other rules are included windows.
+// If no included windows, all time is included except excluded windows.
+//
+// For example, "mon-fri 09:00-18:00;!2018-10-01" runs in business hours but national day,
+// "!sun 02:00-04:00" runs always but weekly backup window.
+type Schedule struct {
+	location *time.Location
+	includes []*scheduleRule
+	excludes []*scheduleRule
+}
+
+type scheduleRule struct {
+	allDays  bool
+	weekdays [7]bool
+	dates    map[string]bool
+	begin    string
+	end      string
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseSchedule parses schedule spec in timezone,
+// timezone is IANA name such as "Asia/Shanghai", empty timezone means local time,
+// center fills its timezone to strategies with running windows, see LocalTimezone
+func ParseSchedule(spec string, timezone string) (*Schedule, error) {
+	sch := &Schedule{location: time.Local}
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("%s: bad timezone %s", ErrScheduleInvalid, timezone)
+		}
+		sch.location = loc
+	}
+	for _, ruleStr := range strings.Split(spec, ";") {
+		ruleStr = strings.TrimSpace(ruleStr)
+		if ruleStr == "" {
+			continue
+		}
+		exclude := strings.HasPrefix(ruleStr, "!")
+		rule, err := parseScheduleRule(strings.TrimPrefix(ruleStr, "!"))
+		if err != nil {
+			return nil, err
+		}
+		if exclude {
+			sch.excludes = append(sch.excludes, rule)
+		} else {
+			sch.includes = append(sch.includes, rule)
+		}
+	}
+	return sch, nil
+}
+
+func parseScheduleRule(s string) (*scheduleRule, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("%s: bad rule '%s'", ErrScheduleInvalid, s)
+	}
+	rule := &scheduleRule{}
+	for _, day := range strings.Split(strings.ToLower(fields[0]), "|") {
+		if day == "*" {
+			rule.allDays = true
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", day); err == nil {
+			if rule.dates == nil {
+				rule.dates = make(map[string]bool)
+			}
+			rule.dates[day] = true
+			continue
+		}
+		pair := strings.SplitN(day, "-", 2)
+		begin, ok := weekdayNames[pair[0]]
+		if !ok {
+			return nil, fmt.Errorf("%s: bad day '%s'", ErrScheduleInvalid, day)
+		}
+		end := begin
+		if len(pair) == 2 {
+			if end, ok = weekdayNames[pair[1]]; !ok {
+				return nil, fmt.Errorf("%s: bad day '%s'", ErrScheduleInvalid, day)
+			}
+		}
+		for d := begin; ; d = (d + 1) % 7 {
+			rule.weekdays[d] = true
+			if d == end {
+				break
+			}
+		}
+	}
+	rule.begin, rule.end = "00:00", "24:00"
+	if len(fields) == 2 {
+		pair := strings.SplitN(fields[1], "-", 2)
+		if len(pair) != 2 || !isClock(pair[0]) || !isClock(pair[1]) {
+			return nil, fmt.Errorf("%s: bad time range '%s'", ErrScheduleInvalid, fields[1])
+		}
+		rule.begin, rule.end = pair[0], pair[1]
+	}
+	return rule, nil
+}
+
+func isClock(s string) bool {
+	if s == "24:00" {
+		return true
+	}
+	_, err := time.Parse("15:04", s)
+	return err == nil && len(s) == 5
+}
+
+func (r *scheduleRule) matchDay(t time.Time) bool {
+	return r.allDays || r.weekdays[t.Weekday()] || r.dates[t.Format("2006-01-02")]
+}
+
+func (r *scheduleRule) contains(t time.Time) bool {
+	clock := t.Format("15:04")
+	if r.begin <= r.end {
+		return r.matchDay(t) && clock >= r.begin && clock < r.end
+	}
+	// crosses midnight, the window after midnight belongs to previous day
+	if clock >= r.begin {
+		return r.matchDay(t)
+	}
+	if clock < r.end {
+		return r.matchDay(t.AddDate(0, 0, -1))
+	}
+	return false
+}
+
+// Location returns timezone location of the schedule
+func (sch *Schedule) Location() *time.Location {
+	return sch.location
+}
+
+// Contains checks unix time t is in running windows
+func (sch *Schedule) Contains(t int64) bool {
+	tm := time.Unix(t, 0).In(sch.location)
+	for _, rule := range sch.excludes {
+		if rule.contains(tm) {
+			return false
+		}
+	}
+	if len(sch.includes) == 0 {
+		return true
+	}
+	for _, rule := range sch.includes {
+		if rule.contains(tm) {
+			return true
+		}
+	}
+	return false
+}
+
+// LocalTimezone returns IANA name of local timezone from TZ env or /etc/localtime link,
+// center fills it to strategies without timezone, so agents judge running windows in same timezone as eventor.
+// It returns empty string if the name is unknown
+func LocalTimezone() string {
+	tz := strings.TrimPrefix(os.Getenv("TZ"), ":")
+	if tz == "" {
+		link, err := os.Readlink("/etc/localtime")
+		if err != nil {
+			return ""
+		}
+		tz = link
+	}
+	if idx := strings.Index(tz, "zoneinfo/"); idx >= 0 {
+		tz = tz[idx+len("zoneinfo/"):]
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return ""
+	}
+	return tz
+}
+
+var schedules sync.Map
+
+// GetSchedule returns parsed schedule of spec and timezone, it caches parsed result
+func GetSchedule(spec string, timezone string) (*Schedule, error) {
+	key := spec + "@" + timezone
+	if v, ok := schedules.Load(key); ok {
+		return v.(*Schedule), nil
+	}
+	sch, err := ParseSchedule(spec, timezone)
+	if err != nil {
+		return nil, err
+	}
+	schedules.Store(key, sch)
+	return sch, nil
+}
+
+// isInRunTime checks t is in schedule and "15:04" run range in timezone,
+// invalid schedule is always in time, it's reported by judge as syntax error
+func isInRunTime(t int64, runBegin, runEnd, spec, timezone string) bool {
+	if spec == "" && timezone == "" && (runBegin == "" || runEnd == "") {
+		return true
+	}
+	sch, err := GetSchedule(spec, timezone)
+	if err != nil {
+		return true
+	}
+	if !sch.Contains(t) {
+		return false
+	}
+	if runBegin == "" || runEnd == "" {
+		return true
+	}
+	if strings.TrimSpace(runBegin) == strings.TrimSpace(runEnd) {
+		return false
+	}
+	str := time.Unix(t, 0).In(sch.location).Format("15:04")
+	if runBegin < runEnd {
+		return str >= runBegin && str <= runEnd
+	}
+	return !(str >= runEnd && str <= runBegin)
+}
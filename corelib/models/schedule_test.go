@@ -0,0 +1,82 @@
+package models
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSchedule(t *testing.T) {
+	at := func(s string) int64 {
+		tm, _ := time.Parse("2006-01-02 15:04 -0700", s)
+		return tm.Unix()
+	}
+	Convey("schedule", t, func() {
+		Convey("schedule.invalid", func() {
+			for _, spec := range []string{"xyz", "mon-fri 9:00-18:00", "mon 09:00", "mon 09:00-18:00 x"} {
+				_, err := ParseSchedule(spec, "")
+				So(err, ShouldNotBeNil)
+			}
+			_, err := ParseSchedule("", "Mars/Base")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("schedule.business", func() {
+			sch, err := ParseSchedule("mon-fri 09:00-18:00;!2018-10-01", "UTC")
+			So(err, ShouldBeNil)
+			So(sch.Contains(at("2018-09-28 10:00 +0000")), ShouldBeTrue)  // friday
+			So(sch.Contains(at("2018-09-28 18:30 +0000")), ShouldBeFalse) // friday night
+			So(sch.Contains(at("2018-09-29 10:00 +0000")), ShouldBeFalse) // saturday
+			So(sch.Contains(at("2018-10-01 10:00 +0000")), ShouldBeFalse) // national day
+			So(sch.Contains(at("2018-10-02 10:00 +0000")), ShouldBeTrue)
+		})
+
+		Convey("schedule.exclude", func() {
+			sch, err := ParseSchedule("!sun 23:00-02:00", "UTC")
+			So(err, ShouldBeNil)
+			So(sch.Contains(at("2018-09-30 23:30 +0000")), ShouldBeFalse) // sunday
+			So(sch.Contains(at("2018-10-01 01:30 +0000")), ShouldBeFalse) // monday, but in sunday window
+			So(sch.Contains(at("2018-10-01 23:30 +0000")), ShouldBeTrue)
+			So(sch.Contains(at("2018-09-30 12:00 +0000")), ShouldBeTrue)
+		})
+
+		Convey("schedule.timezone", func() {
+			sch, err := ParseSchedule("* 09:00-18:00", "Asia/Shanghai")
+			So(err, ShouldBeNil)
+			So(sch.Contains(at("2018-09-28 02:00 +0000")), ShouldBeTrue)
+			So(sch.Contains(at("2018-09-28 12:00 +0000")), ShouldBeFalse)
+		})
+
+		Convey("schedule.strategy", func() {
+			st := &Strategy{RunBegin: "09:00", RunEnd: "18:00", Timezone: "Asia/Shanghai"}
+			So(st.CheckSchedule(), ShouldBeNil)
+			So(st.IsInTime(at("2018-09-28 02:00 +0000")), ShouldBeTrue)
+			So(st.IsInTime(at("2018-09-28 12:00 +0000")), ShouldBeFalse)
+
+			st.Schedule = "mon-fri"
+			So(st.IsInTime(at("2018-09-29 02:00 +0000")), ShouldBeFalse)
+
+			st.Schedule = "bad"
+			So(st.CheckSchedule(), ShouldNotBeNil)
+			So(st.IsInTime(at("2018-09-29 02:00 +0000")), ShouldBeTrue)
+
+			exp := &Expression{Schedule: "sat|sun", Timezone: "UTC"}
+			So(exp.CheckSchedule(), ShouldBeNil)
+			So(exp.IsInTime(at("2018-09-29 02:00 +0000")), ShouldBeTrue)
+			So(exp.IsInTime(at("2018-09-28 02:00 +0000")), ShouldBeFalse)
+		})
+
+		Convey("schedule.local", func() {
+			tz := os.Getenv("TZ")
+			defer os.Setenv("TZ", tz)
+			os.Setenv("TZ", "Asia/Shanghai")
+			So(LocalTimezone(), ShouldEqual, "Asia/Shanghai")
+			os.Setenv("TZ", ":/usr/share/zoneinfo/Europe/Paris")
+			So(LocalTimezone(), ShouldEqual, "Europe/Paris")
+			os.Setenv("TZ", "Bad/Zone")
+			So(LocalTimezone(), ShouldBeEmpty)
+		})
+	})
+}
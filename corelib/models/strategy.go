@@ -5,7 +5,6 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-	"time"
 )
 
 // Template is template object for strategy
@@ -44,6 +43,15 @@ type Strategy struct {
 	GroupBys       []string `json:"group_bys,omitempty" db:"-"`
 	Score          float64  `json:"score,omitempty" db:"score"`
 
+	RecoverValueStr string   `json:"-" db:"recover_value"`
+	RecoverValue    *float64 `json:"rcv,omitempty" db:"-"`             // value to recover from problem, as hysteresis
+	RecoverCount    int      `json:"rcc,omitempty" db:"recover_count"` // consecutive ok count to recover from problem
+	FlapCount       int      `json:"flc,omitempty" db:"flap_count"`    // status changes count in window to be flapping
+	FlapWindow      int      `json:"flw,omitempty" db:"flap_window"`   // flapping window in seconds
+
+	Schedule string `json:"sch,omitempty" db:"schedule"` // running windows, see Schedule
+	Timezone string `json:"tz,omitempty" db:"timezone"`  // IANA timezone of run time and schedule
+
 	tags     map[string]string
 	template *Template
 
@@ -61,6 +69,14 @@ func (s *Strategy) ToSimple() *Strategy {
 			Operator:       s.Operator,
 			RightValue:     s.RightValue,
 			TagString:      s.TagString,
+			RecoverValue:   s.RecoverValue,
+			RecoverCount:   s.RecoverCount,
+			FlapCount:      s.FlapCount,
+			FlapWindow:     s.FlapWindow,
+			RunBegin:       s.RunBegin,
+			RunEnd:         s.RunEnd,
+			Schedule:       s.Schedule,
+			Timezone:       s.Timezone,
 		}
 	}
 	return s.simple
@@ -89,19 +105,20 @@ func (s *Strategy) GroupBy() []string {
 	return s.GroupBys
 }
 
-// IsInTime check t is in strategy running duration
+// IsInTime check t is in strategy running duration,
+// RunBegin and RunEnd are shorthand of daily running window,
+// Schedule and Timezone are weekday, calendar and timezone-aware running windows
 func (s *Strategy) IsInTime(t int64) bool {
-	if s.RunBegin == "" || s.RunEnd == "" {
-		return true
-	}
-	if strings.TrimSpace(s.RunBegin) == strings.TrimSpace(s.RunEnd) {
-		return false
-	}
-	str := time.Unix(t, 0).Format("15:04")
-	if s.RunBegin < s.RunEnd {
-		return str >= s.RunBegin && str <= s.RunEnd
+	return isInRunTime(t, s.RunBegin, s.RunEnd, s.Schedule, s.Timezone)
+}
+
+// CheckSchedule checks schedule and timezone are valid
+func (s *Strategy) CheckSchedule() error {
+	if s.Schedule == "" && s.Timezone == "" {
+		return nil
 	}
-	return !(str >= s.RunEnd && str <= s.RunBegin)
+	_, err := GetSchedule(s.Schedule, s.Timezone)
+	return err
 }
 
 // IsEnable checkes strategy is enabled to run
@@ -115,6 +132,12 @@ func (s *Strategy) IsEnable() bool {
 	return true
 }
 
+// IsBaseline checks strategy uses baseline function, such as season(#3,7) or ewma(#3,20),
+// it's judged in judge service instead of agent
+func (s *Strategy) IsBaseline() bool {
+	return strings.HasPrefix(s.Func, "season(") || strings.HasPrefix(s.Func, "ewma(")
+}
+
 // ExtractTags extracts tag string to map
 func ExtractTags(s string) (map[string]string, error) {
 	if s == "" {
@@ -135,6 +158,54 @@ func ExtractTags(s string) (map[string]string, error) {
 	return tags, nil
 }
 
+// ExtractTagFilters extracts strategy tag string to map of tag filters,
+// it's same to ExtractTags but supports "key!~regexp" without "=",
+// a comma in value is escaped as "\,", such as "mount=~^/d{1\,3}$"
+func ExtractTagFilters(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	tags := make(map[string]string)
+	tagSlice := splitTagFilters(s)
+	for _, tag := range tagSlice {
+		idx := strings.Index(tag, "!~")
+		eqIdx := strings.Index(tag, "=")
+		if idx > 0 && (eqIdx < 0 || idx < eqIdx) && !strings.HasPrefix(tag[idx+2:], "=") {
+			// key!~regexp, as key! with value ~regexp
+			tags[strings.TrimSpace(tag[:idx])+"!"] = "~" + strings.TrimSpace(tag[idx+2:])
+			continue
+		}
+		pair := strings.SplitN(tag, "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("bad tag %s", tag)
+		}
+		tags[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+	}
+	return tags, nil
+}
+
+// splitTagFilters splits tag filters by comma, "\," is kept as comma in value
+func splitTagFilters(s string) []string {
+	var (
+		tags []string
+		buf  strings.Builder
+	)
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && s[i+1] == ',' {
+			buf.WriteByte(',')
+			i++
+			continue
+		}
+		if s[i] == ',' {
+			tags = append(tags, buf.String())
+			buf.Reset()
+			continue
+		}
+		buf.WriteByte(s[i])
+	}
+	return append(tags, buf.String())
+}
+
 // ExtractFields extracts field string to map
 func ExtractFields(s string) (map[string]interface{}, error) {
 	if s == "" {
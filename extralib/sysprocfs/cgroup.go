@@ -0,0 +1,201 @@
+package sysprocfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	cgroupDir = "/sys/fs/cgroup"
+
+	cgroupContainerRegexp = regexp.MustCompile(`[0-9a-f]{64}`)
+	cgroupNoLimit         = int64(1) << 60 // v1 shows unlimited memory as a very large number
+)
+
+// CgroupStat is resource usage of one cgroup,
+// counters are accumulated values since cgroup created
+type CgroupStat struct {
+	Path        string `json:"path"` // relative path to cgroup root, such as /system.slice/nginx.service
+	ContainerID string `json:"container_id,omitempty"`
+	Unit        string `json:"unit,omitempty"`
+
+	CPUUsage         uint64  `json:"cpu_usage"` // nanoseconds
+	CPUPeriods       uint64  `json:"cpu_periods"`
+	CPUThrottled     uint64  `json:"cpu_throttled"`
+	CPUThrottledTime uint64  `json:"cpu_throttled_time"` // nanoseconds
+	CPULimit         float64 `json:"cpu_limit"`          // cores, 0 if no limit
+
+	MemoryUsage int64 `json:"memory_usage"`
+	MemoryLimit int64 `json:"memory_limit"` // 0 if no limit
+	OOMKills    int64 `json:"oom_kills"`
+
+	IOReadBytes  uint64 `json:"io_read_bytes"`
+	IOWriteBytes uint64 `json:"io_write_bytes"`
+}
+
+// SetCgroupDir sets cgroup root directory, such as /host/sys/fs/cgroup when running in container
+func SetCgroupDir(dir string) {
+	cgroupDir = dir
+}
+
+// CgroupVersion returns 2 if cgroup root is unified hierarchy, or 1
+func CgroupVersion() int {
+	if _, err := os.Stat(filepath.Join(cgroupDir, "cgroup.controllers")); err == nil {
+		return 2
+	}
+	return 1
+}
+
+// Cgroups returns stats of cgroups for containers or systemd services,
+// other cgroups such as slices are ignored
+func Cgroups() ([]*CgroupStat, error) {
+	if CgroupVersion() == 2 {
+		return walkCgroups(cgroupDir, readCgroupV2)
+	}
+	root := filepath.Join(cgroupDir, "cpuacct")
+	if _, err := os.Stat(root); err != nil {
+		root = filepath.Join(cgroupDir, "cpu,cpuacct")
+	}
+	return walkCgroups(root, readCgroupV1)
+}
+
+func walkCgroups(root string, readFn func(root string, path string, stat *CgroupStat)) ([]*CgroupStat, error) {
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // no cgroup
+		}
+		return nil, err
+	}
+	var stats []*CgroupStat
+	err = filepath.Walk(root, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, fpath)
+		if rel == "." {
+			return nil
+		}
+		stat := &CgroupStat{Path: "/" + filepath.ToSlash(rel)}
+		name := info.Name()
+		if id := cgroupContainerRegexp.FindString(name); id != "" {
+			stat.ContainerID = id[:12]
+		} else if strings.HasSuffix(name, ".service") {
+			stat.Unit = name
+		} else {
+			return nil
+		}
+		readFn(root, fpath, stat)
+		stats = append(stats, stat)
+		return filepath.SkipDir // sub cgroups are counted in parent
+	})
+	return stats, err
+}
+
+func readCgroupV2(_ string, dir string, stat *CgroupStat) {
+	cpuStat := readCgroupKeyValues(filepath.Join(dir, "cpu.stat"))
+	stat.CPUUsage = uint64(cpuStat["usage_usec"]) * 1000
+	stat.CPUPeriods = uint64(cpuStat["nr_periods"])
+	stat.CPUThrottled = uint64(cpuStat["nr_throttled"])
+	stat.CPUThrottledTime = uint64(cpuStat["throttled_usec"]) * 1000
+	if fields := strings.Fields(readCgroupString(filepath.Join(dir, "cpu.max"))); len(fields) == 2 && fields[0] != "max" {
+		quota, _ := strconv.ParseFloat(fields[0], 64)
+		period, _ := strconv.ParseFloat(fields[1], 64)
+		if period > 0 {
+			stat.CPULimit = quota / period
+		}
+	}
+
+	stat.MemoryUsage = readCgroupInt(filepath.Join(dir, "memory.current"))
+	stat.MemoryLimit = readCgroupInt(filepath.Join(dir, "memory.max"))
+	stat.OOMKills = readCgroupKeyValues(filepath.Join(dir, "memory.events"))["oom_kill"]
+
+	for _, line := range strings.Split(readCgroupString(filepath.Join(dir, "io.stat")), "\n") {
+		// 8:0 rbytes=1024 wbytes=2048 rios=1 wios=2 dbytes=0 dios=0
+		for _, field := range strings.Fields(line) {
+			idx := strings.Index(field, "=")
+			if idx < 0 {
+				continue
+			}
+			value, _ := strconv.ParseUint(field[idx+1:], 10, 64)
+			switch field[:idx] {
+			case "rbytes":
+				stat.IOReadBytes += value
+			case "wbytes":
+				stat.IOWriteBytes += value
+			}
+		}
+	}
+}
+
+func readCgroupV1(root string, dir string, stat *CgroupStat) {
+	controllerDir := func(controller string) string {
+		return filepath.Join(filepath.Dir(root), controller, filepath.FromSlash(stat.Path))
+	}
+	stat.CPUUsage = uint64(readCgroupInt(filepath.Join(dir, "cpuacct.usage")))
+	cpuDir := controllerDir("cpu")
+	cpuStat := readCgroupKeyValues(filepath.Join(cpuDir, "cpu.stat"))
+	stat.CPUPeriods = uint64(cpuStat["nr_periods"])
+	stat.CPUThrottled = uint64(cpuStat["nr_throttled"])
+	stat.CPUThrottledTime = uint64(cpuStat["throttled_time"])
+	quota := readCgroupInt(filepath.Join(cpuDir, "cpu.cfs_quota_us"))
+	period := readCgroupInt(filepath.Join(cpuDir, "cpu.cfs_period_us"))
+	if quota > 0 && period > 0 {
+		stat.CPULimit = float64(quota) / float64(period)
+	}
+
+	memDir := controllerDir("memory")
+	stat.MemoryUsage = readCgroupInt(filepath.Join(memDir, "memory.usage_in_bytes"))
+	stat.MemoryLimit = readCgroupInt(filepath.Join(memDir, "memory.limit_in_bytes"))
+	stat.OOMKills = readCgroupKeyValues(filepath.Join(memDir, "memory.oom_control"))["oom_kill"]
+
+	ioData := readCgroupString(filepath.Join(controllerDir("blkio"), "blkio.throttle.io_service_bytes"))
+	for _, line := range strings.Split(ioData, "\n") {
+		// 8:0 Read 1024
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		value, _ := strconv.ParseUint(fields[2], 10, 64)
+		switch fields[1] {
+		case "Read":
+			stat.IOReadBytes += value
+		case "Write":
+			stat.IOWriteBytes += value
+		}
+	}
+}
+
+func readCgroupString(file string) string {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readCgroupInt reads integer value in file, returns 0 if max or unlimited
+func readCgroupInt(file string) int64 {
+	value, err := strconv.ParseInt(readCgroupString(file), 10, 64)
+	if err != nil || value >= cgroupNoLimit {
+		return 0
+	}
+	return value
+}
+
+// readCgroupKeyValues reads lines as "key value" in file
+func readCgroupKeyValues(file string) map[string]int64 {
+	m := make(map[string]int64)
+	for _, line := range strings.Split(readCgroupString(file), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		m[fields[0]], _ = strconv.ParseInt(fields[1], 10, 64)
+	}
+	return m
+}
@@ -0,0 +1,127 @@
+package sysprocfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testContainerID = "4f1c9b2e8d7a6c5b4a39281706f5e4d3c2b1a0f9e8d7c6b5a4938271605f4e3d"
+
+func writeCgroupFiles(dir string, files map[string]string) {
+	os.MkdirAll(dir, os.ModePerm)
+	for name, data := range files {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+	}
+}
+
+func TestCgroupV2(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "cgroup")
+	defer os.RemoveAll(dir)
+	defer SetCgroupDir("/sys/fs/cgroup")
+	SetCgroupDir(dir)
+
+	writeCgroupFiles(dir, map[string]string{"cgroup.controllers": "cpu io memory pids\n"})
+	writeCgroupFiles(filepath.Join(dir, "system.slice", "nginx.service"), map[string]string{
+		"cpu.stat":       "usage_usec 2000000\nuser_usec 1500000\nsystem_usec 500000\nnr_periods 100\nnr_throttled 10\nthrottled_usec 30000\n",
+		"cpu.max":        "max 100000\n",
+		"memory.current": "104857600\n",
+		"memory.max":     "max\n",
+		"memory.events":  "low 0\nhigh 0\nmax 0\noom 1\noom_kill 1\n",
+		"io.stat":        "8:0 rbytes=1024 wbytes=2048 rios=1 wios=2 dbytes=0 dios=0\n8:16 rbytes=1024 wbytes=0 rios=1 wios=0 dbytes=0 dios=0\n",
+	})
+	writeCgroupFiles(filepath.Join(dir, "system.slice", "nginx.service", "sub"), map[string]string{
+		"cpu.stat": "usage_usec 1000\n",
+	})
+	writeCgroupFiles(filepath.Join(dir, "system.slice", "docker-"+testContainerID+".scope"), map[string]string{
+		"cpu.stat":       "usage_usec 1000000\nnr_periods 0\nnr_throttled 0\nthrottled_usec 0\n",
+		"cpu.max":        "200000 100000\n",
+		"memory.current": "536870912\n",
+		"memory.max":     "1073741824\n",
+	})
+	writeCgroupFiles(filepath.Join(dir, "user.slice"), nil)
+
+	Convey("cgroup.v2", t, func() {
+		So(CgroupVersion(), ShouldEqual, 2)
+		stats, err := Cgroups()
+		So(err, ShouldBeNil)
+		So(stats, ShouldHaveLength, 2)
+
+		container := stats[0]
+		So(container.Path, ShouldEqual, "/system.slice/docker-"+testContainerID+".scope")
+		So(container.ContainerID, ShouldEqual, testContainerID[:12])
+		So(container.CPULimit, ShouldEqual, 2)
+		So(container.MemoryUsage, ShouldEqual, 536870912)
+		So(container.MemoryLimit, ShouldEqual, 1073741824)
+
+		service := stats[1]
+		So(service.Path, ShouldEqual, "/system.slice/nginx.service")
+		So(service.Unit, ShouldEqual, "nginx.service")
+		So(service.CPUUsage, ShouldEqual, 2000000000)
+		So(service.CPUPeriods, ShouldEqual, 100)
+		So(service.CPUThrottled, ShouldEqual, 10)
+		So(service.CPUThrottledTime, ShouldEqual, 30000000)
+		So(service.CPULimit, ShouldEqual, 0)
+		So(service.MemoryLimit, ShouldEqual, 0)
+		So(service.OOMKills, ShouldEqual, 1)
+		So(service.IOReadBytes, ShouldEqual, 2048)
+		So(service.IOWriteBytes, ShouldEqual, 2048)
+	})
+}
+
+func TestCgroupV1(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "cgroup")
+	defer os.RemoveAll(dir)
+	defer SetCgroupDir("/sys/fs/cgroup")
+	SetCgroupDir(dir)
+
+	path := filepath.Join("docker", testContainerID)
+	writeCgroupFiles(filepath.Join(dir, "cpu,cpuacct", path), map[string]string{
+		"cpuacct.usage":     "3000000000\n",
+		"cpu.stat":          "nr_periods 50\nnr_throttled 5\nthrottled_time 7000000\n",
+		"cpu.cfs_quota_us":  "50000\n",
+		"cpu.cfs_period_us": "100000\n",
+	})
+	os.Symlink("cpu,cpuacct", filepath.Join(dir, "cpu"))
+	os.Symlink("cpu,cpuacct", filepath.Join(dir, "cpuacct"))
+	writeCgroupFiles(filepath.Join(dir, "memory", path), map[string]string{
+		"memory.usage_in_bytes": "2048\n",
+		"memory.limit_in_bytes": "9223372036854771712\n",
+		"memory.oom_control":    "oom_kill_disable 0\nunder_oom 0\noom_kill 2\n",
+	})
+	writeCgroupFiles(filepath.Join(dir, "blkio", path), map[string]string{
+		"blkio.throttle.io_service_bytes": "8:0 Read 4096\n8:0 Write 1024\n8:0 Sync 0\n8:0 Total 5120\nTotal 5120\n",
+	})
+	writeCgroupFiles(filepath.Join(dir, "cpu,cpuacct", "user.slice"), nil)
+
+	Convey("cgroup.v1", t, func() {
+		So(CgroupVersion(), ShouldEqual, 1)
+		stats, err := Cgroups()
+		So(err, ShouldBeNil)
+		So(stats, ShouldHaveLength, 1)
+
+		stat := stats[0]
+		So(stat.Path, ShouldEqual, "/docker/"+testContainerID)
+		So(stat.ContainerID, ShouldEqual, testContainerID[:12])
+		So(stat.CPUUsage, ShouldEqual, 3000000000)
+		So(stat.CPUPeriods, ShouldEqual, 50)
+		So(stat.CPUThrottled, ShouldEqual, 5)
+		So(stat.CPUThrottledTime, ShouldEqual, 7000000)
+		So(stat.CPULimit, ShouldEqual, 0.5)
+		So(stat.MemoryUsage, ShouldEqual, 2048)
+		So(stat.MemoryLimit, ShouldEqual, 0)
+		So(stat.OOMKills, ShouldEqual, 2)
+		So(stat.IOReadBytes, ShouldEqual, 4096)
+		So(stat.IOWriteBytes, ShouldEqual, 1024)
+	})
+
+	Convey("cgroup.none", t, func() {
+		SetCgroupDir(filepath.Join(dir, "none"))
+		stats, err := Cgroups()
+		So(err, ShouldBeNil)
+		So(stats, ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,188 @@
+package sysprocfs
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const clockTicks = 100 // USER_HZ, clock ticks per second in /proc/<pid>/stat
+
+var (
+	procDir  = "/proc"
+	pageSize = int64(os.Getpagesize())
+
+	// ErrProcessStatFormat means /proc/<pid>/stat is not in right format
+	ErrProcessStatFormat = errors.New("process-stat-format")
+)
+
+// ProcessInfo is stat of one process from /proc/<pid>
+type ProcessInfo struct {
+	Pid        int    `json:"pid"`
+	Name       string `json:"name"`
+	Cmdline    string `json:"cmdline"`
+	Unit       string `json:"unit,omitempty"` // systemd unit of the process
+	CPUTicks   uint64 `json:"cpu_ticks"`      // user and system time in clock ticks
+	StartTime  int64  `json:"start_time"`     // seconds after system boot
+	RSS        int64  `json:"rss"`            // bytes
+	Threads    int64  `json:"threads"`
+	Fds        int64  `json:"fds"`
+	ReadBytes  int64  `json:"read_bytes"`
+	WriteBytes int64  `json:"write_bytes"`
+}
+
+// SetProcDir sets procfs directory, such as /host/proc when running in container
+func SetProcDir(dir string) {
+	procDir = dir
+}
+
+// Pids returns all process ids in /proc
+func Pids() ([]int, error) {
+	files, err := ioutil.ReadDir(procDir)
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, 0, len(files))
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+		if pid, err := strconv.Atoi(f.Name()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// ProcessName returns name of process from /proc/<pid>/comm
+func ProcessName(pid int) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// ProcessCmdline returns command line of process, arguments are joined by space
+func ProcessCmdline(pid int) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return "", err
+	}
+	data = bytes.TrimRight(data, "\x00")
+	return string(bytes.Replace(data, []byte{0}, []byte{' '}, -1)), nil
+}
+
+// ProcessUnit returns systemd unit of process from /proc/<pid>/cgroup,
+// such as nginx.service
+func ProcessUnit(pid int) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return "", err
+	}
+	var unit string
+	for _, line := range strings.Split(string(data), "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[1] != "name=systemd" && parts[0] != "0" {
+			continue
+		}
+		for _, name := range strings.Split(parts[2], "/") {
+			if strings.HasSuffix(name, ".service") || strings.HasSuffix(name, ".scope") {
+				unit = name
+			}
+		}
+		if unit != "" {
+			break
+		}
+	}
+	return unit, nil
+}
+
+// ReadProcess reads process stats from /proc/<pid>,
+// fds and io bytes are zero if no permission to read
+func ReadProcess(pid int) (*ProcessInfo, error) {
+	dir := filepath.Join(procDir, strconv.Itoa(pid))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	info, err := parseProcessStat(data)
+	if err != nil {
+		return nil, err
+	}
+	info.Pid = pid
+	info.Cmdline, _ = ProcessCmdline(pid)
+	info.Unit, _ = ProcessUnit(pid)
+	if fds, err := ioutil.ReadDir(filepath.Join(dir, "fd")); err == nil {
+		info.Fds = int64(len(fds))
+	}
+	if ioData, err := ioutil.ReadFile(filepath.Join(dir, "io")); err == nil {
+		for _, line := range strings.Split(string(ioData), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			value, _ := strconv.ParseInt(fields[1], 10, 64)
+			switch fields[0] {
+			case "read_bytes:":
+				info.ReadBytes = value
+			case "write_bytes:":
+				info.WriteBytes = value
+			}
+		}
+	}
+	return info, nil
+}
+
+// parseProcessStat parses /proc/<pid>/stat
+func parseProcessStat(data []byte) (*ProcessInfo, error) {
+	// name is in parentheses and may contain spaces or parentheses
+	begin, end := bytes.IndexByte(data, '('), bytes.LastIndexByte(data, ')')
+	if begin < 0 || end < begin {
+		return nil, ErrProcessStatFormat
+	}
+	// fields from the 3rd field, state
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 22 {
+		return nil, ErrProcessStatFormat
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	threads, _ := strconv.ParseInt(fields[17], 10, 64)
+	startTime, _ := strconv.ParseInt(fields[19], 10, 64)
+	rss, _ := strconv.ParseInt(fields[21], 10, 64)
+	return &ProcessInfo{
+		Name:      string(data[begin+1 : end]),
+		CPUTicks:  utime + stime,
+		StartTime: startTime / clockTicks,
+		RSS:       rss * pageSize,
+		Threads:   threads,
+	}, nil
+}
+
+// BootTime returns system boot time from btime in /proc/stat
+func BootTime() (int64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(procDir, "stat"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "btime ") {
+			return strconv.ParseInt(strings.TrimSpace(line[6:]), 10, 64)
+		}
+	}
+	return 0, ErrProcessStatFormat
+}
+
+// ProcessCPUSeconds converts clock ticks to seconds
+func ProcessCPUSeconds(ticks uint64) float64 {
+	return float64(ticks) / clockTicks
+}
@@ -0,0 +1,57 @@
+package sysprocfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/baishancloud/mallard/extralib/sysprocfs/procfstest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestProcess(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "procfs")
+	defer os.RemoveAll(dir)
+	defer SetProcDir("/proc")
+	SetProcDir(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "stat"), []byte("cpu  1 2 3 4\nbtime 1534000000\nprocesses 100\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "net"), os.ModePerm)
+	procfstest.WriteProcess(dir, 100, "nginx", "nginx: master process /usr/sbin/nginx", "12:pids:/system.slice/nginx.service\n1:name=systemd:/system.slice/nginx.service\n", 50)
+	procfstest.WriteProcess(dir, 101, "my (app)", "/opt/app/bin/app\x00-c\x00app.conf", "0::/user.slice/user-0.slice/session-1.scope\n", 10)
+
+	Convey("process.read", t, func() {
+		pids, err := Pids()
+		So(err, ShouldBeNil)
+		So(pids, ShouldResemble, []int{100, 101})
+
+		bootTime, err := BootTime()
+		So(err, ShouldBeNil)
+		So(bootTime, ShouldEqual, 1534000000)
+
+		info, err := ReadProcess(100)
+		So(err, ShouldBeNil)
+		So(info.Name, ShouldEqual, "nginx")
+		So(info.Unit, ShouldEqual, "nginx.service")
+		So(info.CPUTicks, ShouldEqual, 100)
+		So(info.StartTime, ShouldEqual, 5)
+		So(info.RSS, ShouldEqual, 256*pageSize)
+		So(info.Threads, ShouldEqual, 4)
+		So(info.Fds, ShouldEqual, 3)
+		So(info.ReadBytes, ShouldEqual, 4096)
+		So(info.WriteBytes, ShouldEqual, 8192)
+
+		info, err = ReadProcess(101)
+		So(err, ShouldBeNil)
+		So(info.Name, ShouldEqual, "my (app)")
+		So(info.Cmdline, ShouldEqual, "/opt/app/bin/app -c app.conf")
+		So(info.Unit, ShouldEqual, "session-1.scope")
+
+		_, err = ReadProcess(102)
+		So(err, ShouldNotBeNil)
+
+		_, err = parseProcessStat([]byte("100 (nginx) S 1"))
+		So(err, ShouldEqual, ErrProcessStatFormat)
+	})
+}
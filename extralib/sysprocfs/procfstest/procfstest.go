@@ -0,0 +1,27 @@
+// Package procfstest writes fake procfs files for testing code reading sysprocfs
+package procfstest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// WriteProcess writes /proc/<pid> files of a process to fake procfs dir,
+// the process has 4 threads, 3 fds, 256 pages rss, ticks in both utime and stime,
+// 4096 read bytes and 8192 write bytes
+func WriteProcess(dir string, pid int, name string, cmdline string, cgroup string, ticks int) {
+	pidDir := filepath.Join(dir, strconv.Itoa(pid))
+	os.MkdirAll(filepath.Join(pidDir, "fd"), os.ModePerm)
+	for i := 0; i < 3; i++ {
+		ioutil.WriteFile(filepath.Join(pidDir, "fd", strconv.Itoa(i)), nil, 0644)
+	}
+	stat := fmt.Sprintf("%d (%s) S 1 %d %d 0 -1 4202752 100 0 0 0 %d %d 0 0 20 0 4 0 500 102400 256 18446744073709551615", pid, name, pid, pid, ticks, ticks)
+	ioutil.WriteFile(filepath.Join(pidDir, "stat"), []byte(stat), 0644)
+	ioutil.WriteFile(filepath.Join(pidDir, "comm"), []byte(name+"\n"), 0644)
+	ioutil.WriteFile(filepath.Join(pidDir, "cmdline"), []byte(cmdline+"\x00"), 0644)
+	ioutil.WriteFile(filepath.Join(pidDir, "cgroup"), []byte(cgroup), 0644)
+	ioutil.WriteFile(filepath.Join(pidDir, "io"), []byte("rchar: 100\nwchar: 200\nread_bytes: 4096\nwrite_bytes: 8192\n"), 0644)
+}